@@ -1,15 +1,314 @@
 package app
 
-import "kirmac-site-backend/common/postgresql"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v3/log"
+	"gopkg.in/yaml.v3"
+	"kirmac-site-backend/common/postgresql"
+	"kirmac-site-backend/common/ratelimit"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFileEnv names the environment variable pointing at an optional YAML or JSON configuration file
+const ConfigFileEnv = "KIRMAC_CONFIG_FILE"
+
+var logLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+var corsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// headerNamePattern matches the tokens RFC 9110 allows in header names
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// minJWTSecretLength is the size of the SHA-256 output, shorter HMAC secrets weaken the signature
+const minJWTSecretLength = 32
 
 type ConfigurationManager struct {
-	PostgreSqlConfig postgresql.Config
+	PostgreSqlConfig postgresql.Config  `yaml:"postgresql" json:"postgresql"`
+	QueryTimeouts    QueryTimeoutConfig `yaml:"query_timeouts" json:"query_timeouts"`
+	Server           ServerConfig       `yaml:"server" json:"server"`
+	Cors             CorsConfig         `yaml:"cors" json:"cors"`
+	Storage          StorageConfig      `yaml:"storage" json:"storage"`
+	Trash            TrashConfig        `yaml:"trash" json:"trash"`
+	Auth             AuthConfig         `yaml:"auth" json:"auth"`
+	Tenancy          TenancyConfig      `yaml:"tenancy" json:"tenancy"`
+	RateLimit        RateLimitConfig    `yaml:"rate_limit" json:"rate_limit"`
+	LogLevel         string             `yaml:"log_level" json:"log_level"`
+	AutoMigrate      bool               `yaml:"auto_migrate" json:"auto_migrate"`
+}
+
+type ServerConfig struct {
+	ListenAddress string   `yaml:"listen_address" json:"listen_address"`
+	ReadTimeout   Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout  Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout   Duration `yaml:"idle_timeout" json:"idle_timeout"`
+}
+
+// QueryTimeoutConfig limits how long database queries may run, zero disables the limit
+type QueryTimeoutConfig struct {
+	Read   Duration `yaml:"read" json:"read"`
+	Write  Duration `yaml:"write" json:"write"`
+	Search Duration `yaml:"search" json:"search"`
+}
+
+// CorsPolicy tells which browser origins may call the API and what their requests may carry.
+// A zero MaxAge leaves the caching of preflight responses to the browser.
+type CorsPolicy struct {
+	AllowOrigins     []string `yaml:"allow_origins" json:"allow_origins"`
+	AllowMethods     []string `yaml:"allow_methods" json:"allow_methods"`
+	AllowHeaders     []string `yaml:"allow_headers" json:"allow_headers"`
+	ExposeHeaders    []string `yaml:"expose_headers" json:"expose_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" json:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age" json:"max_age"`
+}
+
+// CorsConfig is the CORS policy of the public read API, Admin overrides it for the requests that write, sign in
+// or carry credentials. The admin API allows no other origin unless its own origins are set, never the * origin.
+type CorsConfig struct {
+	CorsPolicy `yaml:",inline"`
+	Admin      CorsOverride `yaml:"admin" json:"admin"`
+}
+
+// CorsOverride replaces the fields of a CorsPolicy it sets and keeps the others
+type CorsOverride struct {
+	AllowOrigins     []string  `yaml:"allow_origins" json:"allow_origins"`
+	AllowMethods     []string  `yaml:"allow_methods" json:"allow_methods"`
+	AllowHeaders     []string  `yaml:"allow_headers" json:"allow_headers"`
+	ExposeHeaders    []string  `yaml:"expose_headers" json:"expose_headers"`
+	AllowCredentials *bool     `yaml:"allow_credentials" json:"allow_credentials"`
+	MaxAge           *Duration `yaml:"max_age" json:"max_age"`
+}
+
+// AdminPolicy is the public policy with the admin overrides applied
+func (config CorsConfig) AdminPolicy() CorsPolicy {
+	policy := config.CorsPolicy
+	admin := config.Admin
+	if admin.AllowOrigins != nil {
+		policy.AllowOrigins = admin.AllowOrigins
+	}
+	if admin.AllowMethods != nil {
+		policy.AllowMethods = admin.AllowMethods
+	}
+	if admin.AllowHeaders != nil {
+		policy.AllowHeaders = admin.AllowHeaders
+	}
+	if admin.ExposeHeaders != nil {
+		policy.ExposeHeaders = admin.ExposeHeaders
+	}
+	if admin.AllowCredentials != nil {
+		policy.AllowCredentials = *admin.AllowCredentials
+	}
+	if admin.MaxAge != nil {
+		policy.MaxAge = *admin.MaxAge
+	}
+	return policy
+}
+
+// StorageConfig selects where uploaded images are kept, Backend is local or s3
+type StorageConfig struct {
+	Backend       string             `yaml:"backend" json:"backend"`
+	MaxUploadSize int64              `yaml:"max_upload_size" json:"max_upload_size"`
+	Local         LocalStorageConfig `yaml:"local" json:"local"`
+	S3            S3StorageConfig    `yaml:"s3" json:"s3"`
+}
+
+type LocalStorageConfig struct {
+	Directory string `yaml:"directory" json:"directory"`
+	PublicURL string `yaml:"public_url" json:"public_url"`
+}
+
+type S3StorageConfig struct {
+	Endpoint        string `yaml:"endpoint" json:"endpoint"`
+	Region          string `yaml:"region" json:"region"`
+	Bucket          string `yaml:"bucket" json:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"secret_access_key"`
+	PublicURL       string `yaml:"public_url" json:"public_url"`
+}
+
+// TrashConfig sets how long deleted properties are kept before they are purged, a zero retention keeps them forever
+type TrashConfig struct {
+	Retention     Duration `yaml:"retention" json:"retention"`
+	PurgeInterval Duration `yaml:"purge_interval" json:"purge_interval"`
+}
+
+// AuthConfig signs the tokens handed out on sign in, an empty secret is replaced with a random one on every start
+type AuthConfig struct {
+	JWTSecret       string   `yaml:"jwt_secret" json:"jwt_secret"`
+	Issuer          string   `yaml:"issuer" json:"issuer"`
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" json:"access_token_ttl"`
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" json:"refresh_token_ttl"`
+}
+
+// TenancyConfig tells how the tenant of a request is found. Anonymous requests belong to the tenant serving the host
+// named by HostHeader, or to the tenant with the slug DefaultTenant when no tenant serves it; an empty DefaultTenant refuses them.
+// The tenants are read from the database at most once every CacheTTL.
+type TenancyConfig struct {
+	DefaultTenant string   `yaml:"default_tenant" json:"default_tenant"`
+	HostHeader    string   `yaml:"host_header" json:"host_header"`
+	CacheTTL      Duration `yaml:"cache_ttl" json:"cache_ttl"`
 }
 
-func NewConfigurationManager() *ConfigurationManager {
-	postgreSqlConfig := getPostgreSqlConfig()
+// RateLimitConfig limits the requests of every client, told apart by its API key or else its IP address.
+// IPHeader names a header a proxy in front of the server sets to the client address, empty uses the connection address.
+type RateLimitConfig struct {
+	Enabled  bool      `yaml:"enabled" json:"enabled"`
+	IPHeader string    `yaml:"ip_header" json:"ip_header"`
+	Public   RateLimit `yaml:"public" json:"public"`
+	Write    RateLimit `yaml:"write" json:"write"`
+	Login    RateLimit `yaml:"login" json:"login"`
+}
+
+// RateLimit lets a client make Requests requests every Period and up to Burst at once, Burst defaults to Requests.
+// Zero requests lift the limit.
+type RateLimit struct {
+	Requests int      `yaml:"requests" json:"requests"`
+	Period   Duration `yaml:"period" json:"period"`
+	Burst    int      `yaml:"burst" json:"burst"`
+}
+
+// Limit converts the limit to the token bucket of the limiter
+func (limit RateLimit) Limit() ratelimit.Limit {
+	burst := limit.Burst
+	if burst == 0 {
+		burst = limit.Requests
+	}
+	return ratelimit.Limit{Requests: limit.Requests, Period: time.Duration(limit.Period), Burst: burst}
+}
+
+// Duration is a time.Duration written as a string such as "30s" in configuration files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// NewConfigurationManager loads the configuration from the file named by KIRMAC_CONFIG_FILE, if any,
+// and the KIRMAC_* environment variables
+func NewConfigurationManager() (*ConfigurationManager, error) {
+	return LoadConfigurationManager(os.Getenv(ConfigFileEnv), os.LookupEnv)
+}
+
+// LoadConfigurationManager applies the file at path and then the environment variables found by lookupEnv
+// on top of the defaults, and validates the result
+func LoadConfigurationManager(path string, lookupEnv func(string) (string, bool)) (*ConfigurationManager, error) {
+	configurationManager := defaultConfigurationManager()
+	if path != "" {
+		if err := configurationManager.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var errs []error
+	for _, override := range configurationManager.environmentOverrides() {
+		value, ok := lookupEnv(override.name)
+		if !ok {
+			continue
+		}
+		if err := override.apply(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", override.name, err))
+		}
+	}
+	errs = append(errs, configurationManager.validate()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return configurationManager, nil
+}
+
+// FiberLogLevel converts LogLevel to the level of the fiber logger
+func (configurationManager *ConfigurationManager) FiberLogLevel() log.Level {
+	switch configurationManager.LogLevel {
+	case "trace":
+		return log.LevelTrace
+	case "debug":
+		return log.LevelDebug
+	case "warn":
+		return log.LevelWarn
+	case "error":
+		return log.LevelError
+	}
+	return log.LevelInfo
+}
+
+func defaultConfigurationManager() *ConfigurationManager {
 	return &ConfigurationManager{
-		PostgreSqlConfig: postgreSqlConfig,
+		PostgreSqlConfig: getPostgreSqlConfig(),
+		QueryTimeouts: QueryTimeoutConfig{
+			Read:   Duration(5 * time.Second),
+			Write:  Duration(5 * time.Second),
+			Search: Duration(10 * time.Second),
+		},
+		Server: ServerConfig{
+			ListenAddress: ":8080",
+			ReadTimeout:   Duration(10 * time.Second),
+			WriteTimeout:  Duration(10 * time.Second),
+			IdleTimeout:   Duration(60 * time.Second),
+		},
+		Cors: CorsConfig{
+			CorsPolicy: CorsPolicy{
+				AllowOrigins:  []string{"*"},
+				AllowMethods:  []string{"GET", "HEAD"},
+				AllowHeaders:  []string{"Accept", "Content-Type", "If-None-Match", "X-Request-ID"},
+				ExposeHeaders: []string{"ETag", "Location", "X-Request-ID"},
+				MaxAge:        Duration(10 * time.Minute),
+			},
+			Admin: CorsOverride{
+				// browsers on other origins may not call the admin API until its origins are configured
+				AllowOrigins: []string{},
+				AllowMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+				AllowHeaders: []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match", "X-API-Key", "X-Request-ID"},
+			},
+		},
+		Storage: StorageConfig{
+			Backend:       "local",
+			MaxUploadSize: 10 << 20,
+			Local: LocalStorageConfig{
+				Directory: "uploads",
+				PublicURL: "http://localhost:8080/images",
+			},
+			S3: S3StorageConfig{
+				Region: "us-east-1",
+			},
+		},
+		Trash: TrashConfig{
+			Retention:     Duration(30 * 24 * time.Hour),
+			PurgeInterval: Duration(time.Hour),
+		},
+		Auth: AuthConfig{
+			Issuer:          "kirmac-site-backend",
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(30 * 24 * time.Hour),
+		},
+		Tenancy: TenancyConfig{
+			DefaultTenant: "default",
+			HostHeader:    "Host",
+			CacheTTL:      Duration(time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Public:  RateLimit{Requests: 300, Period: Duration(time.Minute), Burst: 60},
+			Write:   RateLimit{Requests: 60, Period: Duration(time.Minute), Burst: 20},
+			Login:   RateLimit{Requests: 5, Period: Duration(time.Minute), Burst: 5},
+		},
+		LogLevel:    "info",
+		AutoMigrate: true,
 	}
 }
 
@@ -24,3 +323,377 @@ func getPostgreSqlConfig() postgresql.Config {
 		MaxConnectionIdleTime: "30s",
 	}
 }
+
+func (configurationManager *ConfigurationManager) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read configuration file: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, configurationManager)
+	case ".json":
+		err = json.Unmarshal(content, configurationManager)
+	default:
+		return fmt.Errorf("unsupported configuration file %s, expected .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse configuration file %s: %v", path, err)
+	}
+	return nil
+}
+
+type environmentOverride struct {
+	name  string
+	apply func(value string) error
+}
+
+func (configurationManager *ConfigurationManager) environmentOverrides() []environmentOverride {
+	setString := func(target *string) func(string) error {
+		return func(value string) error {
+			*target = value
+			return nil
+		}
+	}
+	setDuration := func(target *Duration) func(string) error {
+		return func(value string) error {
+			return target.UnmarshalText([]byte(value))
+		}
+	}
+	setBool := func(target *bool) func(string) error {
+		return func(value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("must be true or false")
+			}
+			*target = parsed
+			return nil
+		}
+	}
+	setInt := func(target *int) func(string) error {
+		return func(value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("must be a number")
+			}
+			*target = parsed
+			return nil
+		}
+	}
+	setList := func(target *[]string) func(string) error {
+		return func(value string) error {
+			*target = append([]string{}, splitList(value)...)
+			return nil
+		}
+	}
+	db := &configurationManager.PostgreSqlConfig
+	server := &configurationManager.Server
+	cors := &configurationManager.Cors
+	storage := &configurationManager.Storage
+	rateLimit := &configurationManager.RateLimit
+	return []environmentOverride{
+		{"KIRMAC_DB_HOST", setString(&db.Host)},
+		{"KIRMAC_DB_PORT", setString(&db.Port)},
+		{"KIRMAC_DB_USER", setString(&db.UserName)},
+		{"KIRMAC_DB_PASSWORD", setString(&db.Password)},
+		{"KIRMAC_DB_NAME", setString(&db.DbName)},
+		{"KIRMAC_DB_MAX_CONNECTIONS", setString(&db.MaxConnections)},
+		{"KIRMAC_DB_MAX_CONNECTION_IDLE_TIME", setString(&db.MaxConnectionIdleTime)},
+		{"KIRMAC_DB_ROW_LEVEL_SECURITY", setBool(&db.RowLevelSecurity)},
+		{"KIRMAC_DB_READ_TIMEOUT", setDuration(&configurationManager.QueryTimeouts.Read)},
+		{"KIRMAC_DB_WRITE_TIMEOUT", setDuration(&configurationManager.QueryTimeouts.Write)},
+		{"KIRMAC_DB_SEARCH_TIMEOUT", setDuration(&configurationManager.QueryTimeouts.Search)},
+		{"KIRMAC_HTTP_LISTEN_ADDRESS", setString(&server.ListenAddress)},
+		{"KIRMAC_HTTP_READ_TIMEOUT", setDuration(&server.ReadTimeout)},
+		{"KIRMAC_HTTP_WRITE_TIMEOUT", setDuration(&server.WriteTimeout)},
+		{"KIRMAC_HTTP_IDLE_TIMEOUT", setDuration(&server.IdleTimeout)},
+		{"KIRMAC_CORS_ALLOW_ORIGINS", setList(&cors.AllowOrigins)},
+		{"KIRMAC_CORS_ALLOW_METHODS", setList(&cors.AllowMethods)},
+		{"KIRMAC_CORS_ALLOW_HEADERS", setList(&cors.AllowHeaders)},
+		{"KIRMAC_CORS_EXPOSE_HEADERS", setList(&cors.ExposeHeaders)},
+		{"KIRMAC_CORS_ALLOW_CREDENTIALS", setBool(&cors.AllowCredentials)},
+		{"KIRMAC_CORS_MAX_AGE", setDuration(&cors.MaxAge)},
+		{"KIRMAC_CORS_ADMIN_ALLOW_ORIGINS", setList(&cors.Admin.AllowOrigins)},
+		{"KIRMAC_CORS_ADMIN_ALLOW_METHODS", setList(&cors.Admin.AllowMethods)},
+		{"KIRMAC_CORS_ADMIN_ALLOW_HEADERS", setList(&cors.Admin.AllowHeaders)},
+		{"KIRMAC_CORS_ADMIN_EXPOSE_HEADERS", setList(&cors.Admin.ExposeHeaders)},
+		{"KIRMAC_CORS_ADMIN_ALLOW_CREDENTIALS", func(value string) error {
+			cors.Admin.AllowCredentials = new(bool)
+			return setBool(cors.Admin.AllowCredentials)(value)
+		}},
+		{"KIRMAC_CORS_ADMIN_MAX_AGE", func(value string) error {
+			cors.Admin.MaxAge = new(Duration)
+			return setDuration(cors.Admin.MaxAge)(value)
+		}},
+		{"KIRMAC_STORAGE_BACKEND", setString(&storage.Backend)},
+		{"KIRMAC_STORAGE_MAX_UPLOAD_SIZE", func(value string) error {
+			maxUploadSize, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("must be a number of bytes")
+			}
+			storage.MaxUploadSize = maxUploadSize
+			return nil
+		}},
+		{"KIRMAC_STORAGE_DIRECTORY", setString(&storage.Local.Directory)},
+		{"KIRMAC_STORAGE_PUBLIC_URL", setString(&storage.Local.PublicURL)},
+		{"KIRMAC_S3_ENDPOINT", setString(&storage.S3.Endpoint)},
+		{"KIRMAC_S3_REGION", setString(&storage.S3.Region)},
+		{"KIRMAC_S3_BUCKET", setString(&storage.S3.Bucket)},
+		{"KIRMAC_S3_ACCESS_KEY_ID", setString(&storage.S3.AccessKeyID)},
+		{"KIRMAC_S3_SECRET_ACCESS_KEY", setString(&storage.S3.SecretAccessKey)},
+		{"KIRMAC_S3_PUBLIC_URL", setString(&storage.S3.PublicURL)},
+		{"KIRMAC_TRASH_RETENTION", setDuration(&configurationManager.Trash.Retention)},
+		{"KIRMAC_TRASH_PURGE_INTERVAL", setDuration(&configurationManager.Trash.PurgeInterval)},
+		{"KIRMAC_JWT_SECRET", setString(&configurationManager.Auth.JWTSecret)},
+		{"KIRMAC_AUTH_ISSUER", setString(&configurationManager.Auth.Issuer)},
+		{"KIRMAC_AUTH_ACCESS_TOKEN_TTL", setDuration(&configurationManager.Auth.AccessTokenTTL)},
+		{"KIRMAC_AUTH_REFRESH_TOKEN_TTL", setDuration(&configurationManager.Auth.RefreshTokenTTL)},
+		{"KIRMAC_TENANCY_DEFAULT_TENANT", setString(&configurationManager.Tenancy.DefaultTenant)},
+		{"KIRMAC_TENANCY_HOST_HEADER", setString(&configurationManager.Tenancy.HostHeader)},
+		{"KIRMAC_TENANCY_CACHE_TTL", setDuration(&configurationManager.Tenancy.CacheTTL)},
+		{"KIRMAC_RATE_LIMIT_ENABLED", setBool(&rateLimit.Enabled)},
+		{"KIRMAC_RATE_LIMIT_IP_HEADER", setString(&rateLimit.IPHeader)},
+		{"KIRMAC_RATE_LIMIT_PUBLIC_REQUESTS", setInt(&rateLimit.Public.Requests)},
+		{"KIRMAC_RATE_LIMIT_PUBLIC_PERIOD", setDuration(&rateLimit.Public.Period)},
+		{"KIRMAC_RATE_LIMIT_PUBLIC_BURST", setInt(&rateLimit.Public.Burst)},
+		{"KIRMAC_RATE_LIMIT_WRITE_REQUESTS", setInt(&rateLimit.Write.Requests)},
+		{"KIRMAC_RATE_LIMIT_WRITE_PERIOD", setDuration(&rateLimit.Write.Period)},
+		{"KIRMAC_RATE_LIMIT_WRITE_BURST", setInt(&rateLimit.Write.Burst)},
+		{"KIRMAC_RATE_LIMIT_LOGIN_REQUESTS", setInt(&rateLimit.Login.Requests)},
+		{"KIRMAC_RATE_LIMIT_LOGIN_PERIOD", setDuration(&rateLimit.Login.Period)},
+		{"KIRMAC_RATE_LIMIT_LOGIN_BURST", setInt(&rateLimit.Login.Burst)},
+		{"KIRMAC_LOG_LEVEL", setString(&configurationManager.LogLevel)},
+		{"KIRMAC_AUTO_MIGRATE", setBool(&configurationManager.AutoMigrate)},
+	}
+}
+
+// validate checks every field and reports all problems instead of stopping at the first one
+func (configurationManager *ConfigurationManager) validate() []error {
+	var errs []error
+	addError := func(field string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	db := configurationManager.PostgreSqlConfig
+	if db.Host == "" {
+		addError("postgresql.host", "must not be empty")
+	}
+	if port, err := strconv.Atoi(db.Port); err != nil || port < 1 || port > 65535 {
+		addError("postgresql.port", "must be a number between 1 and 65535, got %q", db.Port)
+	}
+	if db.DbName == "" {
+		addError("postgresql.db_name", "must not be empty")
+	}
+	if maxConnections, err := strconv.Atoi(db.MaxConnections); err != nil || maxConnections < 1 {
+		addError("postgresql.max_connections", "must be a positive number, got %q", db.MaxConnections)
+	}
+	if idleTime, err := time.ParseDuration(db.MaxConnectionIdleTime); err != nil || idleTime <= 0 {
+		addError("postgresql.max_connection_idle_time", "must be a positive duration such as 30s, got %q", db.MaxConnectionIdleTime)
+	}
+
+	queryTimeouts := configurationManager.QueryTimeouts
+	if queryTimeouts.Read < 0 {
+		addError("query_timeouts.read", "must not be negative")
+	}
+	if queryTimeouts.Write < 0 {
+		addError("query_timeouts.write", "must not be negative")
+	}
+	if queryTimeouts.Search < 0 {
+		addError("query_timeouts.search", "must not be negative")
+	}
+
+	server := configurationManager.Server
+	if _, port, err := net.SplitHostPort(server.ListenAddress); err != nil || port == "" {
+		addError("server.listen_address", "must be a host:port address such as :8080, got %q", server.ListenAddress)
+	}
+	if server.ReadTimeout < 0 {
+		addError("server.read_timeout", "must not be negative")
+	}
+	if server.WriteTimeout < 0 {
+		addError("server.write_timeout", "must not be negative")
+	}
+	if server.IdleTimeout < 0 {
+		addError("server.idle_timeout", "must not be negative")
+	}
+
+	publicCorsErrors := validateCorsPolicy(configurationManager.Cors.CorsPolicy, true)
+	for _, err := range publicCorsErrors {
+		addError("cors."+err.field, err.message)
+	}
+	// the admin policy inherits the problems of the public one, only report its own
+	for _, err := range validateCorsPolicy(configurationManager.Cors.AdminPolicy(), false) {
+		if !containsFieldError(publicCorsErrors, err) {
+			addError("cors.admin."+err.field, err.message)
+		}
+	}
+
+	storage := configurationManager.Storage
+	if storage.MaxUploadSize <= 0 {
+		addError("storage.max_upload_size", "must be a positive number of bytes")
+	}
+	switch storage.Backend {
+	case "local":
+		if storage.Local.Directory == "" {
+			addError("storage.local.directory", "must not be empty")
+		}
+		if !isAbsoluteURL(storage.Local.PublicURL) {
+			addError("storage.local.public_url", "must be an absolute http or https URL, got %q", storage.Local.PublicURL)
+		}
+	case "s3":
+		if !isAbsoluteURL(storage.S3.Endpoint) {
+			addError("storage.s3.endpoint", "must be an absolute http or https URL, got %q", storage.S3.Endpoint)
+		}
+		if storage.S3.Region == "" {
+			addError("storage.s3.region", "must not be empty")
+		}
+		if storage.S3.Bucket == "" {
+			addError("storage.s3.bucket", "must not be empty")
+		}
+		if storage.S3.PublicURL != "" && !isAbsoluteURL(storage.S3.PublicURL) {
+			addError("storage.s3.public_url", "must be an absolute http or https URL, got %q", storage.S3.PublicURL)
+		}
+	default:
+		addError("storage.backend", "must be local or s3, got %q", storage.Backend)
+	}
+
+	if configurationManager.Trash.Retention < 0 {
+		addError("trash.retention", "must not be negative")
+	}
+	if configurationManager.Trash.PurgeInterval <= 0 {
+		addError("trash.purge_interval", "must be a positive duration such as 1h")
+	}
+
+	auth := configurationManager.Auth
+	if auth.JWTSecret != "" && len(auth.JWTSecret) < minJWTSecretLength {
+		addError("auth.jwt_secret", "must be at least %d bytes", minJWTSecretLength)
+	}
+	if auth.Issuer == "" {
+		addError("auth.issuer", "must not be empty")
+	}
+	if auth.AccessTokenTTL <= 0 {
+		addError("auth.access_token_ttl", "must be a positive duration such as 15m")
+	}
+	if auth.RefreshTokenTTL < auth.AccessTokenTTL {
+		addError("auth.refresh_token_ttl", "must not be shorter than auth.access_token_ttl")
+	}
+
+	if configurationManager.Tenancy.HostHeader == "" {
+		addError("tenancy.host_header", "must not be empty")
+	}
+	if configurationManager.Tenancy.CacheTTL <= 0 {
+		addError("tenancy.cache_ttl", "must be a positive duration such as 1m")
+	}
+
+	for _, group := range []struct {
+		name  string
+		limit RateLimit
+	}{
+		{"public", configurationManager.RateLimit.Public},
+		{"write", configurationManager.RateLimit.Write},
+		{"login", configurationManager.RateLimit.Login},
+	} {
+		if group.limit.Requests < 0 {
+			addError("rate_limit."+group.name+".requests", "must not be negative")
+		}
+		if group.limit.Requests > 0 && group.limit.Period <= 0 {
+			addError("rate_limit."+group.name+".period", "must be a positive duration such as 1m")
+		}
+		if group.limit.Burst < 0 {
+			addError("rate_limit."+group.name+".burst", "must not be negative")
+		}
+	}
+
+	if !contains(logLevels, configurationManager.LogLevel) {
+		addError("log_level", "must be one of %s, got %q", strings.Join(logLevels, ", "), configurationManager.LogLevel)
+	}
+	return errs
+}
+
+type fieldError struct {
+	field   string
+	message string
+}
+
+// validateCorsPolicy checks a CORS policy, the fields of the errors are relative to the policy. A public policy
+// needs an origin and may allow every origin with *, other policies may allow none but must name the ones they allow.
+func validateCorsPolicy(policy CorsPolicy, public bool) []fieldError {
+	var errs []fieldError
+	if public && len(policy.AllowOrigins) == 0 {
+		errs = append(errs, fieldError{"allow_origins", "must contain at least one origin"})
+	}
+	for _, origin := range policy.AllowOrigins {
+		if origin == "*" && !public {
+			errs = append(errs, fieldError{"allow_origins", "must name its origins, * is only allowed for the public API"})
+			continue
+		}
+		if !isValidOrigin(origin) {
+			errs = append(errs, fieldError{"allow_origins", fmt.Sprintf("%q is not * or a scheme://host origin", origin)})
+		}
+		if origin == "*" && policy.AllowCredentials {
+			errs = append(errs, fieldError{"allow_credentials", "must not be true when allow_origins contains *"})
+		}
+	}
+	if len(policy.AllowMethods) == 0 {
+		errs = append(errs, fieldError{"allow_methods", "must contain at least one method"})
+	}
+	for _, method := range policy.AllowMethods {
+		if !contains(corsMethods, method) {
+			errs = append(errs, fieldError{"allow_methods", fmt.Sprintf("must be one of %s, got %q", strings.Join(corsMethods, ", "), method)})
+		}
+	}
+	for _, header := range policy.AllowHeaders {
+		if !headerNamePattern.MatchString(header) {
+			errs = append(errs, fieldError{"allow_headers", fmt.Sprintf("%q is not a header name", header)})
+		}
+	}
+	for _, header := range policy.ExposeHeaders {
+		if !headerNamePattern.MatchString(header) {
+			errs = append(errs, fieldError{"expose_headers", fmt.Sprintf("%q is not a header name", header)})
+		}
+	}
+	if policy.MaxAge < 0 {
+		errs = append(errs, fieldError{"max_age", "must not be negative"})
+	}
+	return errs
+}
+
+func containsFieldError(errs []fieldError, err fieldError) bool {
+	for _, e := range errs {
+		if e == err {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" && parsed.Path == ""
+}
+
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ContentType is the media type of GeoJSON documents
+const ContentType = "application/geo+json"
+
+// Feature is a GeoJSON feature, properties are encoded with encoding/json
+type Feature struct {
+	Type       string      `json:"type"`
+	ID         interface{} `json:"id,omitempty"`
+	Geometry   Point       `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+// Point is a GeoJSON point geometry, coordinates are longitude first
+type Point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// NewPointFeature creates a feature located at latitude and longitude
+func NewPointFeature(id interface{}, latitude float64, longitude float64, properties interface{}) Feature {
+	return Feature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   Point{Type: "Point", Coordinates: [2]float64{longitude, latitude}},
+		Properties: properties,
+	}
+}
+
+// Writer writes a FeatureCollection one feature at a time, so it never holds more than one feature in memory
+type Writer struct {
+	w       io.Writer
+	started bool
+	count   int
+}
+
+// NewWriter creates a writer for a FeatureCollection written to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write appends a feature to the collection
+func (writer *Writer) Write(feature Feature) error {
+	if err := writer.start(); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(feature)
+	if err != nil {
+		return err
+	}
+	if writer.count > 0 {
+		if _, err := io.WriteString(writer.w, ","); err != nil {
+			return err
+		}
+	}
+	writer.count++
+	_, err = writer.w.Write(encoded)
+	return err
+}
+
+// Close ends the collection, members are added to it next to features as foreign members
+func (writer *Writer) Close(members map[string]interface{}) error {
+	if err := writer.start(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer.w, "]"); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		encodedName, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(members[name])
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(writer.w, ",%s:%s", encodedName, encoded); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(writer.w, "}")
+	return err
+}
+
+func (writer *Writer) start() error {
+	if writer.started {
+		return nil
+	}
+	writer.started = true
+	_, err := io.WriteString(writer.w, `{"type":"FeatureCollection","features":[`)
+	return err
+}
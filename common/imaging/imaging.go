@@ -0,0 +1,102 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// MaxPixels limits the size of images that are decoded, so a small file cannot expand into gigabytes of pixels
+const MaxPixels = 50_000_000
+
+// ErrUnsupported is returned when data is not an image that can be decoded
+var ErrUnsupported = errors.New("unsupported image")
+
+// Decode decodes a JPEG, PNG, GIF or WebP image and turns it upright according to its EXIF orientation.
+// The returned image carries no metadata.
+func Decode(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d pixels is too large", ErrUnsupported, config.Width, config.Height)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if format == "jpeg" {
+		img = orient(img, Orientation(data))
+	}
+	return img, format, nil
+}
+
+// Fit scales img down to fit within maxWidth x maxHeight keeping its aspect ratio, smaller images are returned as they are
+func Fit(img image.Image, maxWidth int, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+	scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	target := image.Rect(0, 0, max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5)))
+	resized := image.NewRGBA(target)
+	draw.CatmullRom.Scale(resized, target, img, bounds, draw.Src, nil)
+	return resized
+}
+
+// Encode writes img as PNG when format is png and as JPEG otherwise, transparent areas of a JPEG become white
+func Encode(w io.Writer, img image.Image, format string) error {
+	if format == "png" {
+		return png.Encode(w, img)
+	}
+	flattened := image.NewRGBA(img.Bounds())
+	draw.Draw(flattened, flattened.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), img, img.Bounds().Min, draw.Over)
+	return jpeg.Encode(w, flattened, &jpeg.Options{Quality: 85})
+}
+
+// orient applies one of the eight EXIF orientations so the image is displayed upright without the tag
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if orientation >= 5 {
+		width, height = height, width
+	}
+	oriented := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = bounds.Dx()-1-x, y
+			case 3: // rotated 180°
+				dx, dy = bounds.Dx()-1-x, bounds.Dy()-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, bounds.Dy()-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise to display
+				dx, dy = bounds.Dy()-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = bounds.Dy()-1-y, bounds.Dx()-1-x
+			case 8: // rotated 90° counter-clockwise to display
+				dx, dy = y, bounds.Dx()-1-x
+			}
+			oriented.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return oriented
+}
@@ -1,11 +1,13 @@
 package postgresql
 
 type Config struct {
-	Host                  string
-	Port                  string
-	UserName              string
-	Password              string
-	DbName                string
-	MaxConnections        string
-	MaxConnectionIdleTime string
+	Host                  string `yaml:"host" json:"host"`
+	Port                  string `yaml:"port" json:"port"`
+	UserName              string `yaml:"user_name" json:"user_name"`
+	Password              string `yaml:"password" json:"password"`
+	DbName                string `yaml:"db_name" json:"db_name"`
+	MaxConnections        string `yaml:"max_connections" json:"max_connections"`
+	MaxConnectionIdleTime string `yaml:"max_connection_idle_time" json:"max_connection_idle_time"`
+	// RowLevelSecurity sets the tenant of the request on every connection taken from the pool for the row-level security policies
+	RowLevelSecurity bool `yaml:"row_level_security" json:"row_level_security"`
 }
@@ -3,8 +3,11 @@ package postgresql
 import (
 	"context"
 	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"kirmac-site-backend/common/requestctx"
 	"log"
+	"strconv"
 )
 
 func GetConnectionPool(context context.Context, config Config) *pgxpool.Pool {
@@ -16,10 +19,32 @@ func GetConnectionPool(context context.Context, config Config) *pgxpool.Pool {
 		config.DbName,
 		config.MaxConnections,
 		config.MaxConnectionIdleTime)
-	connect, err := pgxpool.Connect(context, connString)
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		log.Printf("Unable to parse database configuration: %v\n", err)
+		panic(err)
+	}
+	if config.RowLevelSecurity {
+		poolConfig.BeforeAcquire = setTenant
+	}
+	connect, err := pgxpool.ConnectConfig(context, poolConfig)
 	if err != nil {
 		log.Printf("Unable to connect to database: %v\n", err)
 		panic(err)
 	}
 	return connect
 }
+
+// setTenant sets kirmac.tenant_id to the tenant of the request acquiring conn, or clears it when there is none,
+// a connection that cannot be set is destroyed rather than used with the tenant of an earlier request
+func setTenant(ctx context.Context, conn *pgx.Conn) bool {
+	tenantID := ""
+	if tenant, ok := requestctx.Tenant(ctx); ok {
+		tenantID = strconv.FormatInt(tenant.ID, 10)
+	}
+	if _, err := conn.Exec(ctx, "SELECT set_config('kirmac.tenant_id', $1, false)", tenantID); err != nil {
+		log.Printf("Unable to set the tenant of a connection: %v\n", err)
+		return false
+	}
+	return true
+}
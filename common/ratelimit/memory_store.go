@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets the buckets that refilled
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the buckets in the memory of the process, so every server enforces the limits on its own
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	limit Limit
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]memoryBucket{}}
+}
+
+func (store *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if now.Sub(store.lastSweep) >= sweepInterval {
+		store.sweep(now)
+	}
+	bucket, result := store.buckets[key].Take(limit, now)
+	store.buckets[key] = memoryBucket{Bucket: bucket, limit: limit}
+	return result, nil
+}
+
+// sweep removes the buckets that are full at now, the next request of their key starts a new full bucket
+func (store *MemoryStore) sweep(now time.Time) {
+	for key, bucket := range store.buckets {
+		if bucket.Full(bucket.limit, now) {
+			delete(store.buckets, key)
+		}
+	}
+	store.lastSweep = now
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket refilled with Requests tokens every Period and holding at most Burst tokens,
+// every request takes one token and is refused when the bucket is empty
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Enabled tells whether the limit refuses anything, a limit without requests or period lets every request through
+func (limit Limit) Enabled() bool {
+	return limit.Requests > 0 && limit.Period > 0 && limit.Burst > 0
+}
+
+// interval is the time it takes to refill one token
+func (limit Limit) interval() time.Duration {
+	return limit.Period / time.Duration(limit.Requests)
+}
+
+// Result is what taking a token from a bucket left behind. ResetAfter is how long until the bucket is full again,
+// RetryAfter how long until the next token when the request was refused.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets of the limiter, a store shared by several servers makes the limits apply to all of them
+type Store interface {
+	// Take takes a token from the bucket of key at now
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Bucket is the state of a token bucket, Tokens is how many tokens it held at Updated
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills bucket for the time passed since it was updated and takes a token from it if there is one.
+// Stores keep the returned bucket, a zero bucket is a full one.
+func (bucket Bucket) Take(limit Limit, now time.Time) (Bucket, Result) {
+	burst := float64(limit.Burst)
+	if bucket.Updated.IsZero() {
+		bucket.Tokens = burst
+	} else if elapsed := now.Sub(bucket.Updated); elapsed > 0 {
+		bucket.Tokens = math.Min(burst, bucket.Tokens+float64(elapsed)/float64(limit.interval()))
+	}
+	bucket.Updated = now
+
+	var result Result
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.Tokens) * float64(limit.interval()))
+	}
+	result.Remaining = int(bucket.Tokens)
+	result.ResetAfter = time.Duration((burst - bucket.Tokens) * float64(limit.interval()))
+	return bucket, result
+}
+
+// Full tells whether the bucket is full at now, full buckets do not need to be stored
+func (bucket Bucket) Full(limit Limit, now time.Time) bool {
+	return bucket.Updated.IsZero() || now.Sub(bucket.Updated) >= time.Duration((float64(limit.Burst)-bucket.Tokens)*float64(limit.interval()))
+}
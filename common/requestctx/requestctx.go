@@ -0,0 +1,58 @@
+package requestctx
+
+import (
+	"context"
+	"kirmac-site-backend/domain"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	requestIDKey
+	tenantKey
+)
+
+// WithPrincipal returns a copy of ctx made by principal
+func WithPrincipal(ctx context.Context, principal domain.Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// Principal returns whoever makes the request of ctx, ok is false for anonymous requests
+func Principal(ctx context.Context) (principal domain.Principal, ok bool) {
+	principal, ok = ctx.Value(principalKey).(domain.Principal)
+	return principal, ok
+}
+
+// Actor returns the name of whoever makes the request of ctx, or an empty string when it is anonymous
+func Actor(ctx context.Context) string {
+	principal, _ := Principal(ctx)
+	return principal.Name
+}
+
+// WithRequestID returns a copy of ctx carrying the id of the request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the id of the request of ctx, or an empty string outside a request
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithTenant returns a copy of ctx scoped to tenant
+func WithTenant(ctx context.Context, tenant domain.Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// WithoutTenant returns a copy of ctx scoped to no tenant, for the lookups that have to find rows of every tenant
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey, nil)
+}
+
+// Tenant returns the tenant the request of ctx is scoped to, ok is false when no tenant was resolved
+func Tenant(ctx context.Context) (tenant domain.Tenant, ok bool) {
+	tenant, ok = ctx.Value(tenantKey).(domain.Tenant)
+	return tenant, ok
+}
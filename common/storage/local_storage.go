@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage keeps files in a directory of the local file system
+type LocalStorage struct {
+	directory string
+	publicURL string
+}
+
+// NewLocalStorage creates a storage rooted at directory whose files are served under publicURL
+func NewLocalStorage(directory string, publicURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %v", err)
+	}
+	return &LocalStorage{directory: directory, publicURL: strings.TrimSuffix(publicURL, "/")}, nil
+}
+
+// Put writes content to a temporary file and renames it to key, so readers never see a partial file
+func (localStorage *LocalStorage) Put(ctx context.Context, key string, content io.Reader, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	filePath := localStorage.path(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("unable to create directory for %s: %v", key, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("unable to store %s: %v", key, err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to store %s: %v", key, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("unable to store %s: %v", key, err)
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return fmt.Errorf("unable to store %s: %v", key, err)
+	}
+	if err := os.Rename(file.Name(), filePath); err != nil {
+		return fmt.Errorf("unable to store %s: %v", key, err)
+	}
+	return nil
+}
+
+// Get opens the file stored under key, the content type is derived from its extension
+func (localStorage *LocalStorage) Get(ctx context.Context, key string) (Object, error) {
+	if err := validateKey(key); err != nil {
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	file, err := os.Open(localStorage.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("unable to read %s: %v", key, err)
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return Object{Body: file, ContentType: contentType, Size: info.Size()}, nil
+}
+
+// Delete removes the file stored under key, deleting a missing file is not an error
+func (localStorage *LocalStorage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(localStorage.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unable to delete %s: %v", key, err)
+	}
+	return nil
+}
+
+// URL returns the public address of key
+func (localStorage *LocalStorage) URL(key string) string {
+	return localStorage.publicURL + "/" + key
+}
+
+func (localStorage *LocalStorage) path(key string) string {
+	return filepath.Join(localStorage.directory, filepath.FromSlash(key))
+}
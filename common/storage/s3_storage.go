@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config describes an S3 compatible bucket such as AWS S3, MinIO or Cloudflare R2
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL is the address objects are served from, it defaults to the path style URL of the bucket
+	PublicURL string
+}
+
+// S3Storage keeps files in an S3 compatible bucket, requests use path style URLs and Signature Version 4
+type S3Storage struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Storage creates a storage for the bucket described by config, a nil client uses http.DefaultClient
+func NewS3Storage(config S3Config, client *http.Client) (*S3Storage, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" || config.Region == "" {
+		return nil, fmt.Errorf("S3 bucket and region are required")
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	if config.PublicURL == "" {
+		config.PublicURL = config.Endpoint + "/" + config.Bucket
+	}
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Storage{config: config, client: client, now: time.Now}, nil
+}
+
+// Put uploads content as the object key
+func (s3Storage *S3Storage) Put(ctx context.Context, key string, content io.Reader, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %v", key, err)
+	}
+	res, err := s3Storage.do(ctx, http.MethodPut, key, body, contentType)
+	if err != nil {
+		return fmt.Errorf("unable to store %s: %v", key, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to store %s: %s", key, responseError(res))
+	}
+	return nil
+}
+
+// Get downloads the object key
+func (s3Storage *S3Storage) Get(ctx context.Context, key string) (Object, error) {
+	if err := validateKey(key); err != nil {
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	res, err := s3Storage.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return Object{}, fmt.Errorf("unable to read %s: %v", key, err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return Object{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return Object{}, fmt.Errorf("unable to read %s: %s", key, responseError(res))
+	}
+	return Object{Body: res.Body, ContentType: res.Header.Get("Content-Type"), Size: res.ContentLength}, nil
+}
+
+// Delete removes the object key, deleting a missing object is not an error
+func (s3Storage *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	res, err := s3Storage.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return fmt.Errorf("unable to delete %s: %v", key, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unable to delete %s: %s", key, responseError(res))
+	}
+	return nil
+}
+
+// URL returns the public address of key
+func (s3Storage *S3Storage) URL(key string) string {
+	return s3Storage.config.PublicURL + "/" + key
+}
+
+func (s3Storage *S3Storage) do(ctx context.Context, method string, key string, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s3Storage.config.Endpoint+"/"+s3Storage.config.Bucket+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s3Storage.sign(req, body)
+	return s3Storage.client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers for an unchunked payload to req
+func (s3Storage *S3Storage) sign(req *http.Request, body []byte) {
+	now := s3Storage.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s3Storage.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s3Storage.config.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s3Storage.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Storage.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func responseError(res *http.Response) string {
+	message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Sprintf("%s %s", res.Status, strings.TrimSpace(string(message)))
+}
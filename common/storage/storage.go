@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned when no object is stored under the key
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps uploaded files under slash separated keys such as properties/1/photo.jpg
+type Storage interface {
+	Put(ctx context.Context, key string, content io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns the public address of key
+	URL(key string) string
+}
+
+// Object is a stored file, Body must be closed by the caller
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// validateKey rejects keys that could escape the storage root or that no backend can store
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-", r)) {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"net/http"
+)
+
+type AgentController struct {
+	agentService services.IAgentService
+}
+
+func NewAgentController(agentService services.IAgentService) *AgentController {
+	return &AgentController{
+		agentService: agentService,
+	}
+}
+
+func (a *AgentController) RegisterRoutes(app *fiber.App) {
+	app.Get("/agents", a.getAllAgents)
+	app.Get("/agents/:id", a.getAgentById)
+	app.Get("/agents/:id/properties", a.getAgentProperties)
+	app.Post("/agents", a.addAgent)
+	app.Put("/agents/:id", a.updateAgent)
+	app.Delete("/agents/:id", a.deleteAgent)
+}
+
+func (a *AgentController) getAllAgents(c *fiber.Ctx) error {
+	agents, err := a.agentService.GetAllAgents(c.UserContext())
+	if err != nil {
+		return err
+	}
+	if agents == nil {
+		agents = []domain.Agent{}
+	}
+	return c.JSON(response.AgentListResponse{Data: agents})
+}
+
+func (a *AgentController) getAgentById(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	agent, err := a.agentService.GetAgentById(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(agent)
+}
+
+func (a *AgentController) getAgentProperties(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	criteria, err := parsePropertyCriteria(c)
+	if err != nil {
+		return err
+	}
+	page, err := a.agentService.GetAgentProperties(c.UserContext(), id, criteria)
+	if err != nil {
+		return err
+	}
+	return c.JSON(newPropertyListResponse(c, page))
+}
+
+func (a *AgentController) addAgent(c *fiber.Ctx) error {
+	var agent model.AgentCreate
+	if err := c.BodyParser(&agent); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	createdAgent, err := a.agentService.AddAgent(c.UserContext(), agent)
+	if err != nil {
+		return err
+	}
+	c.Location(fmt.Sprintf("/agents/%d", createdAgent.ID))
+	return c.Status(http.StatusCreated).JSON(createdAgent)
+}
+
+func (a *AgentController) updateAgent(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	var agent model.AgentCreate
+	if err := c.BodyParser(&agent); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	updatedAgent, err := a.agentService.UpdateAgent(c.UserContext(), id, agent)
+	if err != nil {
+		return err
+	}
+	return c.JSON(updatedAgent)
+}
+
+func (a *AgentController) deleteAgent(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	deleted, err := a.agentService.DeleteById(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"deleted": deleted})
+}
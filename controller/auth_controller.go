@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"net/http"
+)
+
+type AuthController struct {
+	authService services.IAuthService
+}
+
+func NewAuthController(authService services.IAuthService) *AuthController {
+	return &AuthController{
+		authService: authService,
+	}
+}
+
+func (a *AuthController) RegisterRoutes(app *fiber.App) {
+	app.Post("/auth/login", a.login)
+	app.Post("/auth/refresh", a.refresh)
+	app.Get("/auth/api-keys", requireAuthentication, a.getAPIKeys)
+	app.Post("/auth/api-keys", a.createAPIKey)
+	app.Delete("/auth/api-keys/:id", a.revokeAPIKey)
+}
+
+// login signs a user in with a username and password and returns an access and a refresh token
+func (a *AuthController) login(c *fiber.Ctx) error {
+	var credentials model.Credentials
+	if err := c.BodyParser(&credentials); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	tokens, err := a.authService.Login(c.UserContext(), credentials)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(tokens)
+}
+
+// refresh exchanges a refresh token for a new pair of tokens
+func (a *AuthController) refresh(c *fiber.Ctx) error {
+	var refresh model.TokenRefresh
+	if err := c.BodyParser(&refresh); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	tokens, err := a.authService.Refresh(c.UserContext(), refresh.RefreshToken)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(tokens)
+}
+
+func (a *AuthController) getAPIKeys(c *fiber.Ctx) error {
+	keys, err := a.authService.GetAPIKeys(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(response.APIKeyListResponse{Data: keys})
+}
+
+// createAPIKey creates an API key, the response is the only time the key is shown
+func (a *AuthController) createAPIKey(c *fiber.Ctx) error {
+	var key model.APIKeyCreate
+	if err := c.BodyParser(&key); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	created, err := a.authService.CreateAPIKey(c.UserContext(), key)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(http.StatusCreated).JSON(created)
+}
+
+func (a *AuthController) revokeAPIKey(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	revoked, err := a.authService.RevokeAPIKey(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(revoked)
+}
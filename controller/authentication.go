@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"strings"
+)
+
+// APIKeyHeader carries the API key of server to server integrations
+const APIKeyHeader = "X-API-Key"
+
+// publicWritePaths are the writes that sign a client in, so they cannot require credentials
+var publicWritePaths = map[string]bool{
+	"/auth/login":   true,
+	"/auth/refresh": true,
+}
+
+// Authentication authenticates a request by its bearer token or API key and records whoever it belongs to as the actor.
+// Reads stay public, every other request without credentials is unauthorized.
+func Authentication(authService services.IAuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := authenticate(c, authService)
+		if err != nil {
+			return err
+		}
+		if principal != nil {
+			c.SetUserContext(requestctx.WithPrincipal(c.UserContext(), *principal))
+		} else if !isSafeMethod(c.Method()) && !publicWritePaths[c.Path()] {
+			return fmt.Errorf("%w: send a bearer token or an %s header", domain.ErrUnauthorized, APIKeyHeader)
+		}
+		return c.Next()
+	}
+}
+
+// authenticate checks the credentials of a request, a request without any has no principal
+func authenticate(c *fiber.Ctx, authService services.IAuthService) (*domain.Principal, error) {
+	var principal domain.Principal
+	var err error
+	if key := c.Get(APIKeyHeader); key != "" {
+		principal, err = authService.AuthenticateAPIKey(c.UserContext(), key)
+	} else if authorization := c.Get(fiber.HeaderAuthorization); authorization != "" {
+		scheme, accessToken, found := strings.Cut(authorization, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return nil, fmt.Errorf("%w: the Authorization header must be a Bearer token", domain.ErrUnauthorized)
+		}
+		principal, err = authService.Authenticate(c.UserContext(), strings.TrimSpace(accessToken))
+	} else {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
+
+// requireAuthentication guards reads that are not public
+func requireAuthentication(c *fiber.Ctx) error {
+	if _, ok := requestctx.Principal(c.UserContext()); !ok {
+		return fmt.Errorf("%w: send a bearer token or an %s header", domain.ErrUnauthorized, APIKeyHeader)
+	}
+	return c.Next()
+}
+
+func isSafeMethod(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
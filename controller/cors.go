@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"kirmac-site-backend/common/app"
+	"kirmac-site-backend/services"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentialHeaders are the request headers that make a request part of the admin API
+var credentialHeaders = map[string]bool{
+	strings.ToLower(fiber.HeaderAuthorization): true,
+	strings.ToLower(APIKeyHeader):              true,
+}
+
+// CORS answers preflights and sets the CORS headers with the public policy for anonymous reads and the admin policy
+// for requests that write, sign in or carry credentials. The origins of the tenant serving the host read from
+// hostHeader replace the configured ones when it sets any. It runs before the other middleware, so the responses
+// they refuse requests with carry the CORS headers too.
+func CORS(config app.CorsConfig, tenantService services.ITenantService, hostHeader string) fiber.Handler {
+	public := config.CorsPolicy
+	admin := config.AdminPolicy()
+	// handlers holds a handler for each policy and list of origins, keyed by both
+	var handlers sync.Map
+	return func(c *fiber.Ctx) error {
+		group, policy := "public", public
+		if isAdminRequest(c) {
+			group, policy = "admin", admin
+		}
+		if origins := tenantOrigins(c, tenantService, hostHeader); len(origins) > 0 {
+			policy.AllowOrigins = origins
+		}
+		key := group + " " + strings.Join(policy.AllowOrigins, ",")
+		handler, ok := handlers.Load(key)
+		if !ok {
+			handler, _ = handlers.LoadOrStore(key, newCorsHandler(policy))
+		}
+		return handler.(fiber.Handler)(c)
+	}
+}
+
+// tenantOrigins are the CORS origins of the tenant serving the host of a cross origin request. Credentials are not
+// checked yet, so the tenant is found by the host alone; without a tenant the configured origins apply. The tenant
+// service finds it among its cached tenants, so requests the rate limits refuse next cost no query.
+func tenantOrigins(c *fiber.Ctx, tenantService services.ITenantService, hostHeader string) []string {
+	if c.Get(fiber.HeaderOrigin) == "" {
+		return nil
+	}
+	tenant, err := tenantService.ResolveTenant(c.UserContext(), c.Get(hostHeader))
+	if err != nil {
+		return nil
+	}
+	return tenant.CorsOrigins
+}
+
+// newCorsHandler applies policy, a policy without origins sets no CORS headers so browsers refuse cross origin calls
+func newCorsHandler(policy app.CorsPolicy) fiber.Handler {
+	if len(policy.AllowOrigins) == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	maxAge := int(time.Duration(policy.MaxAge) / time.Second)
+	return cors.New(cors.Config{
+		AllowOrigins:     strings.Join(policy.AllowOrigins, ","),
+		AllowMethods:     strings.Join(policy.AllowMethods, ","),
+		AllowHeaders:     strings.Join(policy.AllowHeaders, ","),
+		ExposeHeaders:    strings.Join(policy.ExposeHeaders, ","),
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           maxAge,
+	})
+}
+
+// isAdminRequest tells whether a request, or the request a preflight asks about, writes, signs in or carries credentials
+func isAdminRequest(c *fiber.Ctx) bool {
+	if strings.HasPrefix(c.Path(), "/auth/") {
+		return true
+	}
+	if c.Method() == fiber.MethodOptions && c.Get(fiber.HeaderAccessControlRequestMethod) != "" {
+		for _, header := range strings.Split(c.Get(fiber.HeaderAccessControlRequestHeaders), ",") {
+			if credentialHeaders[strings.ToLower(strings.TrimSpace(header))] {
+				return true
+			}
+		}
+		return !isSafeMethod(c.Get(fiber.HeaderAccessControlRequestMethod))
+	}
+	return !isSafeMethod(c.Method()) || c.Get(fiber.HeaderAuthorization) != "" || c.Get(APIKeyHeader) != ""
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/gofiber/fiber/v3/log"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+)
+
+const problemContentType = "application/problem+json"
+
+// ErrorHandler turns errors returned by handlers into problem details responses with a matching status code
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := response.Problem{
+		Type:     "about:blank",
+		Instance: c.OriginalURL(),
+	}
+
+	var validationError *domain.ValidationError
+	var fiberError *fiber.Error
+	switch {
+	case errors.As(err, &validationError):
+		problem.Status = fiber.StatusBadRequest
+		problem.Detail = "One or more fields are invalid"
+		problem.Errors = validationError.Fields
+	case errors.Is(err, domain.ErrValidation):
+		problem.Status = fiber.StatusBadRequest
+		problem.Detail = err.Error()
+	case errors.Is(err, domain.ErrNotFound):
+		problem.Status = fiber.StatusNotFound
+		problem.Detail = err.Error()
+	case errors.Is(err, domain.ErrUnauthorized):
+		problem.Status = fiber.StatusUnauthorized
+		problem.Detail = err.Error()
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="kirmac"`)
+	case errors.Is(err, domain.ErrForbidden):
+		problem.Status = fiber.StatusForbidden
+		problem.Detail = err.Error()
+	case errors.Is(err, domain.ErrConflict):
+		problem.Status = fiber.StatusConflict
+		problem.Detail = err.Error()
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		problem.Status = fiber.StatusPreconditionFailed
+		problem.Detail = err.Error()
+	case errors.Is(err, domain.ErrTooLarge):
+		problem.Status = fiber.StatusRequestEntityTooLarge
+		problem.Detail = err.Error()
+	case errors.Is(err, domain.ErrTooManyRequests):
+		problem.Status = fiber.StatusTooManyRequests
+		problem.Detail = err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		problem.Status = fiber.StatusGatewayTimeout
+		problem.Detail = "The request took too long to complete"
+	case errors.Is(err, domain.ErrUnavailable):
+		problem.Status = fiber.StatusServiceUnavailable
+		problem.Detail = "The service is temporarily unavailable, please try again later"
+	case errors.As(err, &fiberError):
+		problem.Status = fiberError.Code
+		problem.Detail = fiberError.Message
+	default:
+		problem.Status = fiber.StatusInternalServerError
+		problem.Detail = "An unexpected error occurred"
+	}
+	problem.Title = utils.StatusMessage(problem.Status)
+
+	if problem.Status >= fiber.StatusInternalServerError {
+		log.Errorf("%s %s failed in request %s: %v", c.Method(), c.OriginalURL(), requestctx.RequestID(c.UserContext()), err)
+	}
+
+	c.Status(problem.Status)
+	return c.JSON(problem, problemContentType)
+}
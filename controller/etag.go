@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/domain"
+	"strconv"
+	"strings"
+)
+
+// etag is the strong entity tag of a property version
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion returns the version required by the If-Match header, or 0 when any version is accepted
+func ifMatchVersion(c *fiber.Ctx) (int64, error) {
+	ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+	if strings.Contains(ifMatch, ",") {
+		return 0, invalidIfMatch("must contain a single entity tag")
+	}
+	if strings.HasPrefix(ifMatch, "W/") {
+		// weak entity tags never match with If-Match
+		return 0, fmt.Errorf("weak entity tag in If-Match: %w", domain.ErrPreconditionFailed)
+	}
+	version, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil || version <= 0 || len(ifMatch) < 2 || ifMatch[0] != '"' || ifMatch[len(ifMatch)-1] != '"' {
+		return 0, invalidIfMatch(fmt.Sprintf("%s is not an entity tag of this API", ifMatch))
+	}
+	return version, nil
+}
+
+// notModified reports whether the If-None-Match header already matches the current version
+func notModified(c *fiber.Ctx, version int64) bool {
+	current := etag(version)
+	for _, tag := range strings.Split(c.Get(fiber.HeaderIfNoneMatch), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidIfMatch(message string) error {
+	return domain.NewValidationError(domain.FieldError{Field: fiber.HeaderIfMatch, Message: message})
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence/common"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ImageController struct {
+	imageService services.IImageService
+}
+
+func NewImageController(imageService services.IImageService) *ImageController {
+	return &ImageController{
+		imageService: imageService,
+	}
+}
+
+func (i *ImageController) RegisterRoutes(app *fiber.App) {
+	app.Get("/properties/:id/images", i.getImages)
+	app.Post("/properties/:id/images", i.addImage)
+	app.Put("/properties/:id/images", i.reorderImages)
+	app.Patch("/properties/:id/images/:imageId", i.updateImage)
+	app.Delete("/properties/:id/images/:imageId", i.deleteImage)
+	app.Get("/images/*", i.serveImage)
+}
+
+func (i *ImageController) getImages(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	images, err := i.imageService.GetImages(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(response.ImageListResponse{Data: images})
+}
+
+// addImage uploads a file sent as multipart/form-data in the image field, with optional alt, caption and cover fields,
+// or adds an image hosted elsewhere from a JSON body with its url
+func (i *ImageController) addImage(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	var property domain.Property
+	if strings.HasPrefix(mediaType(c.Get(fiber.HeaderContentType)), "multipart/") {
+		fileHeader, err := c.FormFile("image")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Send the image as multipart/form-data in the image field")
+		}
+		cover, _ := strconv.ParseBool(c.FormValue("cover"))
+		file, err := fileHeader.Open()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Unable to read the uploaded image")
+		}
+		defer file.Close()
+		image := model.ImageCreate{Alt: c.FormValue("alt"), Caption: c.FormValue("caption"), IsCover: cover}
+		property, err = i.imageService.UploadImage(c.UserContext(), id, file, image, version)
+		if err != nil {
+			return err
+		}
+	} else {
+		var image model.ImageCreate
+		if err := c.BodyParser(&image); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+		property, err = i.imageService.AddImage(c.UserContext(), id, image, version)
+		if err != nil {
+			return err
+		}
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.Status(http.StatusCreated).JSON(property)
+}
+
+func (i *ImageController) updateImage(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	imageID, err := parseImageId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	var update model.ImageUpdate
+	if err := c.BodyParser(&update); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	property, err := i.imageService.UpdateImage(c.UserContext(), id, imageID, update, version)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.JSON(property)
+}
+
+func (i *ImageController) reorderImages(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	var order model.ImageOrder
+	if err := c.BodyParser(&order); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	property, err := i.imageService.ReorderImages(c.UserContext(), id, order.ImageIDs, version)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.JSON(property)
+}
+
+func (i *ImageController) deleteImage(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	imageID, err := parseImageId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	property, err := i.imageService.DeleteImage(c.UserContext(), id, imageID, version)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.JSON(property)
+}
+
+// serveImage streams a stored image, stored names are random so the response never changes
+func (i *ImageController) serveImage(c *fiber.Ctx) error {
+	object, err := i.imageService.OpenImage(c.UserContext(), c.Params("*"))
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, object.ContentType)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendStream(object.Body, int(object.Size))
+}
+
+func parseImageId(c *fiber.Ctx) (int64, error) {
+	imageID, err := strconv.ParseInt(c.Params("imageId"), 10, 64)
+	if err != nil || imageID <= 0 {
+		return 0, domain.NewValidationError(domain.FieldError{Field: "image_id", Message: common.INVALID_ID})
+	}
+	return imageID, nil
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/common/app"
+	"kirmac-site-backend/common/ratelimit"
+	"kirmac-site-backend/services"
+)
+
+// MiddlewareConfig is what the middleware shared by every route needs, a nil RateLimitStore keeps the rate limits
+// in memory
+type MiddlewareConfig struct {
+	AuthService    services.IAuthService
+	TenantService  services.ITenantService
+	Tenancy        app.TenancyConfig
+	Cors           app.CorsConfig
+	RateLimit      app.RateLimitConfig
+	RateLimitStore ratelimit.Store
+}
+
+// UseMiddleware installs the middleware shared by every route, in the order it has to run, before the controllers
+// register their routes
+func UseMiddleware(fiberApp *fiber.App, config MiddlewareConfig) {
+	rateLimitStore := config.RateLimitStore
+	if rateLimitStore == nil {
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+	fiberApp.Use(RequestID)
+	fiberApp.Use(CORS(config.Cors, config.TenantService, config.Tenancy.HostHeader))
+	// rate limits by address come before authentication so guessing credentials is limited too
+	fiberApp.Use(RateLimit(config.RateLimit, rateLimitStore))
+	fiberApp.Use(Authentication(config.AuthService))
+	fiberApp.Use(APIKeyRateLimit(config.RateLimit, rateLimitStore))
+	fiberApp.Use(Tenancy(config.TenantService, config.Tenancy.HostHeader))
+}
@@ -1,12 +1,21 @@
 package controller
 
 import (
+	"bufio"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v3/log"
+	"kirmac-site-backend/common/geojson"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence/common"
 	"kirmac-site-backend/services"
 	"kirmac-site-backend/services/model"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 type PropertyController struct {
@@ -20,32 +29,149 @@ func NewPropertyController(propertyService services.IPropertyService) *PropertyC
 }
 
 func (p *PropertyController) RegisterRoutes(app *fiber.App) {
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-	}))
 	app.Get("/properties", p.getAllProperties)
+	app.Get("/properties.geojson", p.getPropertiesGeoJSON)
+	app.Get("/properties/search", p.searchProperties)
+	app.Get("/properties/nearby", p.getNearbyProperties)
+	app.Get("/properties/within", p.getPropertiesWithin)
+	app.Get("/properties/trash", p.getDeletedProperties)
 	app.Get("/properties/:id", p.getPropertyById)
 	app.Post("/properties", p.addProperty)
 	app.Put("/properties/:id", p.updateProperty)
+	app.Patch("/properties/:id", p.patchProperty)
 	app.Delete("/properties/:id", p.deleteProperty)
+	app.Get("/properties/:id/transitions", p.getStatusHistory)
+	app.Post("/properties/:id/transitions", p.transitionProperty)
+	app.Post("/properties/:id/restore", p.restoreProperty)
+	app.Get("/properties/:id/history", p.getPropertyHistory)
 }
 
 func (p *PropertyController) getAllProperties(c *fiber.Ctx) error {
-	properties, err := p.propertyService.GetAllProperties()
+	criteria, err := parsePropertyCriteria(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Unable to retrieve properties",
-		})
+		return err
 	}
-	return c.JSON(properties)
+	page, err := p.propertyService.GetAllProperties(c.UserContext(), criteria)
+	if err != nil {
+		return err
+	}
+	return c.JSON(newPropertyListResponse(c, page))
+}
+
+// getNearbyProperties lists the properties within radius_km of lat and lng, nearest first, the usual filters apply
+func (p *PropertyController) getNearbyProperties(c *fiber.Ctx) error {
+	criteria, err := parsePropertyCriteria(c)
+	if err != nil {
+		return err
+	}
+	coordinates, err := queryFloats(c, "lat", "lng", "radius_km")
+	if err != nil {
+		return err
+	}
+	criteria.Near = &domain.GeoRadius{
+		Center:   domain.GeoPoint{Latitude: coordinates[0], Longitude: coordinates[1]},
+		RadiusKm: coordinates[2],
+	}
+	page, err := p.propertyService.GetNearbyProperties(c.UserContext(), criteria)
+	if err != nil {
+		return err
+	}
+	results := page.Results
+	if results == nil {
+		results = []domain.PropertyDistance{}
+	}
+	return c.JSON(response.NearbyPropertiesResponse{
+		Data:       results,
+		TotalCount: page.TotalCount,
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		Links:      pageLinks(c, page.Page, page.PageSize, page.TotalCount),
+	})
+}
+
+// getPropertiesWithin lists the properties inside a bounding box, min_lng is greater than max_lng for a box crossing the antimeridian
+func (p *PropertyController) getPropertiesWithin(c *fiber.Ctx) error {
+	criteria, err := parsePropertyCriteria(c)
+	if err != nil {
+		return err
+	}
+	corners, err := queryFloats(c, "min_lat", "min_lng", "max_lat", "max_lng")
+	if err != nil {
+		return err
+	}
+	criteria.Within = &domain.BoundingBox{MinLatitude: corners[0], MinLongitude: corners[1], MaxLatitude: corners[2], MaxLongitude: corners[3]}
+	page, err := p.propertyService.GetAllProperties(c.UserContext(), criteria)
+	if err != nil {
+		return err
+	}
+	return c.JSON(newPropertyListResponse(c, page))
+}
+
+// getPropertiesGeoJSON streams the properties with coordinates matching the list filters as a GeoJSON FeatureCollection,
+// with cluster=true the properties are grouped into a grid sized for zoom
+func (p *PropertyController) getPropertiesGeoJSON(c *fiber.Ctx) error {
+	criteria, err := parsePropertyCriteria(c)
+	if err != nil {
+		return err
+	}
+	var export services.GeoJSONExport
+	if c.QueryBool("cluster") {
+		if c.Query("zoom") == "" {
+			return domain.NewValidationError(domain.FieldError{Field: "zoom", Message: "is required"})
+		}
+		zoom, err := queryInt(c, "zoom")
+		if err != nil {
+			return err
+		}
+		export, err = p.propertyService.ExportGeoJSONClusters(c.UserContext(), criteria, zoom)
+		if err != nil {
+			return err
+		}
+	} else {
+		export, err = p.propertyService.ExportGeoJSON(c.UserContext(), criteria)
+		if err != nil {
+			return err
+		}
+	}
+
+	method, path := c.Method(), c.OriginalURL()
+	c.Set(fiber.HeaderContentType, geojson.ContentType)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := export(w); err != nil {
+			log.Errorf("%s %s failed while streaming: %v", method, path, err)
+		}
+	})
+	return nil
+}
+
+func (p *PropertyController) searchProperties(c *fiber.Ctx) error {
+	page, err := queryInt(c, "page")
+	if err != nil {
+		return err
+	}
+	pageSize, err := queryInt(c, "page_size")
+	if err != nil {
+		return err
+	}
+	searchPage, err := p.propertyService.SearchProperties(c.UserContext(), c.Query("q"), page, pageSize)
+	if err != nil {
+		return err
+	}
+	return c.JSON(newPropertySearchResponse(c, searchPage))
 }
 
 func (p *PropertyController) getPropertyById(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	property, err := p.propertyService.GetPropertyById(id)
+	id, err := parseId(c)
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
+	}
+	property, err := p.propertyService.GetPropertyById(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	if notModified(c, property.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 	return c.JSON(property)
 }
@@ -53,38 +179,333 @@ func (p *PropertyController) getPropertyById(c *fiber.Ctx) error {
 func (p *PropertyController) addProperty(c *fiber.Ctx) error {
 	var property model.PropertyCreate
 	if err := c.BodyParser(&property); err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
-	err := p.propertyService.AddProperty(property)
+	createdProperty, err := p.propertyService.AddProperty(c.UserContext(), property)
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
-	return c.SendStatus(http.StatusCreated)
+	c.Location(fmt.Sprintf("/properties/%d", createdProperty.ID))
+	c.Set(fiber.HeaderETag, etag(createdProperty.Version))
+	return c.Status(http.StatusCreated).JSON(createdProperty)
 }
 
 func (p *PropertyController) updateProperty(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
 	var property model.PropertyCreate
 	if err := c.BodyParser(&property); err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	updatedProperty, err := p.propertyService.UpdateProperty(c.UserContext(), id, property, version)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(updatedProperty.Version))
+	return c.JSON(updatedProperty)
+}
+
+// patchProperty accepts a JSON Merge Patch (application/merge-patch+json or application/json)
+// or a JSON Patch (application/json-patch+json)
+func (p *PropertyController) patchProperty(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	var patchType string
+	switch mediaType(c.Get(fiber.HeaderContentType)) {
+	case "application/merge-patch+json", fiber.MIMEApplicationJSON:
+		patchType = model.MergePatch
+	case "application/json-patch+json":
+		patchType = model.JSONPatch
+	default:
+		c.Set("Accept-Patch", "application/merge-patch+json, application/json-patch+json")
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "Use application/merge-patch+json or application/json-patch+json")
+	}
+	property, err := p.propertyService.PatchProperty(c.UserContext(), id, model.PropertyPatch{
+		Type:     patchType,
+		Document: c.Body(),
+	}, version)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.JSON(property)
+}
+
+// getStatusHistory shows the status of a property, the statuses it can move to and its past transitions
+func (p *PropertyController) getStatusHistory(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	history, err := p.propertyService.GetStatusHistory(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(history)
+}
+
+// transitionProperty moves a property to the status in the body, an invalid transition is a conflict
+func (p *PropertyController) transitionProperty(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	var transition model.StatusTransition
+	if err := c.BodyParser(&transition); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	property, err := p.propertyService.TransitionProperty(c.UserContext(), id, transition, version)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.JSON(property)
+}
+
+// getDeletedProperties lists the properties in the trash, most recently deleted first
+func (p *PropertyController) getDeletedProperties(c *fiber.Ctx) error {
+	page, err := queryInt(c, "page")
+	if err != nil {
+		return err
+	}
+	pageSize, err := queryInt(c, "page_size")
+	if err != nil {
+		return err
+	}
+	deleted, err := p.propertyService.GetDeletedProperties(c.UserContext(), page, pageSize)
+	if err != nil {
+		return err
+	}
+	return c.JSON(newPropertyListResponse(c, deleted))
+}
+
+// restoreProperty takes a property out of the trash
+func (p *PropertyController) restoreProperty(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	property, err := p.propertyService.RestoreProperty(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(property.Version))
+	return c.JSON(property)
+}
+
+// getPropertyHistory lists the audit entries of a property, newest first
+func (p *PropertyController) getPropertyHistory(c *fiber.Ctx) error {
+	id, err := parseId(c)
+	if err != nil {
+		return err
 	}
+	page, err := queryInt(c, "page")
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
-	err = p.propertyService.UpdateProperty(id, property)
+	pageSize, err := queryInt(c, "page_size")
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
-	return c.SendStatus(http.StatusOK)
+	history, err := p.propertyService.GetPropertyHistory(c.UserContext(), id, page, pageSize)
+	if err != nil {
+		return err
+	}
+	return c.JSON(response.AuditListResponse{
+		Data:       history.Entries,
+		TotalCount: history.TotalCount,
+		Page:       history.Page,
+		PageSize:   history.PageSize,
+		Links:      pageLinks(c, history.Page, history.PageSize, history.TotalCount),
+	})
 }
 
 func (p *PropertyController) deleteProperty(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	deleted, err := p.propertyService.DeleteById(id)
+	id, err := parseId(c)
+	if err != nil {
+		return err
+	}
+	version, err := ifMatchVersion(c)
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
+	}
+	deleted, err := p.propertyService.DeleteById(c.UserContext(), id, version)
+	if err != nil {
+		return err
 	}
 	return c.JSON(fiber.Map{"deleted": deleted})
 }
+
+func mediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+func parseId(c *fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, domain.NewValidationError(domain.FieldError{Field: "id", Message: common.INVALID_ID})
+	}
+	return id, nil
+}
+
+// parsePropertyCriteria reads the list filters, sorting and paging from the query string
+func parsePropertyCriteria(c *fiber.Ctx) (domain.PropertyCriteria, error) {
+	criteria := domain.PropertyCriteria{
+		Location:      c.Query("location"),
+		AgentName:     c.Query("agent_name"),
+		City:          c.Query("city"),
+		SortField:     c.Query("sort"),
+		SortDirection: c.Query("order"),
+	}
+	filters := map[string]**int{
+		"min_price":       &criteria.MinPrice,
+		"max_price":       &criteria.MaxPrice,
+		"min_bedrooms":    &criteria.MinBedrooms,
+		"min_bathrooms":   &criteria.MinBathrooms,
+		"min_square_feet": &criteria.MinSquareFeet,
+		"max_square_feet": &criteria.MaxSquareFeet,
+	}
+	for name, target := range filters {
+		if c.Query(name) == "" {
+			continue
+		}
+		value, err := queryInt(c, name)
+		if err != nil {
+			return criteria, err
+		}
+		*target = &value
+	}
+	if c.Query("agent_id") != "" {
+		agentID, err := queryInt(c, "agent_id")
+		if err != nil {
+			return criteria, err
+		}
+		id := int64(agentID)
+		criteria.AgentID = &id
+	}
+	criteria.Statuses = parseStatuses(c.Query("status"))
+	var err error
+	if criteria.Page, err = queryInt(c, "page"); err != nil {
+		return criteria, err
+	}
+	if criteria.PageSize, err = queryInt(c, "page_size"); err != nil {
+		return criteria, err
+	}
+	return criteria, nil
+}
+
+// parseStatuses reads a comma separated list of statuses, all stands for every status
+func parseStatuses(value string) []domain.PropertyStatus {
+	if value == "all" {
+		return domain.PropertyStatuses
+	}
+	var statuses []domain.PropertyStatus
+	for _, status := range strings.Split(value, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, domain.PropertyStatus(status))
+		}
+	}
+	return statuses
+}
+
+func queryInt(c *fiber.Ctx, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, domain.NewValidationError(domain.FieldError{Field: name, Message: fmt.Sprintf("%q is not a number", value)})
+	}
+	return parsed, nil
+}
+
+// queryFloats reads required decimal query parameters in the order of names
+func queryFloats(c *fiber.Ctx, names ...string) ([]float64, error) {
+	values := make([]float64, len(names))
+	var fieldErrors []domain.FieldError
+	for i, name := range names {
+		value := c.Query(name)
+		parsed, err := strconv.ParseFloat(value, 64)
+		switch {
+		case value == "":
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: name, Message: "is required"})
+		case err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0):
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: name, Message: fmt.Sprintf("%q is not a number", value)})
+		}
+		values[i] = parsed
+	}
+	if len(fieldErrors) > 0 {
+		return nil, domain.NewValidationError(fieldErrors...)
+	}
+	return values, nil
+}
+
+// newPropertyListResponse wraps a page of properties with its paging metadata and links
+func newPropertyListResponse(c *fiber.Ctx, page domain.PropertyPage) response.PropertyListResponse {
+	properties := page.Properties
+	if properties == nil {
+		properties = []domain.Property{}
+	}
+	return response.PropertyListResponse{
+		Data:       properties,
+		TotalCount: page.TotalCount,
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		Links:      pageLinks(c, page.Page, page.PageSize, page.TotalCount),
+	}
+}
+
+// newPropertySearchResponse wraps search results in the envelope of the property list, with the query and the rank and
+// highlights of every match
+func newPropertySearchResponse(c *fiber.Ctx, page domain.PropertySearchPage) response.PropertySearchResponse {
+	results := page.Results
+	if results == nil {
+		results = []domain.PropertySearchResult{}
+	}
+	return response.PropertySearchResponse{
+		Query:      page.Query,
+		Data:       results,
+		TotalCount: page.TotalCount,
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		Links:      pageLinks(c, page.Page, page.PageSize, page.TotalCount),
+	}
+}
+
+// pageLinks links to the current page and to its neighbours when they exist
+func pageLinks(c *fiber.Ctx, page int, pageSize int, totalCount int64) response.PageLinks {
+	links := response.PageLinks{Self: pageLink(c, page, pageSize)}
+	if int64(page)*int64(pageSize) < totalCount {
+		links.Next = pageLink(c, page+1, pageSize)
+	}
+	if page > 1 {
+		links.Prev = pageLink(c, page-1, pageSize)
+	}
+	return links
+}
+
+// pageLink builds a link to the given page keeping every other query parameter of the request
+func pageLink(c *fiber.Ctx, page int, pageSize int) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Set("page", strconv.Itoa(page))
+	query.Set("page_size", strconv.Itoa(pageSize))
+	return c.Path() + "?" + query.Encode()
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v3/log"
+	"kirmac-site-backend/common/app"
+	"kirmac-site-backend/common/ratelimit"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers telling clients about their rate limit
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimit takes a token from the bucket of the IP address of the client for the route group of the request and
+// refuses the request with 429 when it is empty. Sign in attempts, writes and the public reads have separate limits.
+// It runs before Authentication so every request, whatever credentials it claims, counts against its address.
+func RateLimit(config app.RateLimitConfig, store ratelimit.Store) fiber.Handler {
+	limits := rateLimits(config)
+	return func(c *fiber.Ctx) error {
+		return limitRequest(c, config, store, limits, "ip:"+clientIP(c, config.IPHeader))
+	}
+}
+
+// APIKeyRateLimit also limits the requests made with an API key by the bucket of the key, so integrations sharing
+// an address are limited on their own. It runs after Authentication, so only verified keys get a bucket.
+func APIKeyRateLimit(config app.RateLimitConfig, store ratelimit.Store) fiber.Handler {
+	limits := rateLimits(config)
+	return func(c *fiber.Ctx) error {
+		principal, ok := requestctx.Principal(c.UserContext())
+		if !ok || principal.KeyID == 0 {
+			return c.Next()
+		}
+		return limitRequest(c, config, store, limits, "key:"+strconv.FormatInt(principal.KeyID, 10))
+	}
+}
+
+func rateLimits(config app.RateLimitConfig) map[string]ratelimit.Limit {
+	return map[string]ratelimit.Limit{
+		"public": config.Public.Limit(),
+		"write":  config.Write.Limit(),
+		"login":  config.Login.Limit(),
+	}
+}
+
+// limitRequest takes a token from the bucket of client for the route group of the request. The headers report the
+// bucket with the fewest tokens left when several limiters apply. When the store fails the request is let through,
+// so the limiter never takes the API down.
+func limitRequest(c *fiber.Ctx, config app.RateLimitConfig, store ratelimit.Store, limits map[string]ratelimit.Limit, client string) error {
+	group := rateLimitGroup(c)
+	limit := limits[group]
+	if !config.Enabled || !limit.Enabled() {
+		return c.Next()
+	}
+	result, err := store.Take(c.UserContext(), group+" "+client, limit, time.Now())
+	if err != nil {
+		log.Warnf("unable to check the rate limit of request %s, letting it through: %v", requestctx.RequestID(c.UserContext()), err)
+		return c.Next()
+	}
+	if remaining, err := strconv.Atoi(string(c.Response().Header.Peek(HeaderRateLimitRemaining))); err != nil || result.Remaining < remaining || !result.Allowed {
+		c.Set(HeaderRateLimitLimit, strconv.Itoa(limit.Burst))
+		c.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		c.Set(HeaderRateLimitReset, strconv.Itoa(seconds(result.ResetAfter)))
+		c.Set(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, seconds(limit.Period), limit.Burst))
+	}
+	if !result.Allowed {
+		retryAfter := seconds(result.RetryAfter)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return fmt.Errorf("%w: try again in %d seconds", domain.ErrTooManyRequests, retryAfter)
+	}
+	return c.Next()
+}
+
+// rateLimitGroup is the route group whose limit applies to a request
+func rateLimitGroup(c *fiber.Ctx) string {
+	if c.Method() == fiber.MethodPost && c.Path() == "/auth/login" {
+		return "login"
+	}
+	if !isSafeMethod(c.Method()) {
+		return "write"
+	}
+	return "public"
+}
+
+// clientIP is the address of the client, a proxy appends the address it saw last to ipHeader, so that is the one trusted
+func clientIP(c *fiber.Ctx, ipHeader string) string {
+	if ipHeader != "" {
+		addresses := strings.Split(c.Get(ipHeader), ",")
+		if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+			return ip
+		}
+	}
+	return c.IP()
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"kirmac-site-backend/common/requestctx"
+	"regexp"
+)
+
+// requestIDPattern accepts the ids proxies and clients usually send, anything else is replaced with a new id
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID keeps the X-Request-ID of the request or generates one, puts it into the user context and echoes it in the response
+func RequestID(c *fiber.Ctx) error {
+	requestID := c.Get(fiber.HeaderXRequestID)
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = utils.UUIDv4()
+	}
+	c.Set(fiber.HeaderXRequestID, requestID)
+	c.SetUserContext(requestctx.WithRequestID(c.UserContext(), requestID))
+	return c.Next()
+}
@@ -0,0 +1,64 @@
+package response
+
+import "kirmac-site-backend/domain"
+
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+type PropertyListResponse struct {
+	Data       []domain.Property `json:"data"`
+	TotalCount int64             `json:"total_count"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	Links      PageLinks         `json:"links"`
+}
+
+type NearbyPropertiesResponse struct {
+	Data       []domain.PropertyDistance `json:"data"`
+	TotalCount int64                     `json:"total_count"`
+	Page       int                       `json:"page"`
+	PageSize   int                       `json:"page_size"`
+	Links      PageLinks                 `json:"links"`
+}
+
+type AuditListResponse struct {
+	Data       []domain.AuditEntry `json:"data"`
+	TotalCount int64               `json:"total_count"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"page_size"`
+	Links      PageLinks           `json:"links"`
+}
+
+type AgentListResponse struct {
+	Data []domain.Agent `json:"data"`
+}
+
+type APIKeyListResponse struct {
+	Data []domain.APIKey `json:"data"`
+}
+
+type ImageListResponse struct {
+	Data []domain.PropertyImage `json:"data"`
+}
+
+type PropertySearchResponse struct {
+	Query      string                        `json:"query"`
+	Data       []domain.PropertySearchResult `json:"data"`
+	TotalCount int64                         `json:"total_count"`
+	Page       int                           `json:"page"`
+	PageSize   int                           `json:"page_size"`
+	Links      PageLinks                     `json:"links"`
+}
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []domain.FieldError `json:"errors,omitempty"`
+}
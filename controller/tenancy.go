@@ -0,0 +1,20 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/services"
+)
+
+// Tenancy scopes the user context to the tenant of the credentials of the request or of the host read from hostHeader,
+// it runs after Authentication so signed in requests cannot reach another tenant by changing the host
+func Tenancy(tenantService services.ITenantService, hostHeader string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenant, err := tenantService.ResolveTenant(c.UserContext(), c.Get(hostHeader))
+		if err != nil {
+			return err
+		}
+		c.SetUserContext(requestctx.WithTenant(c.UserContext(), tenant))
+		return c.Next()
+	}
+}
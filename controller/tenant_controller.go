@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+)
+
+type TenantController struct{}
+
+func NewTenantController() *TenantController {
+	return &TenantController{}
+}
+
+func (t *TenantController) RegisterRoutes(app *fiber.App) {
+	app.Get("/tenant", t.getTenant)
+}
+
+// getTenant returns the agency the request is served for, with the currency its prices are in
+func (t *TenantController) getTenant(c *fiber.Ctx) error {
+	tenant, ok := requestctx.Tenant(c.UserContext())
+	if !ok {
+		return domain.ErrNotFound
+	}
+	return c.JSON(tenant)
+}
@@ -0,0 +1,12 @@
+package domain
+
+type Agent struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Title     string   `json:"title"`
+	Phone     string   `json:"phone"`
+	Email     string   `json:"email"`
+	PhotoURL  string   `json:"photo_url"`
+	Bio       string   `json:"bio"`
+	Languages []string `json:"languages"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// AuditAction is the kind of change an audit entry records
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+)
+
+// AuditEntityProperty is the entity type of the audit entries of properties
+const AuditEntityProperty = "property"
+
+// FieldChange is the value of a single field before and after a change, a missing value is null
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry records who changed an entity, when, in which request and how its fields changed
+type AuditEntry struct {
+	ID         int64         `json:"id"`
+	EntityType string        `json:"entity_type"`
+	EntityID   int64         `json:"entity_id"`
+	Action     AuditAction   `json:"action"`
+	Actor      string        `json:"actor"`
+	RequestID  string        `json:"request_id"`
+	Changes    []FieldChange `json:"changes"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// AuditPage is a single page of audit entries together with the total number of entries
+type AuditPage struct {
+	Entries    []AuditEntry
+	TotalCount int64
+	Page       int
+	PageSize   int
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the requested resource does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrValidation is returned when the input is invalid, use ValidationError to report the fields
+	ErrValidation = errors.New("validation failed")
+	// ErrConflict is returned when the change clashes with the current state of a resource
+	ErrConflict = errors.New("conflict")
+	// ErrUnauthorized is returned when a request carries no valid credentials
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the caller is known but its role does not allow the action
+	ErrForbidden = errors.New("forbidden")
+	// ErrPreconditionFailed is returned when a conditional change expects another version of a resource
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrTooLarge is returned when an upload exceeds the size limit
+	ErrTooLarge = errors.New("too large")
+	// ErrTooManyRequests is returned when a client went over its rate limit
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrUnavailable is returned when a dependency such as the database cannot be reached
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// FieldError describes why a single field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError reports every invalid field of an input, it matches ErrValidation with errors.Is
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError creates a validation error for the given fields
+func NewValidationError(fields ...FieldError) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
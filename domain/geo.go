@@ -0,0 +1,111 @@
+package domain
+
+import "math"
+
+const (
+	// EarthRadiusKm is the mean radius of the earth used for distances
+	EarthRadiusKm = 6371.0
+	// MaxRadiusKm limits radius searches so they stay a local query
+	MaxRadiusKm = 500.0
+	// kmPerDegree is the length of one degree of latitude
+	kmPerDegree = math.Pi * EarthRadiusKm / 180
+)
+
+// GeoPoint is a position in decimal degrees
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// GeoRadius matches positions within RadiusKm of Center
+type GeoRadius struct {
+	Center   GeoPoint
+	RadiusKm float64
+}
+
+// BoundingBox matches positions between two corners, MinLongitude is greater than MaxLongitude when the box crosses the antimeridian
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// Contains reports whether point lies inside the box
+func (box BoundingBox) Contains(point GeoPoint) bool {
+	if point.Latitude < box.MinLatitude || point.Latitude > box.MaxLatitude {
+		return false
+	}
+	if box.CrossesAntimeridian() {
+		return point.Longitude >= box.MinLongitude || point.Longitude <= box.MaxLongitude
+	}
+	return point.Longitude >= box.MinLongitude && point.Longitude <= box.MaxLongitude
+}
+
+// CrossesAntimeridian reports whether the box spans the 180th meridian
+func (box BoundingBox) CrossesAntimeridian() bool {
+	return box.MinLongitude > box.MaxLongitude
+}
+
+// Bounds returns a box holding the whole circle, it spans every longitude when the circle reaches a pole or half way around the earth
+func (radius GeoRadius) Bounds() BoundingBox {
+	latitudeDelta := radius.RadiusKm / kmPerDegree
+	box := BoundingBox{
+		MinLatitude:  math.Max(-90, radius.Center.Latitude-latitudeDelta),
+		MaxLatitude:  math.Min(90, radius.Center.Latitude+latitudeDelta),
+		MinLongitude: -180,
+		MaxLongitude: 180,
+	}
+	if box.MinLatitude == -90 || box.MaxLatitude == 90 {
+		return box
+	}
+	longitudeDelta := latitudeDelta / math.Cos(radius.Center.Latitude*math.Pi/180)
+	if longitudeDelta >= 180 {
+		return box
+	}
+	box.MinLongitude = wrapLongitude(radius.Center.Longitude - longitudeDelta)
+	box.MaxLongitude = wrapLongitude(radius.Center.Longitude + longitudeDelta)
+	return box
+}
+
+// DistanceKm returns the great circle distance between two points using the haversine formula
+func DistanceKm(a GeoPoint, b GeoPoint) float64 {
+	toRadians := math.Pi / 180
+	latitudeDelta := (b.Latitude - a.Latitude) * toRadians
+	longitudeDelta := (b.Longitude - a.Longitude) * toRadians
+	h := math.Pow(math.Sin(latitudeDelta/2), 2) +
+		math.Cos(a.Latitude*toRadians)*math.Cos(b.Latitude*toRadians)*math.Pow(math.Sin(longitudeDelta/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+func wrapLongitude(longitude float64) float64 {
+	if longitude < -180 {
+		return longitude + 360
+	}
+	if longitude > 180 {
+		return longitude - 360
+	}
+	return longitude
+}
+
+// PropertyDistance is a property found by a radius search with its distance from the center
+type PropertyDistance struct {
+	Property   Property `json:"property"`
+	DistanceKm float64  `json:"distance_km"`
+}
+
+// PropertyDistancePage is a single page of radius search results, nearest first
+type PropertyDistancePage struct {
+	Results    []PropertyDistance
+	TotalCount int64
+	Page       int
+	PageSize   int
+}
+
+// PropertyCluster is a group of properties sharing a grid cell, located at the average position of its properties
+type PropertyCluster struct {
+	Center   GeoPoint
+	Count    int64
+	MinPrice int
+	MaxPrice int
+}
@@ -0,0 +1,22 @@
+package domain
+
+// PropertyImage is an image of a property, images are listed by position and the cover is shown first on listings.
+// Uploaded images also have their size and resized variants.
+type PropertyImage struct {
+	ID       int64                   `json:"id"`
+	URL      string                  `json:"url"`
+	Alt      string                  `json:"alt"`
+	Caption  string                  `json:"caption"`
+	Position int                     `json:"position"`
+	Width    int                     `json:"width,omitempty"`
+	Height   int                     `json:"height,omitempty"`
+	IsCover  bool                    `json:"is_cover"`
+	Variants map[string]ImageVariant `json:"variants,omitempty"`
+}
+
+// ImageVariant is a resized copy of an image
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
@@ -1,15 +1,30 @@
 package domain
 
+import "time"
+
 type Property struct {
-	ID          int64    `json:"id"`
-	Location    string   `json:"location"`
-	Price       int      `json:"price"`
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	Bedrooms    int      `json:"bedrooms"`
-	Bathrooms   int      `json:"bathrooms"`
-	SquareFeet  int      `json:"square_feet"`
-	AgentName   string   `json:"agent_name"`
-	AgentTitle  string   `json:"agent_title"`
-	ImageURLs   []string `json:"image_urls"`
+	ID              int64           `json:"id"`
+	Location        string          `json:"location"`
+	City            string          `json:"city"`
+	District        string          `json:"district"`
+	Neighborhood    string          `json:"neighborhood"`
+	PostalCode      string          `json:"postal_code"`
+	Latitude        *float64        `json:"latitude"`
+	Longitude       *float64        `json:"longitude"`
+	Price           int             `json:"price"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"`
+	Bedrooms        int             `json:"bedrooms"`
+	Bathrooms       int             `json:"bathrooms"`
+	SquareFeet      int             `json:"square_feet"`
+	AgentID         *int64          `json:"agent_id"`
+	AgentName       string          `json:"agent_name"`
+	AgentTitle      string          `json:"agent_title"`
+	Images          []PropertyImage `json:"images"`
+	Status          PropertyStatus  `json:"status"`
+	StatusChangedAt time.Time       `json:"status_changed_at"`
+	DeletedAt       *time.Time      `json:"deleted_at,omitempty"`
+	// OwnerID is the user who created the listing, agents may only change the listings they own
+	OwnerID *int64 `json:"owner_id,omitempty"`
+	Version int64  `json:"version"`
 }
@@ -0,0 +1,89 @@
+package domain
+
+import "math"
+
+const (
+	SortByID         = "id"
+	SortByPrice      = "price"
+	SortByBedrooms   = "bedrooms"
+	SortByBathrooms  = "bathrooms"
+	SortBySquareFeet = "square_feet"
+	SortByTitle      = "title"
+	SortByLocation   = "location"
+
+	SortAscending  = "asc"
+	SortDescending = "desc"
+
+	DefaultPage     = 1
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	// MaxPage keeps the offset of the last page within the range of every integer type it passes through
+	MaxPage = math.MaxInt32
+)
+
+// PropertyCriteria describes how a list of properties should be filtered, sorted and paginated
+type PropertyCriteria struct {
+	MinPrice      *int
+	MaxPrice      *int
+	MinBedrooms   *int
+	MinBathrooms  *int
+	MinSquareFeet *int
+	MaxSquareFeet *int
+	Location      string
+	AgentID       *int64
+	AgentName     string
+	City          string
+	Near          *GeoRadius
+	Within        *BoundingBox
+	// Statuses lists the statuses to include, every status when empty
+	Statuses []PropertyStatus
+	// HasCoordinates leaves out properties without a latitude and longitude
+	HasCoordinates bool
+	SortField      string
+	SortDirection  string
+	Page           int
+	PageSize       int
+}
+
+// Offset returns the number of rows to skip for the current page
+func (criteria PropertyCriteria) Offset() int64 {
+	return PageOffset(criteria.Page, criteria.PageSize)
+}
+
+// PageOffset returns the number of rows to skip for page, computed in int64 so that large pages do not overflow
+func PageOffset(page int, pageSize int) int64 {
+	return int64(page-1) * int64(pageSize)
+}
+
+// PropertyPage is a single page of properties together with the total number of matches
+type PropertyPage struct {
+	Properties []Property
+	TotalCount int64
+	Page       int
+	PageSize   int
+}
+
+// IsSortField reports whether field can be used to sort properties
+func IsSortField(field string) bool {
+	switch field {
+	case SortByID, SortByPrice, SortByBedrooms, SortByBathrooms, SortBySquareFeet, SortByTitle, SortByLocation:
+		return true
+	}
+	return false
+}
+
+// PropertySearchResult is a property matched by a full-text search with its relevance and highlighted snippets
+type PropertySearchResult struct {
+	Property   Property          `json:"property"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// PropertySearchPage is a single page of full-text search results together with the total number of matches
+type PropertySearchPage struct {
+	Query      string
+	Results    []PropertySearchResult
+	TotalCount int64
+	Page       int
+	PageSize   int
+}
@@ -0,0 +1,79 @@
+package domain
+
+import "time"
+
+// PropertyStatus is the stage of a listing in its lifecycle
+type PropertyStatus string
+
+const (
+	StatusDraft      PropertyStatus = "draft"
+	StatusActive     PropertyStatus = "active"
+	StatusUnderOffer PropertyStatus = "under_offer"
+	StatusSold       PropertyStatus = "sold"
+	StatusArchived   PropertyStatus = "archived"
+)
+
+// PropertyStatuses lists every status in lifecycle order
+var PropertyStatuses = []PropertyStatus{StatusDraft, StatusActive, StatusUnderOffer, StatusSold, StatusArchived}
+
+// ListedStatuses are the statuses of properties shown when a list does not ask for a status
+var ListedStatuses = []PropertyStatus{StatusActive, StatusUnderOffer}
+
+// statusTransitions maps each status to the statuses a property may move to from it
+var statusTransitions = map[PropertyStatus][]PropertyStatus{
+	StatusDraft:      {StatusActive, StatusArchived},
+	StatusActive:     {StatusDraft, StatusUnderOffer, StatusSold, StatusArchived},
+	StatusUnderOffer: {StatusActive, StatusSold, StatusArchived},
+	StatusSold:       {StatusArchived},
+	StatusArchived:   {StatusDraft},
+}
+
+// IsPropertyStatus reports whether status is a known status
+func IsPropertyStatus(status PropertyStatus) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// AllowedTransitions lists the statuses a property in status may move to
+func AllowedTransitions(status PropertyStatus) []PropertyStatus {
+	return append([]PropertyStatus{}, statusTransitions[status]...)
+}
+
+// IsListedStatus reports whether properties in status are shown to everyone
+func IsListedStatus(status PropertyStatus) bool {
+	for _, listed := range ListedStatuses {
+		if listed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether a property may move from one status to another
+func CanTransition(from PropertyStatus, to PropertyStatus) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange records a single transition of a property, Actor is whoever made it
+type StatusChange struct {
+	ID         int64          `json:"id"`
+	PropertyID int64          `json:"property_id"`
+	FromStatus PropertyStatus `json:"from_status"`
+	ToStatus   PropertyStatus `json:"to_status"`
+	Actor      string         `json:"actor"`
+	Note       string         `json:"note"`
+	ChangedAt  time.Time      `json:"changed_at"`
+}
+
+// StatusHistory is the current status of a property, the statuses it can move to and the transitions that led there
+type StatusHistory struct {
+	Status             PropertyStatus   `json:"status"`
+	StatusChangedAt    time.Time        `json:"status_changed_at"`
+	AllowedTransitions []PropertyStatus `json:"allowed_transitions"`
+	Changes            []StatusChange   `json:"changes"`
+}
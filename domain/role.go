@@ -0,0 +1,23 @@
+package domain
+
+// Role decides what a user may do, the policy of each role lives in the services
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleAgent  Role = "agent"
+	RoleViewer Role = "viewer"
+)
+
+// Roles lists every role
+var Roles = []Role{RoleAdmin, RoleAgent, RoleViewer}
+
+// IsRole reports whether role is one of Roles
+func IsRole(role Role) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package domain
+
+// DefaultCurrency is the currency of the tenants that do not set one
+const DefaultCurrency = "TRY"
+
+// Tenant is an agency sharing the deployment, every property and user belongs to exactly one tenant.
+// Hosts are the host names its site is served on, CorsOrigins replace the configured CORS origins when set.
+type Tenant struct {
+	ID          int64    `json:"id"`
+	Slug        string   `json:"slug"`
+	Name        string   `json:"name"`
+	Currency    string   `json:"currency"`
+	Hosts       []string `json:"-"`
+	CorsOrigins []string `json:"-"`
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// User is an account that signs in with a username and password
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	TenantID     int64     `json:"tenant_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// APIKey lets another server make changes on behalf of the user who created it, only a hash of the key is stored
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey is a newly created API key together with the key itself, which is shown only once
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// TokenPair is a short-lived access token and the refresh token that renews it
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Principal is whoever a request was authenticated as, Name is recorded as the actor of its changes.
+// A request made with an API key acts as the user owning the key.
+type Principal struct {
+	Name     string
+	UserID   int64
+	Role     Role
+	TenantID int64
+	KeyID    int64
+}
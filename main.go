@@ -1,30 +1,270 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v3/log"
 	"kirmac-site-backend/common/app"
 	"kirmac-site-backend/common/postgresql"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/common/storage"
+	"kirmac-site-backend/common/token"
 	"kirmac-site-backend/controller"
+	"kirmac-site-backend/domain"
 	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/persistence/migrations"
 	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"log"
+	"os"
+	"strings"
+	"time"
 )
 
 func main() {
 	ctx := context.Background()
-	c := fiber.New()
 
-	configurationManager := app.NewConfigurationManager()
+	configurationManager, err := app.NewConfigurationManager()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fiberlog.SetLevel(configurationManager.FiberLogLevel())
 
 	dbPool := postgresql.GetConnectionPool(ctx, configurationManager.PostgreSqlConfig)
+	defer dbPool.Close()
 
-	propertyRepository := persistence.NewPropertyRepository(dbPool)
+	migrator, err := migrations.NewMigrator(dbPool)
+	if err != nil {
+		log.Fatalf("Unable to load migrations: %v", err)
+	}
 
-	propertyService := services.NewPropertyService(propertyRepository)
+	queryTimeouts := persistence.QueryTimeouts{
+		Read:   time.Duration(configurationManager.QueryTimeouts.Read),
+		Write:  time.Duration(configurationManager.QueryTimeouts.Write),
+		Search: time.Duration(configurationManager.QueryTimeouts.Search),
+	}
+	tenantRepository := persistence.NewTenantRepository(dbPool, queryTimeouts)
+	tenantService := services.NewTenantService(tenantRepository, configurationManager.Tenancy.DefaultTenant, time.Duration(configurationManager.Tenancy.CacheTTL))
+	authService := services.NewAuthService(
+		persistence.NewUserRepository(dbPool, queryTimeouts),
+		persistence.NewAPIKeyRepository(dbPool, queryTimeouts),
+		newTokenIssuer(configurationManager.Auth),
+	)
+
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, migrator, authService, tenantService, configurationManager.Tenancy.DefaultTenant, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if configurationManager.AutoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			log.Fatalf("Unable to migrate database: %v", err)
+		}
+	}
+
+	imageStorage, err := newStorage(configurationManager.Storage)
+	if err != nil {
+		log.Fatalf("Unable to open image storage: %v", err)
+	}
+
+	c := fiber.New(fiber.Config{
+		// leave room for the multipart encoding around the largest allowed image
+		BodyLimit:    int(configurationManager.Storage.MaxUploadSize) + 1<<20,
+		ReadTimeout:  time.Duration(configurationManager.Server.ReadTimeout),
+		WriteTimeout: time.Duration(configurationManager.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(configurationManager.Server.IdleTimeout),
+		ErrorHandler: controller.ErrorHandler,
+	})
+
+	propertyRepository := persistence.NewPropertyRepository(dbPool, queryTimeouts)
+	agentRepository := persistence.NewAgentRepository(dbPool, queryTimeouts)
+	imageRepository := persistence.NewPropertyImageRepository(dbPool, queryTimeouts)
+	auditRepository := persistence.NewAuditRepository(dbPool, queryTimeouts)
+	transactionManager := persistence.NewTransactionManager(dbPool)
+
+	propertyService := services.NewPropertyService(propertyRepository, auditRepository, transactionManager)
+	agentService := services.NewAgentService(agentRepository, propertyRepository)
+	imageService := services.NewImageService(propertyRepository, imageRepository, auditRepository, transactionManager, imageStorage, configurationManager.Storage.MaxUploadSize)
+
+	if retention := time.Duration(configurationManager.Trash.Retention); retention > 0 {
+		trashPurger := services.NewTrashPurger(propertyRepository, tenantRepository, imageStorage, retention, time.Duration(configurationManager.Trash.PurgeInterval))
+		go trashPurger.Run(ctx)
+	}
 
 	propertyController := controller.NewPropertyController(propertyService)
+	agentController := controller.NewAgentController(agentService)
+	imageController := controller.NewImageController(imageService)
+	authController := controller.NewAuthController(authService)
+	tenantController := controller.NewTenantController()
 
+	controller.UseMiddleware(c, controller.MiddlewareConfig{
+		AuthService:   authService,
+		TenantService: tenantService,
+		Tenancy:       configurationManager.Tenancy,
+		Cors:          configurationManager.Cors,
+		RateLimit:     configurationManager.RateLimit,
+	})
+	authController.RegisterRoutes(c)
+	tenantController.RegisterRoutes(c)
 	propertyController.RegisterRoutes(c)
+	agentController.RegisterRoutes(c)
+	imageController.RegisterRoutes(c)
+
+	log.Fatal(c.Listen(configurationManager.Server.ListenAddress))
+}
+
+// newStorage opens the image storage backend selected by the configuration
+func newStorage(config app.StorageConfig) (storage.Storage, error) {
+	if config.Backend == "s3" {
+		return storage.NewS3Storage(storage.S3Config{
+			Endpoint:        config.S3.Endpoint,
+			Region:          config.S3.Region,
+			Bucket:          config.S3.Bucket,
+			AccessKeyID:     config.S3.AccessKeyID,
+			SecretAccessKey: config.S3.SecretAccessKey,
+			PublicURL:       config.S3.PublicURL,
+		}, nil)
+	}
+	return storage.NewLocalStorage(config.Local.Directory, config.Local.PublicURL)
+}
+
+// newTokenIssuer signs tokens with the configured secret, or with a random one that invalidates tokens on restart
+func newTokenIssuer(config app.AuthConfig) *token.Issuer {
+	secret := []byte(config.JWTSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Unable to generate a JWT secret: %v", err)
+		}
+		fiberlog.Warn("auth.jwt_secret is not set, tokens are signed with a random secret and stop working on restart")
+	}
+	return token.NewIssuer(secret, config.Issuer, time.Duration(config.AccessTokenTTL), time.Duration(config.RefreshTokenTTL))
+}
+
+// runCommand runs a command line subcommand instead of starting the server
+func runCommand(ctx context.Context, migrator *migrations.Migrator, authService services.IAuthService, tenantService services.ITenantService, defaultTenant string, args []string) error {
+	switch args[0] {
+	case "user":
+		return runUserCommand(ctx, authService, tenantService, defaultTenant, args[1:])
+	case "tenant":
+		return runTenantCommand(ctx, tenantService, args[1:])
+	}
+	if args[0] != "migrate" || len(args) != 2 {
+		return fmt.Errorf("usage: %s migrate up|down|status | user create [-tenant slug] <username> [admin|agent|viewer] | tenant create|list", os.Args[0])
+	}
+
+	switch args[1] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Println("no migration to revert")
+			return nil
+		}
+		fmt.Printf("reverted %04d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Migration.Version, status.Migration.Name, appliedAt)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[1])
+	}
+	return nil
+}
+
+// runUserCommand creates a user account of a tenant with an optional role, the password is read from the first line
+// of standard input so it does not end up in the shell history
+func runUserCommand(ctx context.Context, authService services.IAuthService, tenantService services.ITenantService, defaultTenant string, args []string) error {
+	usage := fmt.Errorf("usage: %s user create [-tenant slug] <username> [admin|agent|viewer]", os.Args[0])
+	if len(args) == 0 || args[0] != "create" {
+		return usage
+	}
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	tenantSlug := flags.String("tenant", defaultTenant, "slug of the tenant the user belongs to")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() < 1 || flags.NArg() > 2 {
+		return usage
+	}
+	tenant, err := tenantService.GetTenantBySlug(ctx, *tenantSlug)
+	if err != nil {
+		return err
+	}
+	user := model.UserCreate{Username: flags.Arg(0), Role: domain.Role(flags.Arg(1))}
+	fmt.Fprint(os.Stderr, "password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("unable to read password: %v", err)
+	}
+	user.Password = strings.TrimRight(password, "\r\n")
+	created, err := authService.CreateUser(requestctx.WithTenant(ctx, tenant), user)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s %s of %s with id %d\n", created.Role, created.Username, tenant.Slug, created.ID)
+	return nil
+}
 
-	c.Listen(":8080")
+// runTenantCommand adds an agency or lists the agencies, hosts and CORS origins are repeatable flags
+func runTenantCommand(ctx context.Context, tenantService services.ITenantService, args []string) error {
+	usage := fmt.Errorf("usage: %s tenant create [-currency code] [-host name]... [-cors-origin origin]... <slug> <name> | tenant list", os.Args[0])
+	if len(args) == 1 && args[0] == "list" {
+		tenants, err := tenantService.GetTenants(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			fmt.Printf("%-20s %-30s %s %s\n", tenant.Slug, tenant.Name, tenant.Currency, strings.Join(tenant.Hosts, ","))
+		}
+		return nil
+	}
+	if len(args) == 0 || args[0] != "create" {
+		return usage
+	}
+	var tenant model.TenantCreate
+	flags := flag.NewFlagSet("tenant create", flag.ContinueOnError)
+	flags.StringVar(&tenant.Currency, "currency", domain.DefaultCurrency, "ISO 4217 code of the currency of the prices")
+	flags.Func("host", "host name the site of the tenant is served on", func(value string) error {
+		tenant.Hosts = append(tenant.Hosts, value)
+		return nil
+	})
+	flags.Func("cors-origin", "origin allowed to call the API for the tenant", func(value string) error {
+		tenant.CorsOrigins = append(tenant.CorsOrigins, value)
+		return nil
+	})
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 2 {
+		return usage
+	}
+	tenant.Slug, tenant.Name = flags.Arg(0), flags.Arg(1)
+	created, err := tenantService.CreateTenant(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created tenant %s with id %d\n", created.Slug, created.ID)
+	return nil
 }
@@ -0,0 +1,127 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber/v3/log"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lib/pq"
+	"kirmac-site-backend/domain"
+)
+
+const (
+	getAllAgentsQuery = `SELECT id, name, title, phone, email, photo_url, bio, languages FROM agents WHERE tenant_id = $tenant ORDER BY name, id`
+	getAgentByIdQuery = `SELECT id, name, title, phone, email, photo_url, bio, languages FROM agents WHERE id = $1 AND tenant_id = $tenant`
+	addAgentQuery     = `INSERT INTO agents (name, title, phone, email, photo_url, bio, languages, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $tenant) RETURNING id`
+	updateAgentQuery  = `UPDATE agents SET name = $1, title = $2, phone = $3, email = $4, photo_url = $5, bio = $6, languages = $7 WHERE id = $8 AND tenant_id = $tenant`
+	deleteAgentQuery  = `DELETE FROM agents WHERE id = $1 AND tenant_id = $tenant`
+)
+
+// IAgentRepository is an interface for the agent repository, every method only sees the agents of the tenant of ctx
+type IAgentRepository interface {
+	GetAllAgents(ctx context.Context) ([]domain.Agent, error)
+	GetAgentById(ctx context.Context, id int64) (domain.Agent, error)
+	AddAgent(ctx context.Context, agent domain.Agent) (domain.Agent, error)
+	UpdateAgent(ctx context.Context, id int64, agent domain.Agent) (domain.Agent, error)
+	DeleteById(ctx context.Context, id int64) (bool, error)
+}
+
+// AgentRepository is a struct for the agent repository
+type AgentRepository struct {
+	dbPool   *pgxpool.Pool
+	timeouts QueryTimeouts
+}
+
+// NewAgentRepository creates a new agent repository
+func NewAgentRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) IAgentRepository {
+	return &AgentRepository{dbPool: dbPool, timeouts: timeouts}
+}
+
+// db returns the transaction ctx runs in, or the pool, scoped to the tenant of ctx
+func (agentRepository *AgentRepository) db(ctx context.Context) Querier {
+	return scoped(ctx, querier(ctx, agentRepository.dbPool))
+}
+
+// GetAllAgents gets every agent ordered by name
+func (agentRepository *AgentRepository) GetAllAgents(ctx context.Context) ([]domain.Agent, error) {
+	ctx, cancel := withTimeout(ctx, agentRepository.timeouts.Read)
+	defer cancel()
+	rows, err := agentRepository.db(ctx).Query(ctx, getAllAgentsQuery)
+	if err != nil {
+		log.Errorf("Veritabanı sorgusu hatası: %v", err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var agents []domain.Agent
+	for rows.Next() {
+		agent, err := scanAgent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		agents = append(agents, agent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return agents, nil
+}
+
+// GetAgentById gets an agent by id
+func (agentRepository *AgentRepository) GetAgentById(ctx context.Context, id int64) (domain.Agent, error) {
+	ctx, cancel := withTimeout(ctx, agentRepository.timeouts.Read)
+	defer cancel()
+	agent, err := scanAgent(agentRepository.db(ctx).QueryRow(ctx, getAgentByIdQuery, id))
+	if err == pgx.ErrNoRows {
+		return domain.Agent{}, fmt.Errorf("agent %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.Agent{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return agent, nil
+}
+
+// AddAgent adds an agent and returns it with its generated id
+func (agentRepository *AgentRepository) AddAgent(ctx context.Context, agent domain.Agent) (domain.Agent, error) {
+	ctx, cancel := withTimeout(ctx, agentRepository.timeouts.Write)
+	defer cancel()
+	err := agentRepository.db(ctx).QueryRow(ctx, addAgentQuery, agent.Name, agent.Title, agent.Phone, agent.Email, agent.PhotoURL, agent.Bio, pq.Array(agent.Languages)).Scan(&agent.ID)
+	if err != nil {
+		log.Errorf("Unable to add agent: %v\n", err)
+		return domain.Agent{}, fmt.Errorf("unable to add agent: %w", translateError(err))
+	}
+	return agent, nil
+}
+
+// UpdateAgent replaces every column of an agent, the change shows on every property of the agent
+func (agentRepository *AgentRepository) UpdateAgent(ctx context.Context, id int64, agent domain.Agent) (domain.Agent, error) {
+	ctx, cancel := withTimeout(ctx, agentRepository.timeouts.Write)
+	defer cancel()
+	cmdTag, err := agentRepository.db(ctx).Exec(ctx, updateAgentQuery, agent.Name, agent.Title, agent.Phone, agent.Email, agent.PhotoURL, agent.Bio, pq.Array(agent.Languages), id)
+	if err != nil {
+		return domain.Agent{}, fmt.Errorf("unable to update agent: %w", translateError(err))
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return domain.Agent{}, fmt.Errorf("agent %d: %w", id, domain.ErrNotFound)
+	}
+	agent.ID = id
+	return agent, nil
+}
+
+// DeleteById deletes an agent by id, the properties of the agent are kept without an agent
+func (agentRepository *AgentRepository) DeleteById(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx, agentRepository.timeouts.Write)
+	defer cancel()
+	cmdTag, err := agentRepository.db(ctx).Exec(ctx, deleteAgentQuery, id)
+	if err != nil {
+		return false, fmt.Errorf("unable to delete agent: %w", translateError(err))
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+func scanAgent(row pgx.Row) (domain.Agent, error) {
+	var agent domain.Agent
+	err := row.Scan(&agent.ID, &agent.Name, &agent.Title, &agent.Phone, &agent.Email, &agent.PhotoURL, &agent.Bio, pq.Array(&agent.Languages))
+	return agent, err
+}
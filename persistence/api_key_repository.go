@@ -0,0 +1,112 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"kirmac-site-backend/domain"
+)
+
+const (
+	apiKeyColumns   = `k.id, k.user_id, k.name, k.prefix, k.key_hash, k.created_at, k.last_used_at, k.revoked_at`
+	addAPIKeyQuery  = `INSERT INTO api_keys (user_id, name, prefix, key_hash) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	getAPIKeysQuery = `SELECT ` + apiKeyColumns + ` FROM api_keys k JOIN users u ON u.id = k.user_id ` +
+		`WHERE ($1 = 0 OR k.user_id = $1) AND u.tenant_id = $tenant ORDER BY k.created_at, k.id`
+	useAPIKeyQuery    = `UPDATE api_keys k SET last_used_at = now() WHERE k.key_hash = $1 AND k.revoked_at IS NULL RETURNING ` + apiKeyColumns
+	revokeAPIKeyQuery = `UPDATE api_keys k SET revoked_at = now() FROM users u WHERE u.id = k.user_id AND k.id = $1 AND ($2 = 0 OR k.user_id = $2) ` +
+		`AND u.tenant_id = $tenant AND k.revoked_at IS NULL RETURNING ` + apiKeyColumns
+)
+
+// IAPIKeyRepository is an interface for the API key repository
+type IAPIKeyRepository interface {
+	AddAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	UseAPIKey(ctx context.Context, keyHash string) (domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64, userID int64) (domain.APIKey, error)
+}
+
+// APIKeyRepository is a struct for the API key repository
+type APIKeyRepository struct {
+	dbPool   *pgxpool.Pool
+	timeouts QueryTimeouts
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) IAPIKeyRepository {
+	return &APIKeyRepository{dbPool: dbPool, timeouts: timeouts}
+}
+
+// db returns the transaction ctx runs in, or the pool
+func (apiKeyRepository *APIKeyRepository) db(ctx context.Context) Querier {
+	return querier(ctx, apiKeyRepository.dbPool)
+}
+
+// AddAPIKey stores a new API key by its hash
+func (apiKeyRepository *APIKeyRepository) AddAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, apiKeyRepository.timeouts.Write)
+	defer cancel()
+	err := apiKeyRepository.db(ctx).QueryRow(ctx, addAPIKeyQuery, key.UserID, key.Name, key.Prefix, key.KeyHash).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("unable to add API key: %w", translateError(err))
+	}
+	return key, nil
+}
+
+// GetAPIKeys gets the API keys of a user including revoked ones, oldest first, userID 0 gets the keys of every user of the tenant
+func (apiKeyRepository *APIKeyRepository) GetAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, apiKeyRepository.timeouts.Read)
+	defer cancel()
+	rows, err := scoped(ctx, apiKeyRepository.db(ctx)).Query(ctx, getAPIKeysQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read API keys: %w", translateError(err))
+	}
+	defer rows.Close()
+
+	keys := []domain.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return keys, nil
+}
+
+// UseAPIKey finds the active API key with the given hash and records that it was used
+func (apiKeyRepository *APIKeyRepository) UseAPIKey(ctx context.Context, keyHash string) (domain.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, apiKeyRepository.timeouts.Write)
+	defer cancel()
+	key, err := scanAPIKey(apiKeyRepository.db(ctx).QueryRow(ctx, useAPIKeyQuery, keyHash))
+	if err == pgx.ErrNoRows {
+		return domain.APIKey{}, fmt.Errorf("API key: %w", domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return key, nil
+}
+
+// RevokeAPIKey revokes an active API key of a user, userID 0 matches any user of the tenant, a revoked key can no longer be used
+func (apiKeyRepository *APIKeyRepository) RevokeAPIKey(ctx context.Context, id int64, userID int64) (domain.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, apiKeyRepository.timeouts.Write)
+	defer cancel()
+	key, err := scanAPIKey(scoped(ctx, apiKeyRepository.db(ctx)).QueryRow(ctx, revokeAPIKeyQuery, id, userID))
+	if err == pgx.ErrNoRows {
+		return domain.APIKey{}, fmt.Errorf("API key %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return key, nil
+}
+
+func scanAPIKey(row pgx.Row) (domain.APIKey, error) {
+	var key domain.APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
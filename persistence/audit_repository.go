@@ -0,0 +1,88 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"kirmac-site-backend/domain"
+)
+
+const (
+	addAuditEntryQuery   = `INSERT INTO audit_log (entity_type, entity_id, action, actor, request_id, changes, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $tenant)`
+	countAuditEntryQuery = `SELECT COUNT(*) FROM audit_log WHERE entity_type = $1 AND entity_id = $2 AND tenant_id = $tenant`
+	getAuditEntriesQuery = `SELECT id, entity_type, entity_id, action, actor, request_id, changes, created_at FROM audit_log ` +
+		`WHERE entity_type = $1 AND entity_id = $2 AND tenant_id = $tenant ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`
+)
+
+// IAuditRepository is an interface for the append-only audit log, entries are kept per tenant
+type IAuditRepository interface {
+	AddEntry(ctx context.Context, entry domain.AuditEntry) error
+	GetEntries(ctx context.Context, entityType string, entityID int64, page int, pageSize int) (domain.AuditPage, error)
+}
+
+// AuditRepository is a struct for the audit repository
+type AuditRepository struct {
+	dbPool   *pgxpool.Pool
+	timeouts QueryTimeouts
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) IAuditRepository {
+	return &AuditRepository{dbPool: dbPool, timeouts: timeouts}
+}
+
+// db returns the transaction ctx runs in, or the pool, scoped to the tenant of ctx
+func (auditRepository *AuditRepository) db(ctx context.Context) Querier {
+	return scoped(ctx, querier(ctx, auditRepository.dbPool))
+}
+
+// AddEntry appends an entry to the audit log, called inside the transaction of the change so both are saved or neither
+func (auditRepository *AuditRepository) AddEntry(ctx context.Context, entry domain.AuditEntry) error {
+	ctx, cancel := withTimeout(ctx, auditRepository.timeouts.Write)
+	defer cancel()
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("unable to encode audit changes: %w", err)
+	}
+	_, err = auditRepository.db(ctx).Exec(ctx, addAuditEntryQuery, entry.EntityType, entry.EntityID, entry.Action, entry.Actor, entry.RequestID, string(changes))
+	if err != nil {
+		return fmt.Errorf("unable to add audit entry: %w", translateError(err))
+	}
+	return nil
+}
+
+// GetEntries gets a page of the audit entries of an entity, newest first
+func (auditRepository *AuditRepository) GetEntries(ctx context.Context, entityType string, entityID int64, page int, pageSize int) (domain.AuditPage, error) {
+	ctx, cancel := withTimeout(ctx, auditRepository.timeouts.Read)
+	defer cancel()
+
+	var totalCount int64
+	if err := auditRepository.db(ctx).QueryRow(ctx, countAuditEntryQuery, entityType, entityID).Scan(&totalCount); err != nil {
+		return domain.AuditPage{}, fmt.Errorf("unable to count audit entries: %w", translateError(err))
+	}
+	rows, err := auditRepository.db(ctx).Query(ctx, getAuditEntriesQuery, entityType, entityID, pageSize, domain.PageOffset(page, pageSize))
+	if err != nil {
+		return domain.AuditPage{}, fmt.Errorf("unable to read audit entries: %w", translateError(err))
+	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var entry domain.AuditEntry
+		err := rows.Scan(&entry.ID, &entry.EntityType, &entry.EntityID, &entry.Action, &entry.Actor, &entry.RequestID, &entry.Changes, &entry.CreatedAt)
+		if err != nil {
+			return domain.AuditPage{}, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.AuditPage{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return domain.AuditPage{
+		Entries:    entries,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
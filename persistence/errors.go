@@ -0,0 +1,58 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"kirmac-site-backend/domain"
+	"net"
+	"strings"
+)
+
+const (
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+)
+
+// foreignKeyFields names the input field holding the reference checked by a foreign key constraint
+var foreignKeyFields = map[string]string{
+	"properties_agent_id_fkey": "agent_id",
+}
+
+// translateError wraps database errors with the matching domain error so callers can tell them apart
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %v", domain.ErrNotFound, err)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if pgconn.Timeout(err) {
+		return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == uniqueViolationCode:
+			return fmt.Errorf("%w: %v", domain.ErrConflict, err)
+		case pgErr.Code == foreignKeyViolationCode && foreignKeyFields[pgErr.ConstraintName] != "":
+			return domain.NewValidationError(domain.FieldError{Field: foreignKeyFields[pgErr.ConstraintName], Message: "does not exist"})
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P"):
+			// connection exceptions, insufficient resources and server shutdowns
+			return fmt.Errorf("%w: %v", domain.ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || pgconn.SafeToRetry(err) {
+		return fmt.Errorf("%w: %v", domain.ErrUnavailable, err)
+	}
+	return err
+}
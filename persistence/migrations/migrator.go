@@ -0,0 +1,244 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+// advisoryLockKey identifies the migration lock, so only one instance migrates the database at a time
+const advisoryLockKey = 7231906482
+
+const (
+	createMigrationsTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())`
+	getAppliedMigrationsQuery  = `SELECT version, applied_at FROM schema_migrations ORDER BY version`
+	addMigrationQuery          = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+	deleteMigrationQuery       = `DELETE FROM schema_migrations WHERE version = $1`
+	lockQuery                  = `SELECT pg_advisory_lock($1)`
+	unlockQuery                = `SELECT pg_advisory_unlock($1)`
+)
+
+// Migration is a numbered schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied and when
+type MigrationStatus struct {
+	Migration Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations to the database
+type Migrator struct {
+	dbPool     *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator creates a new migrator with the migrations embedded in the binary
+func NewMigrator(dbPool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{dbPool: dbPool, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order and returns the applied ones
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		appliedAt, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrator.migrations {
+			if _, ok := appliedAt[migration.Version]; ok {
+				continue
+			}
+			err := runInTx(ctx, conn, migration.Up, addMigrationQuery, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("unable to apply migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the most recently applied migration and returns it, or nil if nothing is applied
+func (migrator *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		appliedAt, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrator.migrations) - 1; i >= 0; i-- {
+			migration := migrator.migrations[i]
+			if _, ok := appliedAt[migration.Version]; !ok {
+				continue
+			}
+			err := runInTx(ctx, conn, migration.Down, deleteMigrationQuery, migration.Version)
+			if err != nil {
+				return fmt.Errorf("unable to revert migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			reverted = &migration
+			return nil
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration with the time it was applied
+func (migrator *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		appliedAt, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrator.migrations {
+			status := MigrationStatus{Migration: migration}
+			if at, ok := appliedAt[migration.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock
+func (migrator *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := migrator.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to acquire connection: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, lockQuery, advisoryLockKey); err != nil {
+		return fmt.Errorf("unable to acquire migration lock: %v", err)
+	}
+	defer conn.Exec(context.Background(), unlockQuery, advisoryLockKey)
+
+	if _, err := conn.Exec(ctx, createMigrationsTableQuery); err != nil {
+		return fmt.Errorf("unable to create schema_migrations table: %v", err)
+	}
+	return fn(conn)
+}
+
+func getAppliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, getAppliedMigrationsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	appliedAt := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("unable to read schema_migrations: %v", err)
+		}
+		appliedAt[version] = at
+	}
+	return appliedAt, rows.Err()
+}
+
+// runInTx runs the migration script and the schema_migrations bookkeeping statement atomically
+func runInTx(ctx context.Context, conn *pgxpool.Conn, script string, bookkeepingQuery string, args ...interface{}) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, bookkeepingQuery, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// loadMigrations reads NNNN_name.up.sql and NNNN_name.down.sql pairs from files, ordered by version
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(files, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, path := range paths {
+		fileName := strings.TrimPrefix(path, "sql/")
+		base, direction, ok := cutDirection(fileName)
+		if !ok {
+			return nil, fmt.Errorf("migration %s must end with .up.sql or .down.sql", fileName)
+		}
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name", fileName)
+		}
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has an invalid version: %v", fileName, err)
+		}
+		content, err := fs.ReadFile(files, path)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func cutDirection(fileName string) (string, string, bool) {
+	if base, ok := strings.CutSuffix(fileName, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(fileName, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS properties
+(
+    id          BIGSERIAL PRIMARY KEY,
+    location    VARCHAR(255) NOT NULL,
+    price       INT          NOT NULL,
+    title       VARCHAR(255) NOT NULL,
+    description TEXT         NOT NULL DEFAULT '',
+    bedrooms    INT          NOT NULL DEFAULT 0,
+    bathrooms   INT          NOT NULL DEFAULT 0,
+    square_feet INT          NOT NULL DEFAULT 0,
+    agent_name  VARCHAR(255) NOT NULL DEFAULT '',
+    agent_title VARCHAR(255) NOT NULL DEFAULT '',
+    image_urls  TEXT[]       DEFAULT '{}'
+);
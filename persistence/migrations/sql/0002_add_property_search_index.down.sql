@@ -0,0 +1 @@
+DROP INDEX IF EXISTS properties_search_idx;
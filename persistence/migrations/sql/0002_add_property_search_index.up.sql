@@ -0,0 +1,6 @@
+-- Must stay in sync with propertySearchVector in persistence/property_repository.go so the planner can use it
+CREATE INDEX IF NOT EXISTS properties_search_idx ON properties USING GIN (
+    (setweight(to_tsvector('turkish', coalesce(title, '')), 'A') ||
+     setweight(to_tsvector('turkish', coalesce(location, '')), 'B') ||
+     setweight(to_tsvector('turkish', coalesce(description, '')), 'C'))
+);
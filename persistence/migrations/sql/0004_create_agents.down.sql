@@ -0,0 +1,7 @@
+ALTER TABLE properties
+    ADD COLUMN agent_name  VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN agent_title VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE properties p SET agent_name = a.name, agent_title = a.title FROM agents a WHERE a.id = p.agent_id;
+
+ALTER TABLE properties DROP COLUMN agent_id;
+DROP TABLE IF EXISTS agents;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS agents
+(
+    id        BIGSERIAL PRIMARY KEY,
+    name      VARCHAR(255) NOT NULL,
+    title     VARCHAR(255) NOT NULL DEFAULT '',
+    phone     VARCHAR(32)  NOT NULL DEFAULT '',
+    email     VARCHAR(255) NOT NULL DEFAULT '',
+    photo_url TEXT         NOT NULL DEFAULT '',
+    bio       TEXT         NOT NULL DEFAULT '',
+    languages TEXT[]       NOT NULL DEFAULT '{}'
+);
+
+-- One agent per name, ignoring case and surrounding spaces, with the spelling and title used on most listings
+INSERT INTO agents (name, title)
+SELECT DISTINCT ON (lower(btrim(agent_name))) btrim(agent_name), btrim(agent_title)
+FROM properties
+WHERE btrim(agent_name) <> ''
+GROUP BY lower(btrim(agent_name)), btrim(agent_name), btrim(agent_title)
+ORDER BY lower(btrim(agent_name)), count(*) DESC, btrim(agent_name), btrim(agent_title);
+
+ALTER TABLE properties ADD COLUMN agent_id BIGINT REFERENCES agents (id) ON DELETE SET NULL;
+UPDATE properties p SET agent_id = a.id FROM agents a WHERE lower(btrim(p.agent_name)) = lower(a.name);
+CREATE INDEX IF NOT EXISTS properties_agent_id_idx ON properties (agent_id);
+
+ALTER TABLE properties DROP COLUMN agent_name, DROP COLUMN agent_title;
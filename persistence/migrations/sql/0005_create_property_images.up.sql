@@ -0,0 +1,10 @@
+-- Size and variants of the uploaded images, the order of the images stays in properties.image_urls
+CREATE TABLE IF NOT EXISTS property_images
+(
+    property_id BIGINT NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    url         TEXT   NOT NULL,
+    width       INT    NOT NULL,
+    height      INT    NOT NULL,
+    variants    JSONB  NOT NULL DEFAULT '{}',
+    PRIMARY KEY (property_id, url)
+);
@@ -0,0 +1,17 @@
+ALTER TABLE properties ADD COLUMN image_urls TEXT[] DEFAULT '{}';
+UPDATE properties p
+SET image_urls = coalesce((SELECT array_agg(i.url ORDER BY i.is_cover DESC, i.position, i.id) FROM property_images i WHERE i.property_id = p.id), '{}');
+
+-- Only uploaded images had a row before
+DELETE FROM property_images WHERE width IS NULL OR height IS NULL;
+DROP INDEX IF EXISTS property_images_property_id_position_idx;
+ALTER TABLE property_images
+    DROP CONSTRAINT property_images_property_id_url_key,
+    DROP COLUMN id,
+    DROP COLUMN alt,
+    DROP COLUMN caption,
+    DROP COLUMN position,
+    DROP COLUMN is_cover,
+    ALTER COLUMN width SET NOT NULL,
+    ALTER COLUMN height SET NOT NULL,
+    ADD PRIMARY KEY (property_id, url);
@@ -0,0 +1,27 @@
+-- Every image gets its own row with alt text, caption, position and cover flag, images added by URL have no size
+ALTER TABLE property_images DROP CONSTRAINT property_images_pkey;
+ALTER TABLE property_images
+    ADD COLUMN id       BIGSERIAL PRIMARY KEY,
+    ADD COLUMN alt      VARCHAR(255)  NOT NULL DEFAULT '',
+    ADD COLUMN caption  VARCHAR(1000) NOT NULL DEFAULT '',
+    ADD COLUMN position INT           NOT NULL DEFAULT 0,
+    ADD COLUMN is_cover BOOLEAN       NOT NULL DEFAULT FALSE,
+    ALTER COLUMN width DROP NOT NULL,
+    ALTER COLUMN height DROP NOT NULL,
+    ADD CONSTRAINT property_images_property_id_url_key UNIQUE (property_id, url);
+
+-- The first occurrence of each URL keeps its place, the first image is the cover
+INSERT INTO property_images (property_id, url, position)
+SELECT DISTINCT ON (p.id, u.url) p.id, u.url, u.position
+FROM properties p, unnest(p.image_urls) WITH ORDINALITY AS u(url, position)
+ORDER BY p.id, u.url, u.position
+ON CONFLICT (property_id, url) DO UPDATE SET position = excluded.position;
+
+UPDATE property_images i
+SET position = ordered.position - 1,
+    is_cover = ordered.position = 1
+FROM (SELECT id, row_number() OVER (PARTITION BY property_id ORDER BY position, id) AS position FROM property_images) ordered
+WHERE ordered.id = i.id;
+
+CREATE INDEX IF NOT EXISTS property_images_property_id_position_idx ON property_images (property_id, position);
+ALTER TABLE properties DROP COLUMN image_urls;
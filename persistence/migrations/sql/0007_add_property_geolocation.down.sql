@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS properties_city_idx;
+DROP INDEX IF EXISTS properties_coordinates_idx;
+ALTER TABLE properties
+    DROP CONSTRAINT properties_coordinates_check,
+    DROP COLUMN longitude,
+    DROP COLUMN latitude,
+    DROP COLUMN postal_code,
+    DROP COLUMN neighborhood,
+    DROP COLUMN district,
+    DROP COLUMN city;
@@ -0,0 +1,15 @@
+ALTER TABLE properties
+    ADD COLUMN city         VARCHAR(255)     NOT NULL DEFAULT '',
+    ADD COLUMN district     VARCHAR(255)     NOT NULL DEFAULT '',
+    ADD COLUMN neighborhood VARCHAR(255)     NOT NULL DEFAULT '',
+    ADD COLUMN postal_code  VARCHAR(16)      NOT NULL DEFAULT '',
+    ADD COLUMN latitude     DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude    DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT properties_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- Locations are written as "City, Country"
+UPDATE properties SET city = btrim(split_part(location, ',', 1)) WHERE position(',' IN location) > 0;
+
+-- Radius and bounding box searches narrow the rows down by latitude first, then by longitude
+CREATE INDEX IF NOT EXISTS properties_coordinates_idx ON properties (latitude, longitude) WHERE latitude IS NOT NULL;
+CREATE INDEX IF NOT EXISTS properties_city_idx ON properties (lower(city));
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS property_status_changes;
+
+DROP INDEX IF EXISTS properties_status_idx;
+
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+-- Every existing listing is published, so it starts as active
+ALTER TABLE properties
+    ADD COLUMN status            VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('draft', 'active', 'under_offer', 'sold', 'archived')),
+    ADD COLUMN status_changed_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS properties_status_idx ON properties (status);
+
+CREATE TABLE IF NOT EXISTS property_status_changes
+(
+    id          BIGSERIAL PRIMARY KEY,
+    property_id BIGINT       NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    from_status VARCHAR(20)  NOT NULL,
+    to_status   VARCHAR(20)  NOT NULL,
+    actor       VARCHAR(255) NOT NULL DEFAULT '',
+    note        TEXT         NOT NULL DEFAULT '',
+    changed_at  TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS property_status_changes_property_idx ON property_status_changes (property_id, changed_at);
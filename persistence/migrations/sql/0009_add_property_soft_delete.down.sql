@@ -0,0 +1,6 @@
+-- Properties in the trash were deleted, so they are removed for good
+DELETE FROM properties WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS properties_deleted_at_idx;
+
+ALTER TABLE properties DROP COLUMN IF EXISTS deleted_at;
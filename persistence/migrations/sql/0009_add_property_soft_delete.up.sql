@@ -0,0 +1,4 @@
+-- Deleted properties stay in the trash until they are restored or purged after the retention period
+ALTER TABLE properties ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS properties_deleted_at_idx ON properties (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,27 @@
+-- Entries outlive the entities they describe, so there is no foreign key to properties
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id          BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(50)  NOT NULL,
+    entity_id   BIGINT       NOT NULL,
+    action      VARCHAR(20)  NOT NULL,
+    actor       VARCHAR(255) NOT NULL DEFAULT '',
+    request_id  VARCHAR(128) NOT NULL DEFAULT '',
+    changes     JSONB        NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE
+    ON audit_log
+    FOR EACH ROW
+EXECUTE FUNCTION audit_log_append_only();
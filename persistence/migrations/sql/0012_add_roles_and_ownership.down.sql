@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS properties_owner_id_idx;
+ALTER TABLE properties DROP COLUMN IF EXISTS owner_id;
+DROP INDEX IF EXISTS api_keys_user_id_idx;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS user_id;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
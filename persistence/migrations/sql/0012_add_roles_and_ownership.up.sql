@@ -0,0 +1,16 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'viewer'
+        CHECK (role IN ('admin', 'agent', 'viewer'));
+
+-- API keys now act as the user who created them, keys from before that have no user and are dropped
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users (id) ON DELETE CASCADE;
+DELETE FROM api_keys WHERE user_id IS NULL;
+ALTER TABLE api_keys
+    ALTER COLUMN user_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+
+-- owner_id is the user who created the listing, listings from before that have no owner and only admins change them
+ALTER TABLE properties
+    ADD COLUMN IF NOT EXISTS owner_id BIGINT REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS properties_owner_id_idx ON properties (owner_id);
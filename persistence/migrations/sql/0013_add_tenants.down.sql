@@ -0,0 +1,37 @@
+DROP POLICY IF EXISTS api_keys_tenant_isolation ON api_keys;
+ALTER TABLE api_keys
+    DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS users_tenant_isolation ON users;
+ALTER TABLE users
+    DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS property_status_changes_tenant_isolation ON property_status_changes;
+ALTER TABLE property_status_changes
+    DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS property_images_tenant_isolation ON property_images;
+ALTER TABLE property_images
+    DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS audit_log_tenant_isolation ON audit_log;
+ALTER TABLE audit_log
+    DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS agents_tenant_isolation ON agents;
+ALTER TABLE agents
+    DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS properties_tenant_isolation ON properties;
+ALTER TABLE properties
+    DISABLE ROW LEVEL SECURITY;
+
+DROP INDEX IF EXISTS audit_log_entity_idx;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS tenant_id;
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE properties DROP CONSTRAINT IF EXISTS properties_agent_id_fkey;
+ALTER TABLE properties
+    ADD CONSTRAINT properties_agent_id_fkey FOREIGN KEY (agent_id) REFERENCES agents (id) ON DELETE SET NULL;
+ALTER TABLE agents DROP CONSTRAINT IF EXISTS agents_id_tenant_id_key;
+DROP INDEX IF EXISTS agents_tenant_id_idx;
+ALTER TABLE agents DROP COLUMN IF EXISTS tenant_id;
+DROP INDEX IF EXISTS properties_tenant_id_idx;
+ALTER TABLE properties DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS tenant_hosts;
+DROP TABLE IF EXISTS tenants;
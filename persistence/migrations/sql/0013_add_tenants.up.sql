@@ -0,0 +1,113 @@
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id           BIGSERIAL PRIMARY KEY,
+    slug         VARCHAR(63)  NOT NULL UNIQUE,
+    name         VARCHAR(255) NOT NULL,
+    currency     CHAR(3)      NOT NULL DEFAULT 'TRY',
+    cors_origins TEXT[]       NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+-- a host serves exactly one tenant
+CREATE TABLE IF NOT EXISTS tenant_hosts
+(
+    host      VARCHAR(255) PRIMARY KEY,
+    tenant_id BIGINT       NOT NULL REFERENCES tenants (id) ON DELETE CASCADE
+);
+
+-- everything from before tenants belongs to the default tenant
+INSERT INTO tenants (slug, name)
+VALUES ('default', 'Default')
+ON CONFLICT (slug) DO NOTHING;
+
+ALTER TABLE properties
+    ADD COLUMN IF NOT EXISTS tenant_id BIGINT REFERENCES tenants (id);
+UPDATE properties
+SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default')
+WHERE tenant_id IS NULL;
+ALTER TABLE properties
+    ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS properties_tenant_id_idx ON properties (tenant_id);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS tenant_id BIGINT REFERENCES tenants (id);
+UPDATE users
+SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default')
+WHERE tenant_id IS NULL;
+ALTER TABLE users
+    ALTER COLUMN tenant_id SET NOT NULL;
+
+ALTER TABLE agents
+    ADD COLUMN IF NOT EXISTS tenant_id BIGINT REFERENCES tenants (id);
+UPDATE agents
+SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default')
+WHERE tenant_id IS NULL;
+ALTER TABLE agents
+    ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS agents_tenant_id_idx ON agents (tenant_id);
+ALTER TABLE agents
+    ADD CONSTRAINT agents_id_tenant_id_key UNIQUE (id, tenant_id);
+
+-- a property can only be listed by an agent of its own tenant, deleting the agent keeps the property without one;
+-- setting only agent_id to null on delete needs PostgreSQL 15 or later
+ALTER TABLE properties
+    DROP CONSTRAINT IF EXISTS properties_agent_id_fkey;
+ALTER TABLE properties
+    ADD CONSTRAINT properties_agent_id_fkey FOREIGN KEY (agent_id, tenant_id) REFERENCES agents (id, tenant_id) ON DELETE SET NULL (agent_id);
+
+ALTER TABLE audit_log
+    ADD COLUMN IF NOT EXISTS tenant_id BIGINT REFERENCES tenants (id);
+UPDATE audit_log
+SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default')
+WHERE tenant_id IS NULL;
+ALTER TABLE audit_log
+    ALTER COLUMN tenant_id SET NOT NULL;
+DROP INDEX IF EXISTS audit_log_entity_idx;
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (tenant_id, entity_type, entity_id, created_at);
+
+-- Row-level security only applies to roles that do not own the tables. The server sets kirmac.tenant_id on every
+-- connection it takes from the pool when postgresql.row_level_security is on, rows of other tenants are then invisible.
+-- Tables without a tenant_id follow the row they belong to.
+ALTER TABLE properties
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY properties_tenant_isolation ON properties
+    USING (tenant_id = nullif(current_setting('kirmac.tenant_id', true), '')::BIGINT);
+
+ALTER TABLE agents
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY agents_tenant_isolation ON agents
+    USING (tenant_id = nullif(current_setting('kirmac.tenant_id', true), '')::BIGINT);
+
+ALTER TABLE audit_log
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY audit_log_tenant_isolation ON audit_log
+    USING (tenant_id = nullif(current_setting('kirmac.tenant_id', true), '')::BIGINT);
+
+ALTER TABLE property_images
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY property_images_tenant_isolation ON property_images
+    USING (EXISTS (SELECT 1
+                   FROM properties p
+                   WHERE p.id = property_images.property_id
+                     AND p.tenant_id = nullif(current_setting('kirmac.tenant_id', true), '')::BIGINT));
+
+ALTER TABLE property_status_changes
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY property_status_changes_tenant_isolation ON property_status_changes
+    USING (EXISTS (SELECT 1
+                   FROM properties p
+                   WHERE p.id = property_status_changes.property_id
+                     AND p.tenant_id = nullif(current_setting('kirmac.tenant_id', true), '')::BIGINT));
+
+-- users and their API keys are found by username or key before the tenant of a request is known, so they are
+-- visible while no tenant is set and only the users of the tenant once it is
+ALTER TABLE users
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY users_tenant_isolation ON users
+    USING (nullif(current_setting('kirmac.tenant_id', true), '') IS NULL
+        OR tenant_id = nullif(current_setting('kirmac.tenant_id', true), '')::BIGINT);
+
+ALTER TABLE api_keys
+    ENABLE ROW LEVEL SECURITY;
+CREATE POLICY api_keys_tenant_isolation ON api_keys
+    USING (EXISTS (SELECT 1 FROM users u WHERE u.id = api_keys.user_id));
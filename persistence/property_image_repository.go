@@ -0,0 +1,116 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"kirmac-site-backend/domain"
+)
+
+const (
+	propertyImageColumns     = `id, url, alt, caption, position, coalesce(width, 0), coalesce(height, 0), is_cover, variants`
+	getPropertyImagesQuery   = `SELECT ` + propertyImageColumns + ` FROM property_images WHERE property_id = $1 ORDER BY position, id`
+	addPropertyImageQuery    = `INSERT INTO property_images (property_id, url, alt, caption, position, width, height, is_cover, variants) VALUES ($1, $2, $3, $4, $5, nullif($6, 0), nullif($7, 0), $8, $9) RETURNING id`
+	updatePropertyImageQuery = `UPDATE property_images SET alt = $1, caption = $2, position = $3, is_cover = $4 WHERE property_id = $5 AND id = $6`
+	deletePropertyImageQuery = `DELETE FROM property_images WHERE property_id = $1 AND id = $2`
+)
+
+// IPropertyImageRepository is an interface for the repository of property images
+type IPropertyImageRepository interface {
+	GetImages(ctx context.Context, propertyID int64) ([]domain.PropertyImage, error)
+	AddImage(ctx context.Context, propertyID int64, image domain.PropertyImage) (domain.PropertyImage, error)
+	UpdateImage(ctx context.Context, propertyID int64, image domain.PropertyImage) error
+	DeleteImage(ctx context.Context, propertyID int64, imageID int64) (bool, error)
+}
+
+// PropertyImageRepository is a struct for the repository of property images
+type PropertyImageRepository struct {
+	dbPool   *pgxpool.Pool
+	timeouts QueryTimeouts
+}
+
+// NewPropertyImageRepository creates a new repository of property images
+func NewPropertyImageRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) IPropertyImageRepository {
+	return &PropertyImageRepository{dbPool: dbPool, timeouts: timeouts}
+}
+
+// db returns the transaction ctx runs in, or the pool
+func (imageRepository *PropertyImageRepository) db(ctx context.Context) Querier {
+	return querier(ctx, imageRepository.dbPool)
+}
+
+// GetImages gets the images of a property ordered by position
+func (imageRepository *PropertyImageRepository) GetImages(ctx context.Context, propertyID int64) ([]domain.PropertyImage, error) {
+	ctx, cancel := withTimeout(ctx, imageRepository.timeouts.Read)
+	defer cancel()
+	rows, err := imageRepository.db(ctx).Query(ctx, getPropertyImagesQuery, propertyID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read images: %w", translateError(err))
+	}
+	defer rows.Close()
+
+	images := []domain.PropertyImage{}
+	for rows.Next() {
+		image, err := scanPropertyImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return images, nil
+}
+
+// AddImage adds an image to a property and returns it with its generated id
+func (imageRepository *PropertyImageRepository) AddImage(ctx context.Context, propertyID int64, image domain.PropertyImage) (domain.PropertyImage, error) {
+	ctx, cancel := withTimeout(ctx, imageRepository.timeouts.Write)
+	defer cancel()
+	variants := []byte("{}")
+	if len(image.Variants) > 0 {
+		var err error
+		if variants, err = json.Marshal(image.Variants); err != nil {
+			return domain.PropertyImage{}, err
+		}
+	}
+	err := imageRepository.db(ctx).QueryRow(ctx, addPropertyImageQuery,
+		propertyID, image.URL, image.Alt, image.Caption, image.Position, image.Width, image.Height, image.IsCover, variants).Scan(&image.ID)
+	if err != nil {
+		return domain.PropertyImage{}, fmt.Errorf("unable to add image: %w", translateError(err))
+	}
+	return image, nil
+}
+
+// UpdateImage saves the alt text, caption, position and cover flag of an image, its file and size never change
+func (imageRepository *PropertyImageRepository) UpdateImage(ctx context.Context, propertyID int64, image domain.PropertyImage) error {
+	ctx, cancel := withTimeout(ctx, imageRepository.timeouts.Write)
+	defer cancel()
+	cmdTag, err := imageRepository.db(ctx).Exec(ctx, updatePropertyImageQuery, image.Alt, image.Caption, image.Position, image.IsCover, propertyID, image.ID)
+	if err != nil {
+		return fmt.Errorf("unable to update image: %w", translateError(err))
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("image %d of property %d: %w", image.ID, propertyID, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteImage deletes an image of a property
+func (imageRepository *PropertyImageRepository) DeleteImage(ctx context.Context, propertyID int64, imageID int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx, imageRepository.timeouts.Write)
+	defer cancel()
+	cmdTag, err := imageRepository.db(ctx).Exec(ctx, deletePropertyImageQuery, propertyID, imageID)
+	if err != nil {
+		return false, fmt.Errorf("unable to delete image: %w", translateError(err))
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+func scanPropertyImage(row pgx.Row) (domain.PropertyImage, error) {
+	var image domain.PropertyImage
+	err := row.Scan(&image.ID, &image.URL, &image.Alt, &image.Caption, &image.Position, &image.Width, &image.Height, &image.IsCover, &image.Variants)
+	return image, err
+}
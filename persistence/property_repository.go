@@ -2,152 +2,816 @@ package persistence
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v3/log"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/lib/pq"
 	"kirmac-site-backend/domain"
+	"strings"
+	"time"
 )
 
 const (
-	getAllPropertiesQuery = `SELECT id, location, price, title, description, bedrooms, bathrooms, square_feet, agent_name, agent_title, image_urls FROM properties`
-	getPropertyByIdQuery  = `SELECT id, location, price, title, description, bedrooms, bathrooms, square_feet, agent_name, agent_title, image_urls FROM properties WHERE id = $1`
-	addPropertyQuery      = `INSERT INTO properties (location, price, title, description, bedrooms, bathrooms, square_feet, agent_name, agent_title, image_urls) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
-	deletePropertyQuery   = `DELETE FROM properties WHERE id = $1`
-	updatePropertyQuery   = `UPDATE properties SET location = $1, price = $2, title = $3, description = $4, bedrooms = $5, bathrooms = $6, square_feet = $7, agent_name = $8, agent_title = $9, image_urls = $10 WHERE id = $11`
+	getAllPropertiesQuery  = `SELECT ` + propertyColumns + ` FROM properties p LEFT JOIN agents a ON a.id = p.agent_id`
+	countPropertiesQuery   = `SELECT COUNT(*) FROM properties p LEFT JOIN agents a ON a.id = p.agent_id`
+	getPropertyByIdQuery   = getAllPropertiesQuery + ` WHERE p.id = $1 AND p.tenant_id = $tenant AND p.deleted_at IS NULL`
+	lockPropertyByIdQuery  = getPropertyByIdQuery + ` FOR UPDATE OF p`
+	lockDeletedByIdQuery   = getAllPropertiesQuery + ` WHERE p.id = $1 AND p.tenant_id = $tenant AND p.deleted_at IS NOT NULL FOR UPDATE OF p`
+	getDeletedQuery        = getAllPropertiesQuery + ` WHERE p.tenant_id = $tenant AND p.deleted_at IS NOT NULL ORDER BY p.deleted_at DESC, p.id LIMIT $1 OFFSET $2`
+	countDeletedQuery      = `SELECT COUNT(*) FROM properties WHERE tenant_id = $tenant AND deleted_at IS NOT NULL`
+	clusterPropertiesQuery = `SELECT avg(p.latitude), avg(p.longitude), count(*), min(p.price), max(p.price) FROM properties p LEFT JOIN agents a ON a.id = p.agent_id`
+	nearbyPropertiesQuery  = `SELECT ` + propertyColumns + `, %s AS distance_km FROM properties p LEFT JOIN agents a ON a.id = p.agent_id`
+	addPropertyQuery       = `INSERT INTO properties (location, city, district, neighborhood, postal_code, latitude, longitude, price, title, description, bedrooms, bathrooms, square_feet, agent_id, status, owner_id, tenant_id) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $tenant) RETURNING id`
+	deletePropertyQuery  = `UPDATE properties SET deleted_at = now(), version = version + 1 WHERE id = $1 AND tenant_id = $tenant`
+	restorePropertyQuery = `UPDATE properties SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND tenant_id = $tenant AND deleted_at IS NOT NULL`
+	purgePropertiesQuery = `DELETE FROM properties WHERE tenant_id = $tenant AND deleted_at < $1`
+	purgedImagesQuery    = `SELECT ` + propertyImageColumns + ` FROM property_images WHERE property_id IN (SELECT id FROM properties WHERE tenant_id = $tenant AND deleted_at < $1 FOR UPDATE)`
+	updatePropertyQuery  = `UPDATE properties SET location = $1, city = $2, district = $3, neighborhood = $4, postal_code = $5, latitude = $6, longitude = $7, ` +
+		`price = $8, title = $9, description = $10, bedrooms = $11, bathrooms = $12, square_feet = $13, agent_id = $14, version = version + 1 WHERE id = $15 AND tenant_id = $tenant`
+	lockPropertyQuery    = `SELECT version FROM properties WHERE id = $1 AND tenant_id = $tenant AND deleted_at IS NULL FOR UPDATE`
+	touchPropertyQuery   = `UPDATE properties SET version = version + 1 WHERE id = $1 AND tenant_id = $tenant`
+	setStatusQuery       = `UPDATE properties SET status = $2, status_changed_at = now(), version = version + 1 WHERE id = $1 AND tenant_id = $tenant AND status = $3`
+	addStatusChangeQuery = `INSERT INTO property_status_changes (property_id, from_status, to_status, actor, note) ` +
+		`SELECT id, $2, $3, $4, $5 FROM properties WHERE id = $1 AND tenant_id = $tenant`
+	getStatusChangesQuery = `SELECT s.id, s.property_id, s.from_status, s.to_status, s.actor, s.note, s.changed_at FROM property_status_changes s ` +
+		`JOIN properties p ON p.id = s.property_id WHERE s.property_id = $1 AND p.tenant_id = $tenant ORDER BY s.changed_at, s.id`
+	searchPropertiesQuery = `WITH search AS (SELECT websearch_to_tsquery('turkish', $1) AS query)
+		SELECT ` + propertyColumns + `,
+			ts_rank(` + propertySearchVector + `, search.query)::float8 AS rank,
+			ts_headline('turkish', p.title, search.query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('turkish', p.description, search.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5'),
+			ts_headline('turkish', p.location, search.query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+		FROM properties p LEFT JOIN agents a ON a.id = p.agent_id, search
+		WHERE ` + propertySearchVector + ` @@ search.query AND p.tenant_id = $tenant AND p.status = ANY($4) AND p.deleted_at IS NULL
+		ORDER BY rank DESC, p.id
+		LIMIT $2 OFFSET $3`
+	countSearchQuery = `SELECT count(*) FROM properties p, websearch_to_tsquery('turkish', $1) AS query
+		WHERE ` + propertySearchVector + ` @@ query AND p.tenant_id = $tenant AND p.status = ANY($2) AND p.deleted_at IS NULL`
 )
 
-// IPropertyRepository is an interface for the property repository
+// propertyColumns selects a property aliased p together with the name and title of its agent aliased a
+const propertyColumns = `p.id, p.location, p.city, p.district, p.neighborhood, p.postal_code, p.latitude, p.longitude, p.price, p.title, p.description, p.bedrooms, p.bathrooms, p.square_feet, ` +
+	`p.agent_id, coalesce(a.name, ''), coalesce(a.title, ''), ` + propertyImagesColumn + `, p.status, p.status_changed_at, p.deleted_at, p.version, p.owner_id`
+
+// propertyImagesColumn lists the images of p ordered by position, the keys match the json names of domain.PropertyImage
+const propertyImagesColumn = `(SELECT coalesce(jsonb_agg(jsonb_strip_nulls(jsonb_build_object('id', i.id, 'url', i.url, 'alt', i.alt, 'caption', i.caption, ` +
+	`'position', i.position, 'width', i.width, 'height', i.height, 'is_cover', i.is_cover, 'variants', i.variants)) ORDER BY i.position, i.id), '[]') ` +
+	`FROM property_images i WHERE i.property_id = p.id)`
+
+// propertySearchVector weights title matches above location matches and location matches above description matches
+const propertySearchVector = `(setweight(to_tsvector('turkish', coalesce(p.title, '')), 'A') || ` +
+	`setweight(to_tsvector('turkish', coalesce(p.location, '')), 'B') || ` +
+	`setweight(to_tsvector('turkish', coalesce(p.description, '')), 'C'))`
+
+// patchableColumns maps the json names of domain.Property fields to the value of their column, json names equal column names
+var patchableColumns = map[string]func(property domain.Property) interface{}{
+	"location":     func(property domain.Property) interface{} { return property.Location },
+	"city":         func(property domain.Property) interface{} { return property.City },
+	"district":     func(property domain.Property) interface{} { return property.District },
+	"neighborhood": func(property domain.Property) interface{} { return property.Neighborhood },
+	"postal_code":  func(property domain.Property) interface{} { return property.PostalCode },
+	"latitude":     func(property domain.Property) interface{} { return property.Latitude },
+	"longitude":    func(property domain.Property) interface{} { return property.Longitude },
+	"price":        func(property domain.Property) interface{} { return property.Price },
+	"title":        func(property domain.Property) interface{} { return property.Title },
+	"description":  func(property domain.Property) interface{} { return property.Description },
+	"bedrooms":     func(property domain.Property) interface{} { return property.Bedrooms },
+	"bathrooms":    func(property domain.Property) interface{} { return property.Bathrooms },
+	"square_feet":  func(property domain.Property) interface{} { return property.SquareFeet },
+	"agent_id":     func(property domain.Property) interface{} { return property.AgentID },
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// IPropertyRepository is an interface for the property repository, every method only sees the properties of the tenant of ctx
 type IPropertyRepository interface {
-	GetAllProperties() ([]domain.Property, error)
-	GetPropertyById(id int64) (domain.Property, error)
-	AddProperty(property domain.Property) domain.Property
-	DeleteById(id int64) (bool, error)
-	UpdateProperty(id int64, property domain.Property) error
+	GetAllProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyPage, error)
+	GetNearbyProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyDistancePage, error)
+	QueryProperties(ctx context.Context, criteria domain.PropertyCriteria, limit int) (PropertyRows, error)
+	GetPropertyClusters(ctx context.Context, criteria domain.PropertyCriteria, cellDegrees float64) ([]domain.PropertyCluster, error)
+	GetPropertyById(ctx context.Context, id int64) (domain.Property, error)
+	GetPropertyForUpdate(ctx context.Context, id int64) (domain.Property, error)
+	GetDeletedPropertyForUpdate(ctx context.Context, id int64) (domain.Property, error)
+	AddProperty(ctx context.Context, property domain.Property) (domain.Property, error)
+	DeleteById(ctx context.Context, id int64, version int64) (bool, error)
+	UpdateProperty(ctx context.Context, id int64, property domain.Property) (domain.Property, error)
+	PatchProperty(ctx context.Context, id int64, property domain.Property, fields []string) (domain.Property, error)
+	Search(ctx context.Context, query string, page int, pageSize int) (domain.PropertySearchPage, error)
+	TouchProperty(ctx context.Context, id int64, version int64) error
+	TransitionProperty(ctx context.Context, id int64, change domain.StatusChange, version int64) (domain.Property, error)
+	GetStatusChanges(ctx context.Context, id int64) ([]domain.StatusChange, error)
+	GetDeletedProperties(ctx context.Context, page int, pageSize int) (domain.PropertyPage, error)
+	RestoreProperty(ctx context.Context, id int64) (domain.Property, error)
+	PurgeDeletedProperties(ctx context.Context, deletedBefore time.Time) (int64, []domain.PropertyImage, error)
+}
+
+// PropertyRows reads properties one row at a time, it must be closed once done
+type PropertyRows interface {
+	Next() bool
+	Property() (domain.Property, error)
+	Err() error
+	Close()
+}
+
+// QueryTimeouts limits how long each kind of query may run, the request context may end it sooner
+type QueryTimeouts struct {
+	Read   time.Duration
+	Write  time.Duration
+	Search time.Duration
+}
+
+// withTimeout bounds ctx by timeout, a zero timeout leaves ctx unchanged
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // PropertyRepository is a struct for the property repository
 type PropertyRepository struct {
-	dbPool *pgxpool.Pool
+	dbPool       *pgxpool.Pool
+	transactions ITransactionManager
+	timeouts     QueryTimeouts
 }
 
 // NewPropertyRepository creates a new property repository
-func NewPropertyRepository(dbPool *pgxpool.Pool) IPropertyRepository {
-	return &PropertyRepository{dbPool: dbPool}
+func NewPropertyRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) IPropertyRepository {
+	return &PropertyRepository{dbPool: dbPool, transactions: NewTransactionManager(dbPool), timeouts: timeouts}
 }
 
-func (propertyRepository *PropertyRepository) GetAllProperties() ([]domain.Property, error) {
-	ctx := context.Background()
-	propertiesRows, err := propertyRepository.dbPool.Query(ctx, getAllPropertiesQuery)
+// db returns the transaction ctx runs in, or the pool, scoped to the tenant of ctx
+func (propertyRepository *PropertyRepository) db(ctx context.Context) Querier {
+	return scoped(ctx, querier(ctx, propertyRepository.dbPool))
+}
+
+// GetAllProperties gets a page of properties matching the criteria
+func (propertyRepository *PropertyRepository) GetAllProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyPage, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+	where, args := buildPropertyFilter(criteria)
+
+	var totalCount int64
+	err := propertyRepository.db(ctx).QueryRow(ctx, countPropertiesQuery+where, args...).Scan(&totalCount)
 	if err != nil {
 		log.Errorf("Veritabanı sorgusu hatası: %v", err)
-		return nil, err
+		return domain.PropertyPage{}, translateError(err)
+	}
+
+	query := fmt.Sprintf("%s%s ORDER BY %s %s, p.id LIMIT $%d OFFSET $%d",
+		getAllPropertiesQuery, where, "p."+criteria.SortField, criteria.SortDirection, len(args)+1, len(args)+2)
+	args = append(args, criteria.PageSize, criteria.Offset())
+	propertiesRows, err := propertyRepository.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		log.Errorf("Veritabanı sorgusu hatası: %v", err)
+		return domain.PropertyPage{}, translateError(err)
 	}
 	defer propertiesRows.Close()
 
 	properties, err := propertyRepository.scanProperties(propertiesRows)
 	if err != nil {
 		log.Errorf("Satırları tarama hatası: %v", err)
-		return nil, err
+		return domain.PropertyPage{}, translateError(err)
 	}
 
 	if len(properties) == 0 {
 		log.Info("Hiç mülk bulunamadı")
 	}
 
-	return properties, nil
+	return domain.PropertyPage{
+		Properties: properties,
+		TotalCount: totalCount,
+		Page:       criteria.Page,
+		PageSize:   criteria.PageSize,
+	}, nil
+}
+
+// GetNearbyProperties gets a page of properties within criteria.Near that match the other criteria, nearest first
+func (propertyRepository *PropertyRepository) GetNearbyProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyDistancePage, error) {
+	if criteria.Near == nil {
+		return domain.PropertyDistancePage{}, fmt.Errorf("nearby properties need a center and radius")
+	}
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+	where, args := buildPropertyFilter(criteria)
+
+	var totalCount int64
+	err := propertyRepository.db(ctx).QueryRow(ctx, countPropertiesQuery+where, args...).Scan(&totalCount)
+	if err != nil {
+		log.Errorf("Veritabanı sorgusu hatası: %v", err)
+		return domain.PropertyDistancePage{}, translateError(err)
+	}
+
+	query := fmt.Sprintf(nearbyPropertiesQuery, distanceKm(len(args)+1, len(args)+2)) +
+		fmt.Sprintf("%s ORDER BY distance_km, p.id LIMIT $%d OFFSET $%d", where, len(args)+3, len(args)+4)
+	args = append(args, criteria.Near.Center.Latitude, criteria.Near.Center.Longitude, criteria.PageSize, criteria.Offset())
+	rows, err := propertyRepository.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		log.Errorf("Veritabanı sorgusu hatası: %v", err)
+		return domain.PropertyDistancePage{}, translateError(err)
+	}
+	defer rows.Close()
+
+	var results []domain.PropertyDistance
+	for rows.Next() {
+		var result domain.PropertyDistance
+		if err := rows.Scan(append(propertyFields(&result.Property), &result.DistanceKm)...); err != nil {
+			return domain.PropertyDistancePage{}, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.PropertyDistancePage{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return domain.PropertyDistancePage{
+		Results:    results,
+		TotalCount: totalCount,
+		Page:       criteria.Page,
+		PageSize:   criteria.PageSize,
+	}, nil
+}
+
+// QueryProperties runs the query for at most limit properties matching the criteria in their sort order without paging,
+// the rows are read while they are consumed so large results are never held in memory
+func (propertyRepository *PropertyRepository) QueryProperties(ctx context.Context, criteria domain.PropertyCriteria, limit int) (PropertyRows, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Search)
+	where, args := buildPropertyFilter(criteria)
+	query := fmt.Sprintf("%s%s ORDER BY %s %s, p.id LIMIT $%d",
+		getAllPropertiesQuery, where, "p."+criteria.SortField, criteria.SortDirection, len(args)+1)
+	rows, err := propertyRepository.db(ctx).Query(ctx, query, append(args, limit)...)
+	if err != nil {
+		cancel()
+		log.Errorf("Veritabanı sorgusu hatası: %v", err)
+		return nil, translateError(err)
+	}
+	return &propertyRows{rows: rows, cancel: cancel}, nil
+}
+
+// GetPropertyClusters groups the properties with coordinates matching the criteria into square grid cells of cellDegrees, largest first
+func (propertyRepository *PropertyRepository) GetPropertyClusters(ctx context.Context, criteria domain.PropertyCriteria, cellDegrees float64) ([]domain.PropertyCluster, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Search)
+	defer cancel()
+	criteria.HasCoordinates = true
+	where, args := buildPropertyFilter(criteria)
+	query := fmt.Sprintf("%s%s GROUP BY floor(p.latitude / $%[3]d), floor(p.longitude / $%[3]d) ORDER BY count(*) DESC, 1, 2",
+		clusterPropertiesQuery, where, len(args)+1)
+	rows, err := propertyRepository.db(ctx).Query(ctx, query, append(args, cellDegrees)...)
+	if err != nil {
+		log.Errorf("Veritabanı sorgusu hatası: %v", err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var clusters []domain.PropertyCluster
+	for rows.Next() {
+		var cluster domain.PropertyCluster
+		if err := rows.Scan(&cluster.Center.Latitude, &cluster.Center.Longitude, &cluster.Count, &cluster.MinPrice, &cluster.MaxPrice); err != nil {
+			return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		clusters = append(clusters, cluster)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return clusters, nil
 }
 
 // GetPropertyById gets a property by id
-func (propertyRepository *PropertyRepository) GetPropertyById(id int64) (domain.Property, error) {
+func (propertyRepository *PropertyRepository) GetPropertyById(ctx context.Context, id int64) (domain.Property, error) {
 	var p domain.Property
 
-	ctx := context.Background()
-	row := propertyRepository.dbPool.QueryRow(ctx, getPropertyByIdQuery, id)
-	err := row.Scan(
-		&p.ID,
-		&p.Location,
-		&p.Price,
-		&p.Title,
-		&p.Description,
-		&p.Bedrooms,
-		&p.Bathrooms,
-		&p.SquareFeet,
-		&p.AgentName,
-		&p.AgentTitle,
-		pq.Array(&p.ImageURLs),
-	)
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+	row := propertyRepository.db(ctx).QueryRow(ctx, getPropertyByIdQuery, id)
+	err := row.Scan(propertyFields(&p)...)
+	if err == pgx.ErrNoRows {
+		return domain.Property{}, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+	}
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return domain.Property{}, fmt.Errorf("property not found: %v", err)
-		}
-		return domain.Property{}, fmt.Errorf("unable to read row: %v", err)
+		return domain.Property{}, fmt.Errorf("unable to read row: %w", translateError(err))
 	}
 
 	return p, nil
 }
 
-// AddProperty adds a property
-func (propertyRepository *PropertyRepository) AddProperty(property domain.Property) domain.Property {
-	ctx := context.Background()
-	var id int64
-	err := propertyRepository.dbPool.QueryRow(ctx, addPropertyQuery, property.Location, property.Price, property.Title, property.Description, property.Bedrooms, property.Bathrooms, property.SquareFeet, property.AgentName, property.AgentTitle, pq.Array(property.ImageURLs)).Scan(&id)
+// GetPropertyForUpdate gets a property by id and locks its row until the transaction of ctx ends,
+// so the property stays as read until the change made from it is saved
+func (propertyRepository *PropertyRepository) GetPropertyForUpdate(ctx context.Context, id int64) (domain.Property, error) {
+	var p domain.Property
+
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+	err := propertyRepository.db(ctx).QueryRow(ctx, lockPropertyByIdQuery, id).Scan(propertyFields(&p)...)
+	if err == pgx.ErrNoRows {
+		return domain.Property{}, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.Property{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return p, nil
+}
+
+// GetDeletedPropertyForUpdate gets a property in the trash by id and locks its row until the transaction of ctx ends
+func (propertyRepository *PropertyRepository) GetDeletedPropertyForUpdate(ctx context.Context, id int64) (domain.Property, error) {
+	var p domain.Property
+
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+	err := propertyRepository.db(ctx).QueryRow(ctx, lockDeletedByIdQuery, id).Scan(propertyFields(&p)...)
+	if err == pgx.ErrNoRows {
+		return domain.Property{}, fmt.Errorf("deleted property %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.Property{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return p, nil
+}
+
+// AddProperty adds a property and returns it as stored, with its generated id and the name and title of its agent.
+// A property without a status is active.
+func (propertyRepository *PropertyRepository) AddProperty(ctx context.Context, property domain.Property) (domain.Property, error) {
+	if property.Status == "" {
+		property.Status = domain.StatusActive
+	}
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	var added domain.Property
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		var id int64
+		err := propertyRepository.db(ctx).QueryRow(ctx, addPropertyQuery, property.Location, property.City, property.District, property.Neighborhood, property.PostalCode, property.Latitude, property.Longitude, property.Price, property.Title, property.Description, property.Bedrooms, property.Bathrooms, property.SquareFeet, property.AgentID, property.Status, property.OwnerID).Scan(&id)
+		if err != nil {
+			log.Errorf("Unable to add property: %v\n", err)
+			return fmt.Errorf("unable to add property: %w", translateError(err))
+		}
+		added, err = propertyRepository.GetPropertyById(ctx, id)
+		return err
+	})
+	if err != nil {
+		return domain.Property{}, err
+	}
+	return added, nil
+}
+
+// DeleteById moves a property to the trash, a non-zero version must match the stored version.
+// Deleted properties are left out of every other read until they are restored or purged.
+func (propertyRepository *PropertyRepository) DeleteById(ctx context.Context, id int64, version int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	deleted := false
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		err := propertyRepository.lockProperty(ctx, id, version)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil // Nothing to delete, but also no error
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := propertyRepository.db(ctx).Exec(ctx, deletePropertyQuery, id); err != nil {
+			return fmt.Errorf("unable to delete property: %w", translateError(err))
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// GetDeletedProperties gets a page of the properties in the trash, most recently deleted first
+func (propertyRepository *PropertyRepository) GetDeletedProperties(ctx context.Context, page int, pageSize int) (domain.PropertyPage, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+
+	var totalCount int64
+	if err := propertyRepository.db(ctx).QueryRow(ctx, countDeletedQuery).Scan(&totalCount); err != nil {
+		return domain.PropertyPage{}, fmt.Errorf("unable to count deleted properties: %w", translateError(err))
+	}
+	rows, err := propertyRepository.db(ctx).Query(ctx, getDeletedQuery, pageSize, domain.PageOffset(page, pageSize))
+	if err != nil {
+		return domain.PropertyPage{}, fmt.Errorf("unable to read deleted properties: %w", translateError(err))
+	}
+	defer rows.Close()
+	properties, err := propertyRepository.scanProperties(rows)
+	if err != nil {
+		return domain.PropertyPage{}, translateError(err)
+	}
+	return domain.PropertyPage{
+		Properties: properties,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// RestoreProperty takes a property out of the trash and returns it with its new version
+func (propertyRepository *PropertyRepository) RestoreProperty(ctx context.Context, id int64) (domain.Property, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	var restored domain.Property
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		tag, err := propertyRepository.db(ctx).Exec(ctx, restorePropertyQuery, id)
+		if err != nil {
+			return fmt.Errorf("unable to restore property: %w", translateError(err))
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("deleted property %d: %w", id, domain.ErrNotFound)
+		}
+		restored, err = propertyRepository.GetPropertyById(ctx, id)
+		return err
+	})
+	if err != nil {
+		return domain.Property{}, err
+	}
+	return restored, nil
+}
+
+// PurgeDeletedProperties permanently removes the properties of the tenant deleted before deletedBefore together with their images and history,
+// and returns how many were removed with the images they had so that their stored files can be deleted once the removal is committed
+func (propertyRepository *PropertyRepository) PurgeDeletedProperties(ctx context.Context, deletedBefore time.Time) (int64, []domain.PropertyImage, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	var purged int64
+	var images []domain.PropertyImage
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		// locks the purged properties so that none is restored between reading their images and removing them
+		rows, err := propertyRepository.db(ctx).Query(ctx, purgedImagesQuery, deletedBefore)
+		if err != nil {
+			return fmt.Errorf("unable to read purged images: %w", translateError(err))
+		}
+		defer rows.Close()
+		images = []domain.PropertyImage{}
+		for rows.Next() {
+			image, err := scanPropertyImage(rows)
+			if err != nil {
+				return fmt.Errorf("unable to read row: %w", translateError(err))
+			}
+			images = append(images, image)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		rows.Close()
+
+		tag, err := propertyRepository.db(ctx).Exec(ctx, purgePropertiesQuery, deletedBefore)
+		if err != nil {
+			return fmt.Errorf("unable to purge deleted properties: %w", translateError(err))
+		}
+		purged = tag.RowsAffected()
+		return nil
+	})
 	if err != nil {
-		log.Errorf("Unable to add property: %v\n", err)
-		return domain.Property{} // Consider handling the error more gracefully
+		return 0, nil, err
 	}
-	property.ID = id
-	return property
+	return purged, images, nil
 }
 
-// DeleteById deletes a property by id
-func (propertyRepository *PropertyRepository) DeleteById(id int64) (bool, error) {
-	ctx := context.Background()
-	cmdTag, err := propertyRepository.dbPool.Exec(ctx, deletePropertyQuery, id)
+// UpdateProperty replaces every column of a property in place and returns it as stored with its new version.
+// A non-zero property.Version must match the stored version.
+func (propertyRepository *PropertyRepository) UpdateProperty(ctx context.Context, id int64, property domain.Property) (domain.Property, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	var updated domain.Property
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		if err := propertyRepository.lockProperty(ctx, id, property.Version); err != nil {
+			return err
+		}
+		_, err := propertyRepository.db(ctx).Exec(ctx, updatePropertyQuery,
+			property.Location,
+			property.City,
+			property.District,
+			property.Neighborhood,
+			property.PostalCode,
+			property.Latitude,
+			property.Longitude,
+			property.Price,
+			property.Title,
+			property.Description,
+			property.Bedrooms,
+			property.Bathrooms,
+			property.SquareFeet,
+			property.AgentID,
+			id)
+		if err != nil {
+			return fmt.Errorf("unable to update property: %w", translateError(err))
+		}
+		updated, err = propertyRepository.GetPropertyById(ctx, id)
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("unable to delete property: %v", err)
+		return domain.Property{}, err
 	}
-	if cmdTag.RowsAffected() == 0 {
-		return false, nil // No rows affected, but also no error
+	return updated, nil
+}
+
+// PatchProperty updates only the columns of the given fields, fields are the json names of domain.Property.
+// A non-zero property.Version must match the stored version.
+func (propertyRepository *PropertyRepository) PatchProperty(ctx context.Context, id int64, property domain.Property, fields []string) (domain.Property, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	assignments := make([]string, 0, len(fields)+1)
+	args := make([]interface{}, 0, len(fields)+1)
+	for _, field := range fields {
+		columnValue, ok := patchableColumns[field]
+		if !ok {
+			return domain.Property{}, fmt.Errorf("field %s cannot be patched", field)
+		}
+		args = append(args, columnValue(property))
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", field, len(args)))
 	}
-	return true, nil
+	assignments = append(assignments, "version = version + 1")
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE properties SET %s WHERE id = $%d AND tenant_id = %s", strings.Join(assignments, ", "), len(args), tenantParam)
+
+	var patched domain.Property
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		if err := propertyRepository.lockProperty(ctx, id, property.Version); err != nil {
+			return err
+		}
+		if _, err := propertyRepository.db(ctx).Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("unable to patch property: %w", translateError(err))
+		}
+		var err error
+		patched, err = propertyRepository.GetPropertyById(ctx, id)
+		return err
+	})
+	if err != nil {
+		return domain.Property{}, err
+	}
+	return patched, nil
+}
+
+// TouchProperty increases the version of a property whose images changed, and keeps it locked until the transaction of ctx ends.
+// A non-zero version must match the stored version.
+func (propertyRepository *PropertyRepository) TouchProperty(ctx context.Context, id int64, version int64) error {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+	return propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		if err := propertyRepository.lockProperty(ctx, id, version); err != nil {
+			return err
+		}
+		if _, err := propertyRepository.db(ctx).Exec(ctx, touchPropertyQuery, id); err != nil {
+			return fmt.Errorf("unable to update property: %w", translateError(err))
+		}
+		return nil
+	})
 }
 
-// UpdateProperty updates a property
-func (propertyRepository *PropertyRepository) UpdateProperty(id int64, property domain.Property) error {
-	ctx := context.Background()
+// TransitionProperty moves a property from change.FromStatus to change.ToStatus and records the change.
+// It fails with domain.ErrConflict when the property is no longer in change.FromStatus, a non-zero version must match the stored version.
+func (propertyRepository *PropertyRepository) TransitionProperty(ctx context.Context, id int64, change domain.StatusChange, version int64) (domain.Property, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Write)
+	defer cancel()
+
+	var transitioned domain.Property
+	err := propertyRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		if err := propertyRepository.lockProperty(ctx, id, version); err != nil {
+			return err
+		}
+		tag, err := propertyRepository.db(ctx).Exec(ctx, setStatusQuery, id, change.ToStatus, change.FromStatus)
+		if err != nil {
+			return fmt.Errorf("unable to update property status: %w", translateError(err))
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("property %d is no longer %s: %w", id, change.FromStatus, domain.ErrConflict)
+		}
+		_, err = propertyRepository.db(ctx).Exec(ctx, addStatusChangeQuery, id, change.FromStatus, change.ToStatus, change.Actor, change.Note)
+		if err != nil {
+			return fmt.Errorf("unable to record status change: %w", translateError(err))
+		}
+		transitioned, err = propertyRepository.GetPropertyById(ctx, id)
+		return err
+	})
+	if err != nil {
+		return domain.Property{}, err
+	}
+	return transitioned, nil
+}
 
-	_, err := propertyRepository.dbPool.Exec(ctx, updatePropertyQuery,
-		property.Location,
-		property.Price,
-		property.Title,
-		property.Description,
-		property.Bedrooms,
-		property.Bathrooms,
-		property.SquareFeet,
-		property.AgentName,
-		property.AgentTitle,
-		pq.Array(property.ImageURLs),
-		id)
+// GetStatusChanges lists the status changes of a property, oldest first
+func (propertyRepository *PropertyRepository) GetStatusChanges(ctx context.Context, id int64) ([]domain.StatusChange, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Read)
+	defer cancel()
+	rows, err := propertyRepository.db(ctx).Query(ctx, getStatusChangesQuery, id)
 	if err != nil {
-		return fmt.Errorf("unable to update property: %v", err)
+		return nil, fmt.Errorf("unable to read status changes: %w", translateError(err))
+	}
+	defer rows.Close()
+
+	changes := []domain.StatusChange{}
+	for rows.Next() {
+		var change domain.StatusChange
+		err := rows.Scan(&change.ID, &change.PropertyID, &change.FromStatus, &change.ToStatus, &change.Actor, &change.Note, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return changes, nil
+}
+
+// lockProperty locks the row of a property until the transaction of ctx ends,
+// a non-zero version must match the stored version
+func (propertyRepository *PropertyRepository) lockProperty(ctx context.Context, id int64, version int64) error {
+	var stored int64
+	err := propertyRepository.db(ctx).QueryRow(ctx, lockPropertyQuery, id).Scan(&stored)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
 	}
-	_, err = propertyRepository.DeleteById(id)
 	if err != nil {
-		return fmt.Errorf("unable to delete property: %v", err)
+		return fmt.Errorf("unable to read property: %w", translateError(err))
+	}
+	if version != 0 && version != stored {
+		return fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
 	}
-	propertyRepository.AddProperty(property)
 	return nil
 }
 
+// Search finds properties whose title, description or location match the query, most relevant first
+func (propertyRepository *PropertyRepository) Search(ctx context.Context, query string, page int, pageSize int) (domain.PropertySearchPage, error) {
+	ctx, cancel := withTimeout(ctx, propertyRepository.timeouts.Search)
+	defer cancel()
+
+	var totalCount int64
+	err := propertyRepository.db(ctx).QueryRow(ctx, countSearchQuery, query, statusNames(domain.ListedStatuses)).Scan(&totalCount)
+	if err != nil {
+		log.Errorf("Arama sorgusu hatası: %v", err)
+		return domain.PropertySearchPage{}, translateError(err)
+	}
+	rows, err := propertyRepository.db(ctx).Query(ctx, searchPropertiesQuery, query, pageSize, domain.PageOffset(page, pageSize), statusNames(domain.ListedStatuses))
+	if err != nil {
+		log.Errorf("Arama sorgusu hatası: %v", err)
+		return domain.PropertySearchPage{}, translateError(err)
+	}
+	defer rows.Close()
+
+	var results []domain.PropertySearchResult
+	for rows.Next() {
+		var result domain.PropertySearchResult
+		var titleHighlight, descriptionHighlight, locationHighlight string
+		err := rows.Scan(append(propertyFields(&result.Property), &result.Rank, &titleHighlight, &descriptionHighlight, &locationHighlight)...)
+		if err != nil {
+			log.Errorf("Error scanning search row: %v", err)
+			return domain.PropertySearchPage{}, translateError(err)
+		}
+		result.Highlights = map[string]string{
+			"title":       titleHighlight,
+			"description": descriptionHighlight,
+			"location":    locationHighlight,
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		log.Errorf("Error scanning search row: %v", err)
+		return domain.PropertySearchPage{}, translateError(err)
+	}
+	return domain.PropertySearchPage{
+		Query:      query,
+		Results:    results,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// buildPropertyFilter builds the WHERE clause and its arguments for the criteria, deleted properties and other tenants never match.
+// Sort fields are not bound as arguments, so they must be checked with domain.IsSortField beforehand.
+func buildPropertyFilter(criteria domain.PropertyCriteria) (string, []interface{}) {
+	conditions := []string{"p.tenant_id = " + tenantParam, "p.deleted_at IS NULL"}
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	addBounds := func(box domain.BoundingBox) {
+		addCondition("p.latitude >= $%d", box.MinLatitude)
+		addCondition("p.latitude <= $%d", box.MaxLatitude)
+		if box.CrossesAntimeridian() {
+			args = append(args, box.MinLongitude, box.MaxLongitude)
+			conditions = append(conditions, fmt.Sprintf("(p.longitude >= $%d OR p.longitude <= $%d)", len(args)-1, len(args)))
+			return
+		}
+		addCondition("p.longitude >= $%d", box.MinLongitude)
+		addCondition("p.longitude <= $%d", box.MaxLongitude)
+	}
+
+	if criteria.MinPrice != nil {
+		addCondition("p.price >= $%d", *criteria.MinPrice)
+	}
+	if criteria.MaxPrice != nil {
+		addCondition("p.price <= $%d", *criteria.MaxPrice)
+	}
+	if criteria.MinBedrooms != nil {
+		addCondition("p.bedrooms >= $%d", *criteria.MinBedrooms)
+	}
+	if criteria.MinBathrooms != nil {
+		addCondition("p.bathrooms >= $%d", *criteria.MinBathrooms)
+	}
+	if criteria.MinSquareFeet != nil {
+		addCondition("p.square_feet >= $%d", *criteria.MinSquareFeet)
+	}
+	if criteria.MaxSquareFeet != nil {
+		addCondition("p.square_feet <= $%d", *criteria.MaxSquareFeet)
+	}
+	if criteria.Location != "" {
+		addCondition("p.location ILIKE $%d", likePattern(criteria.Location))
+	}
+	if criteria.AgentID != nil {
+		addCondition("p.agent_id = $%d", *criteria.AgentID)
+	}
+	if criteria.AgentName != "" {
+		addCondition("a.name ILIKE $%d", likePattern(criteria.AgentName))
+	}
+	if criteria.City != "" {
+		addCondition("lower(p.city) = lower($%d)", criteria.City)
+	}
+	if len(criteria.Statuses) > 0 {
+		addCondition("p.status = ANY($%d)", statusNames(criteria.Statuses))
+	}
+	if criteria.HasCoordinates {
+		conditions = append(conditions, "p.latitude IS NOT NULL")
+	}
+	if criteria.Within != nil {
+		addBounds(*criteria.Within)
+	}
+	if criteria.Near != nil {
+		// the bounding box of the circle can use the coordinates index, the exact distance is checked on what remains
+		addBounds(criteria.Near.Bounds())
+		args = append(args, criteria.Near.Center.Latitude, criteria.Near.Center.Longitude, criteria.Near.RadiusKm)
+		conditions = append(conditions, fmt.Sprintf("%s <= $%d", distanceKm(len(args)-2, len(args)-1), len(args)))
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// distanceKm is the haversine distance in kilometers between p and the point whose latitude and longitude are the given parameters
+func distanceKm(latitudeParam int, longitudeParam int) string {
+	return fmt.Sprintf("(2 * %v * asin(sqrt(least(1, power(sin(radians(p.latitude - $%[2]d) / 2), 2) + "+
+		"cos(radians($%[2]d)) * cos(radians(p.latitude)) * power(sin(radians(p.longitude - $%[3]d) / 2), 2)))))",
+		domain.EarthRadiusKm, latitudeParam, longitudeParam)
+}
+
+// statusNames converts statuses to strings, which pgx can encode as a text array
+func statusNames(statuses []domain.PropertyStatus) []string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return names
+}
+
+// likePattern turns a search term into an ILIKE pattern that matches it anywhere in the column
+func likePattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+// propertyFields returns the scan destinations of the columns selected by propertyColumns
+func propertyFields(p *domain.Property) []interface{} {
+	return []interface{}{&p.ID, &p.Location, &p.City, &p.District, &p.Neighborhood, &p.PostalCode, &p.Latitude, &p.Longitude,
+		&p.Price, &p.Title, &p.Description, &p.Bedrooms, &p.Bathrooms, &p.SquareFeet, &p.AgentID, &p.AgentName, &p.AgentTitle, &p.Images, &p.Status, &p.StatusChangedAt, &p.DeletedAt, &p.Version, &p.OwnerID}
+}
+
+// propertyRows implements PropertyRows over the rows of a property query
+type propertyRows struct {
+	rows   pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (propertyRows *propertyRows) Next() bool {
+	return propertyRows.rows.Next()
+}
+
+func (propertyRows *propertyRows) Property() (domain.Property, error) {
+	var p domain.Property
+	if err := propertyRows.rows.Scan(propertyFields(&p)...); err != nil {
+		return domain.Property{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return p, nil
+}
+
+func (propertyRows *propertyRows) Err() error {
+	return translateError(propertyRows.rows.Err())
+}
+
+func (propertyRows *propertyRows) Close() {
+	propertyRows.rows.Close()
+	propertyRows.cancel()
+}
+
 // scanProperties scans properties
 func (propertyRepository *PropertyRepository) scanProperties(rows pgx.Rows) ([]domain.Property, error) {
 	var properties []domain.Property
 	for rows.Next() {
 		var p domain.Property
-		err := rows.Scan(&p.ID, &p.Location, &p.Price, &p.Title, &p.Description, &p.Bedrooms, &p.Bathrooms, &p.SquareFeet, &p.AgentName, &p.AgentTitle, pq.Array(&p.ImageURLs))
+		err := rows.Scan(propertyFields(&p)...)
 		if err != nil {
 			log.Errorf("Error readinig scaining rows: %v", err)
 			return nil, err
@@ -163,16 +827,10 @@ func (propertyRepository *PropertyRepository) scanProperties(rows pgx.Rows) ([]d
 
 func (propertyRepository *PropertyRepository) scanProperty(row pgx.Row) (domain.Property, error) {
 	var p domain.Property
-	var imageURLs string // Assuming imageURLs are stored as a JSON-encoded string in the database
-	err := row.Scan(&p.ID, &p.Location, &p.Price, &p.Title, &p.Description, &p.Bedrooms, &p.Bathrooms, &p.SquareFeet, &p.AgentName, &p.AgentTitle, &imageURLs)
+	err := row.Scan(propertyFields(&p)...)
 	if err != nil {
 		log.Errorf("Error scanning row: %v\n", err)
 		return domain.Property{}, err
 	}
-	// Decode JSON-encoded imageURLs string to []string
-	if err := json.Unmarshal([]byte(imageURLs), &p.ImageURLs); err != nil {
-		log.Errorf("Error decoding image URLs: %v\n", err)
-		return domain.Property{}, err
-	}
 	return p, nil
 }
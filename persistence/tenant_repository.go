@@ -0,0 +1,126 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"kirmac-site-backend/domain"
+)
+
+const (
+	tenantColumns = `t.id, t.slug, t.name, t.currency, ` +
+		`coalesce((SELECT array_agg(h.host ORDER BY h.host) FROM tenant_hosts h WHERE h.tenant_id = t.id), '{}'), t.cors_origins`
+	addTenantQuery       = `INSERT INTO tenants (slug, name, currency, cors_origins) VALUES ($1, $2, $3, $4) RETURNING id`
+	addTenantHostQuery   = `INSERT INTO tenant_hosts (host, tenant_id) VALUES ($1, $2)`
+	getTenantsQuery      = `SELECT ` + tenantColumns + ` FROM tenants t ORDER BY t.id`
+	getTenantByIdQuery   = `SELECT ` + tenantColumns + ` FROM tenants t WHERE t.id = $1`
+	getTenantBySlugQuery = `SELECT ` + tenantColumns + ` FROM tenants t WHERE t.slug = $1`
+	getTenantByHostQuery = `SELECT ` + tenantColumns + ` FROM tenants t JOIN tenant_hosts th ON th.tenant_id = t.id WHERE th.host = $1`
+)
+
+// ITenantRepository is an interface for the tenant repository
+type ITenantRepository interface {
+	AddTenant(ctx context.Context, tenant domain.Tenant) (domain.Tenant, error)
+	GetTenants(ctx context.Context) ([]domain.Tenant, error)
+	GetTenantById(ctx context.Context, id int64) (domain.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (domain.Tenant, error)
+	GetTenantByHost(ctx context.Context, host string) (domain.Tenant, error)
+}
+
+// TenantRepository is a struct for the tenant repository
+type TenantRepository struct {
+	dbPool       *pgxpool.Pool
+	transactions ITransactionManager
+	timeouts     QueryTimeouts
+}
+
+// NewTenantRepository creates a new tenant repository
+func NewTenantRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) ITenantRepository {
+	return &TenantRepository{dbPool: dbPool, transactions: NewTransactionManager(dbPool), timeouts: timeouts}
+}
+
+// db returns the transaction ctx runs in, or the pool
+func (tenantRepository *TenantRepository) db(ctx context.Context) Querier {
+	return querier(ctx, tenantRepository.dbPool)
+}
+
+// AddTenant adds a tenant with its hosts, a taken slug or host is a conflict
+func (tenantRepository *TenantRepository) AddTenant(ctx context.Context, tenant domain.Tenant) (domain.Tenant, error) {
+	ctx, cancel := withTimeout(ctx, tenantRepository.timeouts.Write)
+	defer cancel()
+	err := tenantRepository.transactions.WithTx(ctx, func(ctx context.Context) error {
+		err := tenantRepository.db(ctx).QueryRow(ctx, addTenantQuery, tenant.Slug, tenant.Name, tenant.Currency, tenant.CorsOrigins).Scan(&tenant.ID)
+		if err != nil {
+			return fmt.Errorf("unable to add tenant %q: %w", tenant.Slug, translateError(err))
+		}
+		for _, host := range tenant.Hosts {
+			if _, err := tenantRepository.db(ctx).Exec(ctx, addTenantHostQuery, host, tenant.ID); err != nil {
+				return fmt.Errorf("unable to add host %q: %w", host, translateError(err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Tenant{}, err
+	}
+	return tenant, nil
+}
+
+// GetTenants gets every tenant in the order they were added
+func (tenantRepository *TenantRepository) GetTenants(ctx context.Context) ([]domain.Tenant, error) {
+	ctx, cancel := withTimeout(ctx, tenantRepository.timeouts.Read)
+	defer cancel()
+	rows, err := tenantRepository.db(ctx).Query(ctx, getTenantsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tenants: %w", translateError(err))
+	}
+	defer rows.Close()
+
+	tenants := []domain.Tenant{}
+	for rows.Next() {
+		tenant, err := scanTenant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+		}
+		tenants = append(tenants, tenant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return tenants, nil
+}
+
+// GetTenantById gets a tenant by id
+func (tenantRepository *TenantRepository) GetTenantById(ctx context.Context, id int64) (domain.Tenant, error) {
+	return tenantRepository.getTenant(ctx, fmt.Sprintf("tenant %d", id), getTenantByIdQuery, id)
+}
+
+// GetTenantBySlug gets a tenant by slug
+func (tenantRepository *TenantRepository) GetTenantBySlug(ctx context.Context, slug string) (domain.Tenant, error) {
+	return tenantRepository.getTenant(ctx, fmt.Sprintf("tenant %q", slug), getTenantBySlugQuery, slug)
+}
+
+// GetTenantByHost gets the tenant serving a host name
+func (tenantRepository *TenantRepository) GetTenantByHost(ctx context.Context, host string) (domain.Tenant, error) {
+	return tenantRepository.getTenant(ctx, fmt.Sprintf("tenant of host %q", host), getTenantByHostQuery, host)
+}
+
+func (tenantRepository *TenantRepository) getTenant(ctx context.Context, name string, query string, arg interface{}) (domain.Tenant, error) {
+	ctx, cancel := withTimeout(ctx, tenantRepository.timeouts.Read)
+	defer cancel()
+	tenant, err := scanTenant(tenantRepository.db(ctx).QueryRow(ctx, query, arg))
+	if err == pgx.ErrNoRows {
+		return domain.Tenant{}, fmt.Errorf("%s: %w", name, domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.Tenant{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return tenant, nil
+}
+
+func scanTenant(row pgx.Row) (domain.Tenant, error) {
+	var tenant domain.Tenant
+	err := row.Scan(&tenant.ID, &tenant.Slug, &tenant.Name, &tenant.Currency, &tenant.Hosts, &tenant.CorsOrigins)
+	return tenant, err
+}
@@ -0,0 +1,80 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"kirmac-site-backend/common/requestctx"
+	"strconv"
+	"strings"
+)
+
+// tenantParam stands for the id of the tenant of the request in the queries of tenant scoped repositories
+const tenantParam = "$tenant"
+
+var (
+	errNoTenant = errors.New("the request is not scoped to a tenant")
+	errUnscoped = errors.New("the query is not scoped to a tenant")
+)
+
+// tenantQuerier binds tenantParam to the tenant of the request as an extra last argument,
+// and refuses queries that do not use it so no query of a scoped repository can see every tenant
+type tenantQuerier struct {
+	querier  Querier
+	tenantID int64
+	err      error
+}
+
+// scoped returns a Querier running the statements of q for the tenant of ctx
+func scoped(ctx context.Context, q Querier) Querier {
+	tenant, ok := requestctx.Tenant(ctx)
+	if !ok || tenant.ID == 0 {
+		return &tenantQuerier{err: errNoTenant}
+	}
+	return &tenantQuerier{querier: q, tenantID: tenant.ID}
+}
+
+func (tenantQuerier *tenantQuerier) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	sql, args, err := tenantQuerier.bind(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return tenantQuerier.querier.Exec(ctx, sql, args...)
+}
+
+func (tenantQuerier *tenantQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	sql, args, err := tenantQuerier.bind(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return tenantQuerier.querier.Query(ctx, sql, args...)
+}
+
+func (tenantQuerier *tenantQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	sql, args, err := tenantQuerier.bind(sql, args)
+	if err != nil {
+		return errorRow{err: err}
+	}
+	return tenantQuerier.querier.QueryRow(ctx, sql, args...)
+}
+
+func (tenantQuerier *tenantQuerier) bind(sql string, args []interface{}) (string, []interface{}, error) {
+	if tenantQuerier.err != nil {
+		return "", nil, tenantQuerier.err
+	}
+	if !strings.Contains(sql, tenantParam) {
+		return "", nil, errUnscoped
+	}
+	args = append(args[:len(args):len(args)], tenantQuerier.tenantID)
+	return strings.ReplaceAll(sql, tenantParam, "$"+strconv.Itoa(len(args))), args, nil
+}
+
+// errorRow is a row whose Scan fails with err
+type errorRow struct {
+	err error
+}
+
+func (row errorRow) Scan(...interface{}) error {
+	return row.err
+}
@@ -0,0 +1,63 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Querier runs statements, it is implemented by both the pool and a transaction
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type txKey struct{}
+
+// ITransactionManager is an interface for running a unit of work inside one database transaction
+type ITransactionManager interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TransactionManager is a struct for the transaction manager
+type TransactionManager struct {
+	dbPool *pgxpool.Pool
+}
+
+// NewTransactionManager creates a new transaction manager
+func NewTransactionManager(dbPool *pgxpool.Pool) ITransactionManager {
+	return &TransactionManager{dbPool: dbPool}
+}
+
+// WithTx runs fn inside a transaction that is committed when fn returns nil and rolled back otherwise.
+// Repositories called with the ctx passed to fn use the transaction, and nested calls join the outer transaction.
+func (transactionManager *TransactionManager) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := transactionManager.dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", translateError(err))
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", translateError(err))
+	}
+	return nil
+}
+
+// querier returns the transaction of ctx, or dbPool when ctx is not inside WithTx
+func querier(ctx context.Context, dbPool *pgxpool.Pool) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return dbPool
+}
@@ -0,0 +1,78 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+)
+
+const (
+	addUserQuery           = `INSERT INTO users (username, password_hash, role, tenant_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	getUserByUsernameQuery = `SELECT id, username, password_hash, role, tenant_id, created_at FROM users WHERE username = $1`
+	getUserByIdQuery       = `SELECT id, username, password_hash, role, tenant_id, created_at FROM users WHERE id = $1`
+)
+
+// IUserRepository is an interface for the user repository, usernames are unique across tenants so signing in needs no tenant
+type IUserRepository interface {
+	AddUser(ctx context.Context, user domain.User) (domain.User, error)
+	GetUserByUsername(ctx context.Context, username string) (domain.User, error)
+	GetUserById(ctx context.Context, id int64) (domain.User, error)
+}
+
+// UserRepository is a struct for the user repository
+type UserRepository struct {
+	dbPool   *pgxpool.Pool
+	timeouts QueryTimeouts
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(dbPool *pgxpool.Pool, timeouts QueryTimeouts) IUserRepository {
+	return &UserRepository{dbPool: dbPool, timeouts: timeouts}
+}
+
+// db returns the transaction ctx runs in, or the pool
+func (userRepository *UserRepository) db(ctx context.Context) Querier {
+	return querier(ctx, userRepository.dbPool)
+}
+
+// AddUser adds a user, a taken username is a conflict
+func (userRepository *UserRepository) AddUser(ctx context.Context, user domain.User) (domain.User, error) {
+	ctx, cancel := withTimeout(ctx, userRepository.timeouts.Write)
+	defer cancel()
+	err := userRepository.db(ctx).QueryRow(ctx, addUserQuery, user.Username, user.PasswordHash, user.Role, user.TenantID).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("unable to add user %q: %w", user.Username, translateError(err))
+	}
+	return user, nil
+}
+
+// GetUserByUsername gets a user by username
+func (userRepository *UserRepository) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
+	ctx, cancel := withTimeout(ctx, userRepository.timeouts.Read)
+	defer cancel()
+	return userRepository.getUser(ctx, fmt.Sprintf("user %q", username), getUserByUsernameQuery, username)
+}
+
+// GetUserById gets a user by id
+func (userRepository *UserRepository) GetUserById(ctx context.Context, id int64) (domain.User, error) {
+	ctx, cancel := withTimeout(ctx, userRepository.timeouts.Read)
+	defer cancel()
+	return userRepository.getUser(ctx, fmt.Sprintf("user %d", id), getUserByIdQuery, id)
+}
+
+// getUser reads a user of any tenant, users sign in before the tenant of their requests is known
+func (userRepository *UserRepository) getUser(ctx context.Context, name string, query string, arg interface{}) (domain.User, error) {
+	ctx = requestctx.WithoutTenant(ctx)
+	var user domain.User
+	err := userRepository.db(ctx).QueryRow(ctx, query, arg).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.TenantID, &user.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return domain.User{}, fmt.Errorf("%s: %w", name, domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.User{}, fmt.Errorf("unable to read row: %w", translateError(err))
+	}
+	return user, nil
+}
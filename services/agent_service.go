@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/services/model"
+	"strings"
+)
+
+// IAgentService defines the service interface for agent operations
+type IAgentService interface {
+	GetAllAgents(ctx context.Context) ([]domain.Agent, error)
+	GetAgentById(ctx context.Context, id int64) (domain.Agent, error)
+	AddAgent(ctx context.Context, agent model.AgentCreate) (domain.Agent, error)
+	UpdateAgent(ctx context.Context, id int64, agent model.AgentCreate) (domain.Agent, error)
+	DeleteById(ctx context.Context, id int64) (bool, error)
+	GetAgentProperties(ctx context.Context, id int64, criteria domain.PropertyCriteria) (domain.PropertyPage, error)
+}
+
+// AgentService implements IAgentService and provides business logic for agent operations
+type AgentService struct {
+	repository         persistence.IAgentRepository
+	propertyRepository persistence.IPropertyRepository
+}
+
+// NewAgentService creates a new instance of AgentService
+func NewAgentService(repository persistence.IAgentRepository, propertyRepository persistence.IPropertyRepository) *AgentService {
+	return &AgentService{
+		repository:         repository,
+		propertyRepository: propertyRepository,
+	}
+}
+
+// GetAllAgents retrieves every agent
+func (service *AgentService) GetAllAgents(ctx context.Context) ([]domain.Agent, error) {
+	return service.repository.GetAllAgents(ctx)
+}
+
+// GetAgentById retrieves an agent by id
+func (service *AgentService) GetAgentById(ctx context.Context, id int64) (domain.Agent, error) {
+	return service.repository.GetAgentById(ctx, id)
+}
+
+// AddAgent adds a new agent and returns it as persisted
+func (service *AgentService) AddAgent(ctx context.Context, agent model.AgentCreate) (domain.Agent, error) {
+	if err := authorize(ctx, manageAgents, nil); err != nil {
+		return domain.Agent{}, err
+	}
+	agent = normalizeAgent(agent)
+	if err := validateAgent(agent); err != nil {
+		return domain.Agent{}, err
+	}
+	return service.repository.AddAgent(ctx, toDomainAgent(agent))
+}
+
+// UpdateAgent updates an agent, the change shows on every property of the agent
+func (service *AgentService) UpdateAgent(ctx context.Context, id int64, agent model.AgentCreate) (domain.Agent, error) {
+	if err := authorize(ctx, manageAgents, nil); err != nil {
+		return domain.Agent{}, err
+	}
+	agent = normalizeAgent(agent)
+	if err := validateAgent(agent); err != nil {
+		return domain.Agent{}, err
+	}
+	return service.repository.UpdateAgent(ctx, id, toDomainAgent(agent))
+}
+
+// DeleteById deletes an agent by id, the properties of the agent are kept without an agent
+func (service *AgentService) DeleteById(ctx context.Context, id int64) (bool, error) {
+	if err := authorize(ctx, manageAgents, nil); err != nil {
+		return false, err
+	}
+	deleted, err := service.repository.DeleteById(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if !deleted {
+		return false, fmt.Errorf("agent %d: %w", id, domain.ErrNotFound)
+	}
+	return true, nil
+}
+
+// GetAgentProperties retrieves a page of the properties of an agent matching the criteria
+func (service *AgentService) GetAgentProperties(ctx context.Context, id int64, criteria domain.PropertyCriteria) (domain.PropertyPage, error) {
+	if _, err := service.repository.GetAgentById(ctx, id); err != nil {
+		return domain.PropertyPage{}, err
+	}
+	criteria.AgentID = &id
+	criteria, err := normalizeCriteria(ctx, criteria)
+	if err != nil {
+		return domain.PropertyPage{}, err
+	}
+	return service.propertyRepository.GetAllProperties(ctx, criteria)
+}
+
+// normalizeAgent trims the text fields so the same agent is not stored twice with different spacing
+func normalizeAgent(agent model.AgentCreate) model.AgentCreate {
+	agent.Name = strings.TrimSpace(agent.Name)
+	agent.Title = strings.TrimSpace(agent.Title)
+	agent.Phone = strings.TrimSpace(agent.Phone)
+	agent.Email = strings.TrimSpace(agent.Email)
+	agent.PhotoURL = strings.TrimSpace(agent.PhotoURL)
+	return agent
+}
+
+func toDomainAgent(agent model.AgentCreate) domain.Agent {
+	return domain.Agent{
+		Name:      agent.Name,
+		Title:     agent.Title,
+		Phone:     agent.Phone,
+		Email:     agent.Email,
+		PhotoURL:  agent.PhotoURL,
+		Bio:       agent.Bio,
+		Languages: agent.Languages,
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/services/validation"
+	"regexp"
+)
+
+// Limits for agent fields, text limits match the columns of the agents table
+const (
+	maxPhoneLength    = 32
+	maxBioLength      = 10000
+	maxLanguageCount  = 20
+	maxLanguageLength = 64
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ()-]+$`)
+
+// validateAgent checks every field of the agent and returns all violations together
+func validateAgent(agent model.AgentCreate) error {
+	validator := validation.NewValidator()
+
+	validator.Required("name", agent.Name)
+	validator.MaxLength("name", agent.Name, maxTextLength)
+	validator.MaxLength("title", agent.Title, maxTextLength)
+	if agent.Phone != "" {
+		validator.MaxLength("phone", agent.Phone, maxPhoneLength)
+		validator.Check(phonePattern.MatchString(agent.Phone), "phone", "must contain only digits, spaces, +, -, ( and )")
+	}
+	if agent.Email != "" {
+		validator.MaxLength("email", agent.Email, maxTextLength)
+		validator.Email("email", agent.Email)
+	}
+	if agent.PhotoURL != "" {
+		validator.MaxLength("photo_url", agent.PhotoURL, maxImageURLLength)
+		validator.URL("photo_url", agent.PhotoURL, allowedImageURLSchemes...)
+	}
+	validator.MaxLength("bio", agent.Bio, maxBioLength)
+
+	validator.Check(len(agent.Languages) <= maxLanguageCount, "languages", fmt.Sprintf("must contain at most %d languages", maxLanguageCount))
+	for i, language := range agent.Languages {
+		field := fmt.Sprintf("languages[%d]", i)
+		validator.Required(field, language)
+		validator.MaxLength(field, language, maxLanguageLength)
+	}
+
+	return validator.Err()
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/common/token"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/services/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix starts every API key so leaked keys are easy to recognise
+const apiKeyPrefix = "kk_"
+
+// apiKeyDisplayLength is how much of a key is kept in clear to tell keys apart
+const apiKeyDisplayLength = 10
+
+// dummyPasswordHash is compared against when the username is unknown, so failed sign ins take the same time
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("kirmac-unknown-user"), bcrypt.DefaultCost)
+
+// IAuthService is an interface for user accounts, tokens and API keys
+type IAuthService interface {
+	CreateUser(ctx context.Context, user model.UserCreate) (domain.User, error)
+	Login(ctx context.Context, credentials model.Credentials) (domain.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (domain.TokenPair, error)
+	Authenticate(ctx context.Context, accessToken string) (domain.Principal, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (domain.Principal, error)
+	CreateAPIKey(ctx context.Context, key model.APIKeyCreate) (domain.NewAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (domain.APIKey, error)
+}
+
+// AuthService is a struct for the auth service
+type AuthService struct {
+	users   persistence.IUserRepository
+	apiKeys persistence.IAPIKeyRepository
+	issuer  *token.Issuer
+	now     func() time.Time
+}
+
+// NewAuthService creates a new instance of AuthService that signs tokens with issuer
+func NewAuthService(users persistence.IUserRepository, apiKeys persistence.IAPIKeyRepository, issuer *token.Issuer) *AuthService {
+	return &AuthService{
+		users:   users,
+		apiKeys: apiKeys,
+		issuer:  issuer,
+		now:     time.Now,
+	}
+}
+
+// CreateUser adds a user account of the tenant of ctx with a bcrypt hash of its password, users are viewers unless given another role
+func (service *AuthService) CreateUser(ctx context.Context, user model.UserCreate) (domain.User, error) {
+	tenant, ok := requestctx.Tenant(ctx)
+	if !ok {
+		return domain.User{}, errors.New("users can only be created for a tenant")
+	}
+	user.Username = strings.TrimSpace(user.Username)
+	if user.Role == "" {
+		user.Role = domain.RoleViewer
+	}
+	if err := validateUser(user); err != nil {
+		return domain.User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("unable to hash password: %w", err)
+	}
+	return service.users.AddUser(ctx, domain.User{Username: user.Username, PasswordHash: string(hash), Role: user.Role, TenantID: tenant.ID})
+}
+
+// Login checks the credentials of a user and issues a new pair of tokens
+func (service *AuthService) Login(ctx context.Context, credentials model.Credentials) (domain.TokenPair, error) {
+	user, err := service.users.GetUserByUsername(ctx, strings.TrimSpace(credentials.Username))
+	if errors.Is(err, domain.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(credentials.Password))
+		return domain.TokenPair{}, invalidCredentials()
+	}
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(credentials.Password)) != nil {
+		return domain.TokenPair{}, invalidCredentials()
+	}
+	return service.issueTokens(user)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens as long as its user still exists,
+// the new tokens carry the current role of the user
+func (service *AuthService) Refresh(ctx context.Context, refreshToken string) (domain.TokenPair, error) {
+	claims, err := service.issuer.Verify(token.Refresh, refreshToken, service.now())
+	if err != nil {
+		return domain.TokenPair{}, fmt.Errorf("%w: %v", domain.ErrUnauthorized, err)
+	}
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return domain.TokenPair{}, fmt.Errorf("%w: invalid token subject", domain.ErrUnauthorized)
+	}
+	user, err := service.users.GetUserById(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.TokenPair{}, fmt.Errorf("%w: the user no longer exists", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	return service.issueTokens(user)
+}
+
+// Authenticate verifies an access token without touching the database
+func (service *AuthService) Authenticate(ctx context.Context, accessToken string) (domain.Principal, error) {
+	claims, err := service.issuer.Verify(token.Access, accessToken, service.now())
+	if err != nil {
+		return domain.Principal{}, fmt.Errorf("%w: %v", domain.ErrUnauthorized, err)
+	}
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return domain.Principal{}, fmt.Errorf("%w: invalid token subject", domain.ErrUnauthorized)
+	}
+	return domain.Principal{Name: claims.Name, UserID: id, Role: domain.Role(claims.Role), TenantID: claims.Tenant}, nil
+}
+
+// AuthenticateAPIKey finds the active API key matching key and acts as the user owning it with the role that user has now,
+// revoked and unknown keys are unauthorized
+func (service *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (domain.Principal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return domain.Principal{}, fmt.Errorf("%w: invalid API key", domain.ErrUnauthorized)
+	}
+	apiKey, err := service.apiKeys.UseAPIKey(ctx, hashAPIKey(key))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, fmt.Errorf("%w: invalid API key", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	user, err := service.users.GetUserById(ctx, apiKey.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, fmt.Errorf("%w: invalid API key", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	return domain.Principal{Name: "api-key:" + apiKey.Name, UserID: user.ID, Role: user.Role, TenantID: user.TenantID, KeyID: apiKey.ID}, nil
+}
+
+// CreateAPIKey generates a random API key acting as whoever creates it, the key is returned once and only its hash is stored
+func (service *AuthService) CreateAPIKey(ctx context.Context, key model.APIKeyCreate) (domain.NewAPIKey, error) {
+	if _, err := ownerScope(ctx, manageAPIKeys); err != nil {
+		return domain.NewAPIKey{}, err
+	}
+	principal, _ := requestctx.Principal(ctx)
+	key.Name = strings.TrimSpace(key.Name)
+	if err := validateAPIKey(key); err != nil {
+		return domain.NewAPIKey{}, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return domain.NewAPIKey{}, fmt.Errorf("unable to generate API key: %w", err)
+	}
+	plain := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	added, err := service.apiKeys.AddAPIKey(ctx, domain.APIKey{
+		UserID:  principal.UserID,
+		Name:    key.Name,
+		Prefix:  plain[:apiKeyDisplayLength],
+		KeyHash: hashAPIKey(plain),
+	})
+	if err != nil {
+		return domain.NewAPIKey{}, err
+	}
+	return domain.NewAPIKey{APIKey: added, Key: plain}, nil
+}
+
+// GetAPIKeys retrieves the API keys the caller may manage without the keys themselves, admins see every key
+func (service *AuthService) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	userID, err := ownerScope(ctx, manageAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	return service.apiKeys.GetAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey revokes an API key for good, keys the caller may not manage are not found
+func (service *AuthService) RevokeAPIKey(ctx context.Context, id int64) (domain.APIKey, error) {
+	userID, err := ownerScope(ctx, manageAPIKeys)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	return service.apiKeys.RevokeAPIKey(ctx, id, userID)
+}
+
+// issueTokens signs an access and a refresh token for user
+func (service *AuthService) issueTokens(user domain.User) (domain.TokenPair, error) {
+	now := service.now()
+	identity := token.Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Username, Role: string(user.Role), Tenant: user.TenantID}
+	accessToken, expiresAt, err := service.issuer.Issue(token.Access, identity, now)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	refreshToken, _, err := service.issuer.Issue(token.Refresh, identity, now)
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	return domain.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, TokenType: "Bearer", ExpiresAt: expiresAt}, nil
+}
+
+func invalidCredentials() error {
+	return fmt.Errorf("%w: invalid username or password", domain.ErrUnauthorized)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
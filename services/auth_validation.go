@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/services/validation"
+	"regexp"
+	"strings"
+)
+
+// Limits for accounts, bcrypt ignores everything after the first 72 bytes of a password.
+// API key names are short enough to fit the actor columns once prefixed with api-key:.
+const (
+	minPasswordLength   = 12
+	maxPasswordBytes    = 72
+	maxAPIKeyNameLength = 100
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+// validateUser checks the username and password of a new account and returns all violations together
+func validateUser(user model.UserCreate) error {
+	validator := validation.NewValidator()
+
+	validator.Required("username", user.Username)
+	validator.MaxLength("username", user.Username, maxTextLength)
+	if user.Username != "" {
+		validator.Check(usernamePattern.MatchString(user.Username), "username", "must contain only letters, digits, ., _, @ and -")
+	}
+	validator.Check(len([]rune(user.Password)) >= minPasswordLength, "password", fmt.Sprintf("must be at least %d characters", minPasswordLength))
+	validator.Check(len(user.Password) <= maxPasswordBytes, "password", fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	validator.Check(domain.IsRole(user.Role), "role", fmt.Sprintf("must be one of %s", strings.Join(roleNames(), ", ")))
+
+	return validator.Err()
+}
+
+func roleNames() []string {
+	names := make([]string, len(domain.Roles))
+	for i, role := range domain.Roles {
+		names[i] = string(role)
+	}
+	return names
+}
+
+// validateAPIKey checks the fields of a new API key
+func validateAPIKey(key model.APIKeyCreate) error {
+	validator := validation.NewValidator()
+
+	validator.Required("name", key.Name)
+	validator.MaxLength("name", key.Name, maxAPIKeyNameLength)
+
+	return validator.Err()
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v3/log"
+	"image"
+	"io"
+	"kirmac-site-backend/common/imaging"
+	"kirmac-site-backend/common/storage"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/services/model"
+	"net/http"
+	"strings"
+)
+
+// acceptedImageTypes are the sniffed content types accepted for uploads
+var acceptedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imageVariants are the resized copies generated for every upload, each one fits within its box
+var imageVariants = []struct {
+	name   string
+	width  int
+	height int
+}{
+	{"thumbnail", 320, 320},
+	{"card", 800, 600},
+	{"full", 1920, 1920},
+}
+
+// IImageService defines the service interface for the images of a property
+type IImageService interface {
+	GetImages(ctx context.Context, id int64) ([]domain.PropertyImage, error)
+	UploadImage(ctx context.Context, id int64, content io.Reader, image model.ImageCreate, version int64) (domain.Property, error)
+	AddImage(ctx context.Context, id int64, image model.ImageCreate, version int64) (domain.Property, error)
+	UpdateImage(ctx context.Context, id int64, imageID int64, update model.ImageUpdate, version int64) (domain.Property, error)
+	ReorderImages(ctx context.Context, id int64, imageIDs []int64, version int64) (domain.Property, error)
+	DeleteImage(ctx context.Context, id int64, imageID int64, version int64) (domain.Property, error)
+	OpenImage(ctx context.Context, key string) (storage.Object, error)
+}
+
+// ImageService implements IImageService, it stores uploads and keeps the order and cover of the images of a property consistent
+type ImageService struct {
+	repository    persistence.IPropertyRepository
+	images        persistence.IPropertyImageRepository
+	audit         persistence.IAuditRepository
+	transactions  persistence.ITransactionManager
+	storage       storage.Storage
+	maxUploadSize int64
+}
+
+// NewImageService creates a new instance of ImageService
+func NewImageService(repository persistence.IPropertyRepository, images persistence.IPropertyImageRepository, audit persistence.IAuditRepository, transactions persistence.ITransactionManager, storage storage.Storage, maxUploadSize int64) *ImageService {
+	return &ImageService{
+		repository:    repository,
+		images:        images,
+		audit:         audit,
+		transactions:  transactions,
+		storage:       storage,
+		maxUploadSize: maxUploadSize,
+	}
+}
+
+// GetImages retrieves the images of a property ordered by position
+func (service *ImageService) GetImages(ctx context.Context, id int64) ([]domain.PropertyImage, error) {
+	if _, err := getVisibleProperty(ctx, service.repository, id); err != nil {
+		return nil, err
+	}
+	return service.images.GetImages(ctx, id)
+}
+
+// UploadImage stores an upright, metadata free copy of an image together with its resized variants,
+// and adds it to the end of the images, or to the front as the cover when image.IsCover is set.
+// A non-zero version must match the stored version.
+func (service *ImageService) UploadImage(ctx context.Context, id int64, content io.Reader, image model.ImageCreate, version int64) (domain.Property, error) {
+	if err := validateImage(image, true); err != nil {
+		return domain.Property{}, err
+	}
+	property, err := service.currentProperty(ctx, id, version)
+	if err != nil {
+		return domain.Property{}, err
+	}
+	if len(property.Images) >= maxImageCount {
+		return domain.Property{}, tooManyImages()
+	}
+
+	data, err := io.ReadAll(io.LimitReader(content, service.maxUploadSize+1))
+	if err != nil {
+		return domain.Property{}, invalidField("image", "unable to read the upload")
+	}
+	if int64(len(data)) > service.maxUploadSize {
+		return domain.Property{}, fmt.Errorf("image is larger than %d bytes: %w", service.maxUploadSize, domain.ErrTooLarge)
+	}
+	// the declared content type and file name are ignored, only the content decides what is stored
+	if !acceptedImageTypes[http.DetectContentType(data)] {
+		return domain.Property{}, invalidField("image", "must be a JPEG, PNG, GIF or WebP image")
+	}
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		return domain.Property{}, invalidField("image", "the image could not be decoded")
+	}
+	// re-encoding drops EXIF, GPS and other metadata, images that may be transparent stay PNG
+	if format != "png" && format != "gif" {
+		format = "jpeg"
+	} else {
+		format = "png"
+	}
+
+	uploaded, err := service.storeImage(ctx, fmt.Sprintf("properties/%d/%s", id, randomName()), img, format)
+	if err != nil {
+		return domain.Property{}, err
+	}
+	uploaded.Alt = image.Alt
+	uploaded.Caption = image.Caption
+	uploaded.IsCover = image.IsCover
+	updated, err := service.changeImages(ctx, id, version, func(images []domain.PropertyImage) ([]domain.PropertyImage, error) {
+		return insertImage(images, uploaded)
+	})
+	if err != nil {
+		service.deleteStored(ctx, uploaded)
+		return domain.Property{}, err
+	}
+	return updated, nil
+}
+
+// AddImage adds an image hosted elsewhere by its URL, to the end of the images or to the front as the cover when image.IsCover is set.
+// A non-zero version must match the stored version.
+func (service *ImageService) AddImage(ctx context.Context, id int64, image model.ImageCreate, version int64) (domain.Property, error) {
+	image.URL = strings.TrimSpace(image.URL)
+	if err := validateImage(image, false); err != nil {
+		return domain.Property{}, err
+	}
+	return service.changeImages(ctx, id, version, func(images []domain.PropertyImage) ([]domain.PropertyImage, error) {
+		return insertImage(images, domain.PropertyImage{URL: image.URL, Alt: image.Alt, Caption: image.Caption, IsCover: image.IsCover})
+	})
+}
+
+// UpdateImage changes the alt text, caption, position or cover flag of an image.
+// A non-zero version must match the stored version.
+func (service *ImageService) UpdateImage(ctx context.Context, id int64, imageID int64, update model.ImageUpdate, version int64) (domain.Property, error) {
+	return service.changeImages(ctx, id, version, func(images []domain.PropertyImage) ([]domain.PropertyImage, error) {
+		index := indexOfImage(images, imageID)
+		if index < 0 {
+			return nil, fmt.Errorf("image %d of property %d: %w", imageID, id, domain.ErrNotFound)
+		}
+		if err := validateImageUpdate(update, len(images)); err != nil {
+			return nil, err
+		}
+		image := images[index]
+		if update.Alt != nil {
+			image.Alt = *update.Alt
+		}
+		if update.Caption != nil {
+			image.Caption = *update.Caption
+		}
+		if update.IsCover != nil {
+			setCover(images, imageID)
+			image.IsCover = true
+		}
+		images = append(images[:index:index], images[index+1:]...)
+		position := index
+		if update.Position != nil {
+			position = *update.Position
+		}
+		return append(images[:position:position], append([]domain.PropertyImage{image}, images[position:]...)...), nil
+	})
+}
+
+// ReorderImages saves a new order of the images, imageIDs must hold the id of every image once.
+// A non-zero version must match the stored version.
+func (service *ImageService) ReorderImages(ctx context.Context, id int64, imageIDs []int64, version int64) (domain.Property, error) {
+	return service.changeImages(ctx, id, version, func(images []domain.PropertyImage) ([]domain.PropertyImage, error) {
+		if len(imageIDs) != len(images) {
+			return nil, invalidField("image_ids", "must contain every image of the property exactly once")
+		}
+		reordered := make([]domain.PropertyImage, 0, len(images))
+		for _, imageID := range imageIDs {
+			index := indexOfImage(images, imageID)
+			if index < 0 || indexOfImage(reordered, imageID) >= 0 {
+				return nil, invalidField("image_ids", "must contain every image of the property exactly once")
+			}
+			reordered = append(reordered, images[index])
+		}
+		return reordered, nil
+	})
+}
+
+// DeleteImage removes an image from the property and the files of uploaded images from the storage,
+// the first remaining image becomes the cover when the cover is deleted.
+// A non-zero version must match the stored version.
+func (service *ImageService) DeleteImage(ctx context.Context, id int64, imageID int64, version int64) (domain.Property, error) {
+	var deleted domain.PropertyImage
+	updated, err := service.changeImages(ctx, id, version, func(images []domain.PropertyImage) ([]domain.PropertyImage, error) {
+		index := indexOfImage(images, imageID)
+		if index < 0 {
+			return nil, fmt.Errorf("image %d of property %d: %w", imageID, id, domain.ErrNotFound)
+		}
+		deleted = images[index]
+		return append(images[:index:index], images[index+1:]...), nil
+	})
+	if err != nil {
+		return domain.Property{}, err
+	}
+	// only uploads have variants, an image added by URL may point at files that belong to another image
+	if len(deleted.Variants) > 0 {
+		service.deleteStored(ctx, deleted)
+	}
+	return updated, nil
+}
+
+// changeImages runs change on the images of a property inside a transaction that locks the property and increases its version,
+// then saves what changed with positions following the returned order, and returns the property as stored.
+// Images without an id are added and images missing from the result are deleted. The change is audited as an update of the images.
+func (service *ImageService) changeImages(ctx context.Context, id int64, version int64, change func(images []domain.PropertyImage) ([]domain.PropertyImage, error)) (domain.Property, error) {
+	return auditChange(ctx, service.transactions, service.audit, domain.AuditUpdate, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		locked, err := service.repository.GetPropertyForUpdate(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := authorize(ctx, editProperty, locked.OwnerID); err != nil {
+			return nil, nil, err
+		}
+		if err := service.repository.TouchProperty(ctx, id, version); err != nil {
+			return nil, nil, err
+		}
+		current, err := service.images.GetImages(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		changed, err := change(append([]domain.PropertyImage{}, current...))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := service.saveImages(ctx, id, current, arrangeImages(changed)); err != nil {
+			return nil, nil, err
+		}
+		property, err := service.repository.GetPropertyById(ctx, id)
+		return &locked, &property, err
+	})
+}
+
+// saveImages writes the difference between the current and the changed images
+func (service *ImageService) saveImages(ctx context.Context, id int64, current []domain.PropertyImage, changed []domain.PropertyImage) error {
+	for _, image := range current {
+		if indexOfImage(changed, image.ID) >= 0 {
+			continue
+		}
+		if _, err := service.images.DeleteImage(ctx, id, image.ID); err != nil {
+			return err
+		}
+	}
+	for _, image := range changed {
+		if image.ID == 0 {
+			if _, err := service.images.AddImage(ctx, id, image); err != nil {
+				return err
+			}
+			continue
+		}
+		previous := current[indexOfImage(current, image.ID)]
+		if previous.Alt == image.Alt && previous.Caption == image.Caption && previous.Position == image.Position && previous.IsCover == image.IsCover {
+			continue
+		}
+		if err := service.images.UpdateImage(ctx, id, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeImage stores img and its variants under keys starting with prefix, nothing is left behind when it fails
+func (service *ImageService) storeImage(ctx context.Context, prefix string, img image.Image, format string) (domain.PropertyImage, error) {
+	extension := ".jpg"
+	contentType := "image/jpeg"
+	if format == "png" {
+		extension = ".png"
+		contentType = "image/png"
+	}
+	put := func(key string, img image.Image) (string, error) {
+		var buffer bytes.Buffer
+		if err := imaging.Encode(&buffer, img, format); err != nil {
+			return "", err
+		}
+		if err := service.storage.Put(ctx, key, &buffer, contentType); err != nil {
+			return "", err
+		}
+		return service.storage.URL(key), nil
+	}
+
+	bounds := img.Bounds()
+	stored := domain.PropertyImage{Width: bounds.Dx(), Height: bounds.Dy(), Variants: map[string]domain.ImageVariant{}}
+	var err error
+	if stored.URL, err = put(prefix+extension, img); err != nil {
+		return domain.PropertyImage{}, err
+	}
+	for _, variant := range imageVariants {
+		resized := imaging.Fit(img, variant.width, variant.height)
+		url, err := put(prefix+"_"+variant.name+extension, resized)
+		if err != nil {
+			service.deleteStored(ctx, stored)
+			return domain.PropertyImage{}, err
+		}
+		stored.Variants[variant.name] = domain.ImageVariant{URL: url, Width: resized.Bounds().Dx(), Height: resized.Bounds().Dy()}
+	}
+	return stored, nil
+}
+
+// OpenImage reads a stored image for serving
+func (service *ImageService) OpenImage(ctx context.Context, key string) (storage.Object, error) {
+	object, err := service.storage.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.Object{}, fmt.Errorf("image %s: %w", key, domain.ErrNotFound)
+	}
+	return object, err
+}
+
+// currentProperty gets a property the request may change, so nothing is stored for a request that is not allowed to
+func (service *ImageService) currentProperty(ctx context.Context, id int64, version int64) (domain.Property, error) {
+	property, err := service.repository.GetPropertyById(ctx, id)
+	if err != nil {
+		return domain.Property{}, err
+	}
+	if err := authorize(ctx, editProperty, property.OwnerID); err != nil {
+		return domain.Property{}, err
+	}
+	if version != 0 && property.Version != version {
+		return domain.Property{}, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+	}
+	return property, nil
+}
+
+// deleteStored removes the files of an uploaded image and its variants, a failure only leaves orphaned files behind
+func (service *ImageService) deleteStored(ctx context.Context, stored domain.PropertyImage) {
+	deleteStoredImage(ctx, service.storage, stored)
+}
+
+// deleteStoredImage removes the files of an image and its variants that are kept in store, files hosted elsewhere are left alone
+func deleteStoredImage(ctx context.Context, store storage.Storage, stored domain.PropertyImage) {
+	urls := []string{stored.URL}
+	for _, variant := range stored.Variants {
+		urls = append(urls, variant.URL)
+	}
+	for _, url := range urls {
+		key, ok := strings.CutPrefix(url, store.URL(""))
+		if !ok {
+			continue
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			log.Errorf("Unable to delete image %s: %v", key, err)
+		}
+	}
+}
+
+func randomName() string {
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(name)
+}
+
+// insertImage adds image to the end, or to the front as the only cover when it is the cover
+func insertImage(images []domain.PropertyImage, image domain.PropertyImage) ([]domain.PropertyImage, error) {
+	if len(images) >= maxImageCount {
+		return nil, tooManyImages()
+	}
+	if !image.IsCover {
+		return append(images, image), nil
+	}
+	setCover(images, 0)
+	return append([]domain.PropertyImage{image}, images...), nil
+}
+
+// arrangeImages numbers the images in their order and makes sure exactly one of them is the cover, the first one unless another is marked
+func arrangeImages(images []domain.PropertyImage) []domain.PropertyImage {
+	cover := -1
+	for i := range images {
+		images[i].Position = i
+		if images[i].IsCover && cover < 0 {
+			cover = i
+		}
+	}
+	if cover < 0 {
+		cover = 0
+	}
+	for i := range images {
+		images[i].IsCover = i == cover
+	}
+	return images
+}
+
+// setCover marks the image with imageID as the cover and clears the flag of every other image
+func setCover(images []domain.PropertyImage, imageID int64) {
+	for i := range images {
+		images[i].IsCover = images[i].ID == imageID && imageID != 0
+	}
+}
+
+func indexOfImage(images []domain.PropertyImage, imageID int64) int {
+	for i, image := range images {
+		if image.ID == imageID {
+			return i
+		}
+	}
+	return -1
+}
+
+func tooManyImages() error {
+	return invalidField("images", fmt.Sprintf("a property can have at most %d images", maxImageCount))
+}
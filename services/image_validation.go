@@ -0,0 +1,49 @@
+package services
+
+import (
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/services/validation"
+)
+
+// Limits for image fields, text limits match the columns of the property_images table
+const (
+	maxImageCount     = 30
+	maxImageURLLength = 2048
+	maxAltLength      = 255
+	maxCaptionLength  = 1000
+)
+
+var allowedImageURLSchemes = []string{"http", "https"}
+
+// validateImage checks a new image, the URL is only checked for images that are not uploaded
+func validateImage(image model.ImageCreate, uploaded bool) error {
+	validator := validation.NewValidator()
+
+	if !uploaded {
+		validator.Required("url", image.URL)
+		validator.MaxLength("url", image.URL, maxImageURLLength)
+		validator.URL("url", image.URL, allowedImageURLSchemes...)
+	}
+	validator.MaxLength("alt", image.Alt, maxAltLength)
+	validator.MaxLength("caption", image.Caption, maxCaptionLength)
+
+	return validator.Err()
+}
+
+// validateImageUpdate checks the given fields of an image update, count is the number of images of the property
+func validateImageUpdate(update model.ImageUpdate, count int) error {
+	validator := validation.NewValidator()
+
+	if update.Alt != nil {
+		validator.MaxLength("alt", *update.Alt, maxAltLength)
+	}
+	if update.Caption != nil {
+		validator.MaxLength("caption", *update.Caption, maxCaptionLength)
+	}
+	if update.Position != nil {
+		validator.Between("position", *update.Position, 0, count-1)
+	}
+	validator.Check(update.IsCover == nil || *update.IsCover, "is_cover", "can only be set to true, choose another image as the cover instead")
+
+	return validator.Err()
+}
@@ -1,14 +1,102 @@
 package model
 
+import "kirmac-site-backend/domain"
+
 type PropertyCreate struct {
-	Location    string   `json:"location"`
-	Price       int      `json:"price"`
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	Bedrooms    int      `json:"bedrooms"`
-	Bathrooms   int      `json:"bathrooms"`
-	SquareFeet  int      `json:"square_feet"`
-	AgentName   string   `json:"agent_name"`
-	AgentTitle  string   `json:"agent_title"`
-	ImageURLs   []string `json:"image_urls"`
+	Location     string   `json:"location"`
+	City         string   `json:"city"`
+	District     string   `json:"district"`
+	Neighborhood string   `json:"neighborhood"`
+	PostalCode   string   `json:"postal_code"`
+	Latitude     *float64 `json:"latitude"`
+	Longitude    *float64 `json:"longitude"`
+	Price        int      `json:"price"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Bedrooms     int      `json:"bedrooms"`
+	Bathrooms    int      `json:"bathrooms"`
+	SquareFeet   int      `json:"square_feet"`
+	AgentID      *int64   `json:"agent_id"`
+	// Status is only chosen on creation, draft or active, afterwards it changes through transitions
+	Status domain.PropertyStatus `json:"status,omitempty"`
+}
+
+type AgentCreate struct {
+	Name      string   `json:"name"`
+	Title     string   `json:"title"`
+	Phone     string   `json:"phone"`
+	Email     string   `json:"email"`
+	PhotoURL  string   `json:"photo_url"`
+	Bio       string   `json:"bio"`
+	Languages []string `json:"languages"`
+}
+
+const (
+	MergePatch = "merge"
+	JSONPatch  = "json"
+)
+
+// PropertyPatch is a partial update, Document is a JSON Merge Patch or a JSON Patch depending on Type
+type PropertyPatch struct {
+	Type     string
+	Document []byte
+}
+
+// ImageCreate describes a new image of a property, URL is only used for images that are not uploaded
+type ImageCreate struct {
+	URL     string `json:"url"`
+	Alt     string `json:"alt"`
+	Caption string `json:"caption"`
+	IsCover bool   `json:"is_cover"`
+}
+
+// ImageUpdate changes the given fields of an image, position moves the image and shifts the others
+type ImageUpdate struct {
+	Alt      *string `json:"alt"`
+	Caption  *string `json:"caption"`
+	Position *int    `json:"position"`
+	IsCover  *bool   `json:"is_cover"`
+}
+
+// ImageOrder lists the ids of every image of a property in the new order
+type ImageOrder struct {
+	ImageIDs []int64 `json:"image_ids"`
+}
+
+// StatusTransition moves a property to another status, the note explains why
+type StatusTransition struct {
+	Status domain.PropertyStatus `json:"status"`
+	Note   string                `json:"note"`
+}
+
+// UserCreate is a new user account, the password is only kept as a bcrypt hash
+type UserCreate struct {
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Role     domain.Role `json:"role"`
+}
+
+// Credentials sign a user in
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// APIKeyCreate names a new API key after the integration that uses it
+type APIKeyCreate struct {
+	Name string `json:"name"`
+}
+
+// TokenRefresh exchanges a refresh token for a new pair of tokens
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TenantCreate is a new agency, Hosts are the host names its site is served on
+type TenantCreate struct {
+	Slug        string   `json:"slug"`
+	Name        string   `json:"name"`
+	Currency    string   `json:"currency"`
+	Hosts       []string `json:"hosts"`
+	CorsOrigins []string `json:"cors_origins"`
 }
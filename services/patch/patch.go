@@ -0,0 +1,376 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned when a patch document is malformed or cannot be applied
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrTestFailed is returned when a JSON Patch test operation does not match the document
+	ErrTestFailed = errors.New("patch test failed")
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to document
+func MergePatch(document []byte, patch []byte) ([]byte, error) {
+	target, err := decode(document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: document is not valid JSON: %v", ErrInvalidPatch, err)
+	}
+	patchValue, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: merge patch is not valid JSON: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergeValue(target, patchValue))
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// Operation is a single JSON Patch operation
+type Operation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// UnmarshalJSON decodes an operation keeping a value of null, which a pointer field alone cannot tell apart from a missing value
+func (operation *Operation) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for name, target := range map[string]*string{"op": &operation.Op, "path": &operation.Path, "from": &operation.From} {
+		if member, ok := members[name]; ok {
+			if err := json.Unmarshal(member, target); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	if value, ok := members["value"]; ok {
+		operation.Value = &value
+	}
+	return nil
+}
+
+// JSONPatch applies a JSON Patch (RFC 6902) to document, either every operation succeeds or none is applied
+func JSONPatch(document []byte, patch []byte) ([]byte, error) {
+	target, err := decode(document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: document is not valid JSON: %v", ErrInvalidPatch, err)
+	}
+	var operations []Operation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("%w: JSON patch must be an array of operations: %v", ErrInvalidPatch, err)
+	}
+	for i, operation := range operations {
+		target, err = applyOperation(target, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func applyOperation(document interface{}, operation Operation) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch operation.Op {
+	case "add":
+		value, err := operationValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		return add(document, path, value)
+	case "remove":
+		document, _, err := remove(document, path)
+		return document, err
+	case "replace":
+		value, err := operationValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		document, _, err = remove(document, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(document, path, value)
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(document, from)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidPatch)
+			}
+			if document, _, err = remove(document, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+		return add(document, path, value)
+	case "test":
+		expected, err := operationValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(document, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(actual, expected) {
+			return nil, ErrTestFailed
+		}
+		return document, nil
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, operation.Op)
+}
+
+func operationValue(operation Operation) (interface{}, error) {
+	if operation.Value == nil {
+		return nil, fmt.Errorf("%w: value is required", ErrInvalidPatch)
+	}
+	value, err := decode(*operation.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return value, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func get(document interface{}, path []string) (interface{}, error) {
+	current := document
+	for _, token := range path {
+		switch container := current.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q does not exist", ErrInvalidPatch, token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = container[index]
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrInvalidPatch, token)
+		}
+	}
+	return current, nil
+}
+
+// add sets value at path, inserting into arrays, and returns the updated document
+func add(document interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := get(document, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[last] = value
+		return document, nil
+	case []interface{}:
+		index := len(container)
+		if last != "-" {
+			if index, err = arrayIndex(last, len(container)); err != nil {
+				return nil, err
+			}
+		}
+		updated := append(container[:index:index], append([]interface{}{value}, container[index:]...)...)
+		return set(document, parentPath, updated)
+	}
+	return nil, fmt.Errorf("%w: cannot add %q to a scalar value", ErrInvalidPatch, last)
+}
+
+// set replaces the existing value at path and returns the updated document
+func set(document interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[last] = value
+	case []interface{}:
+		index, err := arrayIndex(last, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = value
+	}
+	return document, nil
+}
+
+// remove deletes the value at path and returns the updated document and the removed value
+func remove(document interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, document, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := get(document, parentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		value, ok := container[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %q does not exist", ErrInvalidPatch, last)
+		}
+		delete(container, last)
+		return document, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(container)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := container[index]
+		updated := append(container[:index:index], container[index+1:]...)
+		document, err = set(document, parentPath, updated)
+		return document, value, err
+	}
+	return nil, nil, fmt.Errorf("%w: cannot remove %q from a scalar value", ErrInvalidPatch, last)
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: %q is not a valid array index", ErrInvalidPatch, token)
+	}
+	return index, nil
+}
+
+func isPrefix(prefix []string, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for name, item := range v {
+			copied[name] = deepCopy(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// jsonEqual compares decoded JSON values, treating numbers by value rather than by their text
+func jsonEqual(a interface{}, b interface{}) bool {
+	aNumber, aIsNumber := a.(json.Number)
+	bNumber, bIsNumber := b.(json.Number)
+	if aIsNumber && bIsNumber {
+		aFloat, aErr := aNumber.Float64()
+		bFloat, bErr := bNumber.Float64()
+		return aErr == nil && bErr == nil && aFloat == bFloat
+	}
+	switch aValue := a.(type) {
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for name, item := range aValue {
+			other, ok := bValue[name]
+			if !ok || !jsonEqual(item, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for i := range aValue {
+			if !jsonEqual(aValue[i], bValue[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+)
+
+// action is something the policy allows or denies, named so it reads well in error messages
+type action string
+
+const (
+	createProperty action = "create listings"
+	editProperty   action = "change listings"
+	manageTrash    action = "manage the trash"
+	viewHistory    action = "view the audit history"
+	viewUnlisted   action = "see unlisted listings"
+	manageAgents   action = "manage agents"
+	manageAPIKeys  action = "manage API keys"
+)
+
+// scope is what a role may take an action on
+type scope int
+
+const (
+	noResource scope = iota
+	ownResources
+	anyResource
+)
+
+// policy is every permission of every role, an action missing for a role is denied
+var policy = map[domain.Role]map[action]scope{
+	domain.RoleAdmin: {
+		createProperty: anyResource,
+		editProperty:   anyResource,
+		manageTrash:    anyResource,
+		viewHistory:    anyResource,
+		viewUnlisted:   anyResource,
+		manageAgents:   anyResource,
+		manageAPIKeys:  anyResource,
+	},
+	domain.RoleAgent: {
+		createProperty: anyResource,
+		editProperty:   ownResources,
+		viewUnlisted:   anyResource,
+		manageAPIKeys:  ownResources,
+	},
+	domain.RoleViewer: {},
+}
+
+// authorize checks that whoever makes the request of ctx may take the action on a resource owned by owner,
+// owner is nil for resources without an owner and for actions that are not about a single resource
+func authorize(ctx context.Context, action action, owner *int64) error {
+	principal, err := principalOf(ctx, action)
+	if err != nil {
+		return err
+	}
+	switch policy[principal.Role][action] {
+	case anyResource:
+		return nil
+	case ownResources:
+		if owner != nil && *owner == principal.UserID {
+			return nil
+		}
+		return fmt.Errorf("%w: %s users can only %s they own", domain.ErrForbidden, principal.Role, action)
+	}
+	return fmt.Errorf("%w: %s users cannot %s", domain.ErrForbidden, principal.Role, action)
+}
+
+// ownerScope returns the user whose resources the request of ctx may take the action on,
+// or 0 when it may take it on every resource
+func ownerScope(ctx context.Context, action action) (int64, error) {
+	principal, err := principalOf(ctx, action)
+	if err != nil {
+		return 0, err
+	}
+	switch policy[principal.Role][action] {
+	case anyResource:
+		return 0, nil
+	case ownResources:
+		return principal.UserID, nil
+	}
+	return 0, fmt.Errorf("%w: %s users cannot %s", domain.ErrForbidden, principal.Role, action)
+}
+
+// authorizeStatus checks that whoever makes the request of ctx may see properties in status,
+// only the statuses that are listed are shown to everyone
+func authorizeStatus(ctx context.Context, status domain.PropertyStatus) error {
+	if domain.IsListedStatus(status) {
+		return nil
+	}
+	return authorize(ctx, viewUnlisted, nil)
+}
+
+func principalOf(ctx context.Context, action action) (domain.Principal, error) {
+	principal, ok := requestctx.Principal(ctx)
+	if !ok {
+		return domain.Principal{}, fmt.Errorf("%w: sign in to %s", domain.ErrUnauthorized, action)
+	}
+	return principal, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/services/model"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// withAudit runs change in a transaction and appends an audit entry comparing the property before and after it,
+// so the change and its entry are saved together or not at all. An update that changes no field is not recorded.
+func (service *PropertyService) withAudit(ctx context.Context, action domain.AuditAction,
+	change func(ctx context.Context) (before *domain.Property, after *domain.Property, err error)) (domain.Property, error) {
+	return auditChange(ctx, service.transactions, service.audit, action, change)
+}
+
+// auditChange is withAudit for every service that changes properties
+func auditChange(ctx context.Context, transactions persistence.ITransactionManager, audit persistence.IAuditRepository, action domain.AuditAction,
+	change func(ctx context.Context) (before *domain.Property, after *domain.Property, err error)) (domain.Property, error) {
+	var result domain.Property
+	err := transactions.WithTx(ctx, func(ctx context.Context) error {
+		before, after, err := change(ctx)
+		if err != nil {
+			return err
+		}
+		entry := domain.AuditEntry{
+			EntityType: domain.AuditEntityProperty,
+			Action:     action,
+			Actor:      requestctx.Actor(ctx),
+			RequestID:  requestctx.RequestID(ctx),
+			Changes:    []domain.FieldChange{},
+		}
+		if after != nil {
+			result = *after
+			entry.EntityID = after.ID
+		} else {
+			entry.EntityID = before.ID
+		}
+		if entry.Changes, err = diffProperties(before, after); err != nil {
+			return err
+		}
+		if action == domain.AuditUpdate && len(entry.Changes) == 0 {
+			return nil
+		}
+		return audit.AddEntry(ctx, entry)
+	})
+	if err != nil {
+		return domain.Property{}, err
+	}
+	return result, nil
+}
+
+// diffProperties lists the audited fields whose values differ between before and after by json name,
+// a nil property has no values so creations and deletions list every field that is set
+func diffProperties(before *domain.Property, after *domain.Property) ([]domain.FieldChange, error) {
+	beforeValues, err := auditedValues(before)
+	if err != nil {
+		return nil, err
+	}
+	afterValues, err := auditedValues(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range beforeValues {
+		fields = append(fields, field)
+	}
+	for field := range afterValues {
+		if _, ok := beforeValues[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []domain.FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(beforeValues[field], afterValues[field]) {
+			changes = append(changes, domain.FieldChange{Field: field, Before: beforeValues[field], After: afterValues[field]})
+		}
+	}
+	return changes, nil
+}
+
+// auditedValues returns the fields a client can set on a property, its images and when it was deleted
+// with their json names and values
+func auditedValues(property *domain.Property) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if property == nil {
+		return values, nil
+	}
+	encoded, err := json.Marshal(struct {
+		model.PropertyCreate
+		Images    []domain.PropertyImage `json:"images,omitempty"`
+		DeletedAt *time.Time             `json:"deleted_at,omitempty"`
+	}{toPropertyCreate(*property), property.Images, property.DeletedAt})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"kirmac-site-backend/common/geojson"
+	"kirmac-site-backend/domain"
+	"math"
+)
+
+const (
+	// maxGeoJSONFeatures caps a point export, larger results are cut and marked as truncated
+	maxGeoJSONFeatures = 10000
+	// MaxClusterZoom is the deepest zoom level a clustered export accepts
+	MaxClusterZoom = 20
+	// coverVariant is the image variant exported for the cover of a property
+	coverVariant = "thumbnail"
+)
+
+// GeoJSONExport writes an export to w once the response can be streamed
+type GeoJSONExport func(w io.Writer) error
+
+// geoJSONProperty is what a point feature tells about its property
+type geoJSONProperty struct {
+	ID         int64  `json:"id"`
+	Title      string `json:"title"`
+	Price      int    `json:"price"`
+	Bedrooms   int    `json:"bedrooms"`
+	Bathrooms  int    `json:"bathrooms"`
+	SquareFeet int    `json:"square_feet"`
+	City       string `json:"city,omitempty"`
+	Location   string `json:"location"`
+	CoverImage string `json:"cover_image,omitempty"`
+}
+
+// geoJSONCluster is what a cluster feature tells about the properties it groups
+type geoJSONCluster struct {
+	Cluster    bool  `json:"cluster"`
+	PointCount int64 `json:"point_count"`
+	MinPrice   int   `json:"min_price"`
+	MaxPrice   int   `json:"max_price"`
+}
+
+// ExportGeoJSON exports the properties with coordinates matching the criteria as point features in their sort order,
+// the criteria are checked and the query is run before the export is returned so failures can still be reported
+func (service *PropertyService) ExportGeoJSON(ctx context.Context, criteria domain.PropertyCriteria) (GeoJSONExport, error) {
+	criteria, err := normalizeCriteria(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+	criteria.HasCoordinates = true
+	rows, err := service.repository.QueryProperties(ctx, criteria, maxGeoJSONFeatures+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w io.Writer) error {
+		defer rows.Close()
+		writer := geojson.NewWriter(w)
+		count := 0
+		for rows.Next() {
+			if count == maxGeoJSONFeatures {
+				return writer.Close(map[string]interface{}{"truncated": true})
+			}
+			property, err := rows.Property()
+			if err != nil {
+				return err
+			}
+			if err := writer.Write(toPointFeature(property)); err != nil {
+				return err
+			}
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return writer.Close(nil)
+	}, nil
+}
+
+// ExportGeoJSONClusters exports the properties with coordinates matching the criteria grouped into a grid sized for zoom,
+// each cell becomes a single feature at the average position of its properties
+func (service *PropertyService) ExportGeoJSONClusters(ctx context.Context, criteria domain.PropertyCriteria, zoom int) (GeoJSONExport, error) {
+	if zoom < 0 || zoom > MaxClusterZoom {
+		return nil, invalidField("zoom", fmt.Sprintf("must be between 0 and %d", MaxClusterZoom))
+	}
+	criteria, err := normalizeCriteria(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := service.repository.GetPropertyClusters(ctx, criteria, clusterCellDegrees(zoom))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w io.Writer) error {
+		writer := geojson.NewWriter(w)
+		for _, cluster := range clusters {
+			feature := geojson.NewPointFeature(nil, cluster.Center.Latitude, cluster.Center.Longitude, geoJSONCluster{
+				Cluster:    true,
+				PointCount: cluster.Count,
+				MinPrice:   cluster.MinPrice,
+				MaxPrice:   cluster.MaxPrice,
+			})
+			if err := writer.Write(feature); err != nil {
+				return err
+			}
+		}
+		return writer.Close(map[string]interface{}{"zoom": zoom})
+	}, nil
+}
+
+// clusterCellDegrees is the size of a grid cell at zoom, four cells span a 256 pixel map tile
+func clusterCellDegrees(zoom int) float64 {
+	return 360 / (4 * math.Pow(2, float64(zoom)))
+}
+
+func toPointFeature(property domain.Property) geojson.Feature {
+	return geojson.NewPointFeature(property.ID, *property.Latitude, *property.Longitude, geoJSONProperty{
+		ID:         property.ID,
+		Title:      property.Title,
+		Price:      property.Price,
+		Bedrooms:   property.Bedrooms,
+		Bathrooms:  property.Bathrooms,
+		SquareFeet: property.SquareFeet,
+		City:       property.City,
+		Location:   property.Location,
+		CoverImage: coverImageURL(property.Images),
+	})
+}
+
+// coverImageURL prefers the thumbnail of the cover image over the original
+func coverImageURL(images []domain.PropertyImage) string {
+	for _, image := range images {
+		if !image.IsCover {
+			continue
+		}
+		if variant, ok := image.Variants[coverVariant]; ok {
+			return variant.URL
+		}
+		return image.URL
+	}
+	return ""
+}
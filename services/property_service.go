@@ -1,127 +1,498 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"kirmac-site-backend/common/requestctx"
 	"kirmac-site-backend/domain"
 	"kirmac-site-backend/persistence"
 	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/services/patch"
+	"kirmac-site-backend/services/validation"
+	"strings"
 )
 
 // IPropertyService defines the service interface for property operations
 type IPropertyService interface {
-	GetAllProperties() ([]model.PropertyCreate, error)
-	GetPropertyById(id int64) (model.PropertyCreate, error)
-	AddProperty(property model.PropertyCreate) error
-	UpdateProperty(id int64, property model.PropertyCreate) error
-	DeleteById(id int64) (bool, error)
+	GetAllProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyPage, error)
+	GetNearbyProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyDistancePage, error)
+	ExportGeoJSON(ctx context.Context, criteria domain.PropertyCriteria) (GeoJSONExport, error)
+	ExportGeoJSONClusters(ctx context.Context, criteria domain.PropertyCriteria, zoom int) (GeoJSONExport, error)
+	GetPropertyById(ctx context.Context, id int64) (domain.Property, error)
+	AddProperty(ctx context.Context, property model.PropertyCreate) (domain.Property, error)
+	UpdateProperty(ctx context.Context, id int64, property model.PropertyCreate, version int64) (domain.Property, error)
+	PatchProperty(ctx context.Context, id int64, patch model.PropertyPatch, version int64) (domain.Property, error)
+	DeleteById(ctx context.Context, id int64, version int64) (bool, error)
+	SearchProperties(ctx context.Context, query string, page int, pageSize int) (domain.PropertySearchPage, error)
+	TransitionProperty(ctx context.Context, id int64, transition model.StatusTransition, version int64) (domain.Property, error)
+	GetStatusHistory(ctx context.Context, id int64) (domain.StatusHistory, error)
+	GetDeletedProperties(ctx context.Context, page int, pageSize int) (domain.PropertyPage, error)
+	RestoreProperty(ctx context.Context, id int64) (domain.Property, error)
+	GetPropertyHistory(ctx context.Context, id int64, page int, pageSize int) (domain.AuditPage, error)
 }
 
 // PropertyService implements IPropertyService and provides business logic for property operations
 type PropertyService struct {
-	repository persistence.IPropertyRepository
+	repository   persistence.IPropertyRepository
+	audit        persistence.IAuditRepository
+	transactions persistence.ITransactionManager
 }
 
-// NewPropertyService creates a new instance of PropertyService
-func NewPropertyService(repository persistence.IPropertyRepository) *PropertyService {
+// NewPropertyService creates a new instance of PropertyService, every change is saved together with its audit entry
+func NewPropertyService(repository persistence.IPropertyRepository, audit persistence.IAuditRepository, transactions persistence.ITransactionManager) *PropertyService {
 	return &PropertyService{
-		repository: repository,
+		repository:   repository,
+		audit:        audit,
+		transactions: transactions,
 	}
 }
 
-// GetAllProperties retrieves all properties
-func (service *PropertyService) GetAllProperties() ([]model.PropertyCreate, error) {
-	properties, err := service.repository.GetAllProperties()
+// GetAllProperties retrieves a page of properties matching the criteria
+func (service *PropertyService) GetAllProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyPage, error) {
+	criteria, err := normalizeCriteria(ctx, criteria)
 	if err != nil {
-		return nil, err
-	}
-	var propertyModels []model.PropertyCreate
-	for _, property := range properties {
-		propertyModels = append(propertyModels, model.PropertyCreate{
-			Location:    property.Location,
-			Price:       property.Price,
-			Title:       property.Title,
-			Description: property.Description,
-			Bedrooms:    property.Bedrooms,
-			Bathrooms:   property.Bathrooms,
-			SquareFeet:  property.SquareFeet,
-			AgentName:   property.AgentName,
-			AgentTitle:  property.AgentTitle,
-			ImageURLs:   property.ImageURLs,
-		})
-	}
-	return propertyModels, nil
-}
-
-// GetPropertyById retrieves a property by id
-func (service *PropertyService) GetPropertyById(id int64) (model.PropertyCreate, error) {
-	property, err := service.repository.GetPropertyById(id)
+		return domain.PropertyPage{}, err
+	}
+	return service.repository.GetAllProperties(ctx, criteria)
+}
+
+// GetNearbyProperties retrieves a page of properties within criteria.Near matching the other criteria, nearest first
+func (service *PropertyService) GetNearbyProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyDistancePage, error) {
+	if criteria.Near == nil {
+		return domain.PropertyDistancePage{}, invalidField("radius_km", "a center and radius are required")
+	}
+	criteria, err := normalizeCriteria(ctx, criteria)
 	if err != nil {
-		return model.PropertyCreate{}, err
+		return domain.PropertyDistancePage{}, err
 	}
-	return model.PropertyCreate{
-		Location:    property.Location,
-		Price:       property.Price,
-		Title:       property.Title,
-		Description: property.Description,
-		Bedrooms:    property.Bedrooms,
-		Bathrooms:   property.Bathrooms,
-		SquareFeet:  property.SquareFeet,
-		AgentName:   property.AgentName,
-		AgentTitle:  property.AgentTitle,
-		ImageURLs:   property.ImageURLs,
-	}, nil
+	return service.repository.GetNearbyProperties(ctx, criteria)
 }
 
-// AddProperty adds a new property
-func (service *PropertyService) AddProperty(property model.PropertyCreate) error {
+// GetPropertyById retrieves a property by id, a property that is not listed is only found by the users who may see it
+func (service *PropertyService) GetPropertyById(ctx context.Context, id int64) (domain.Property, error) {
+	return getVisibleProperty(ctx, service.repository, id)
+}
+
+// AddProperty adds a new property owned by whoever creates it and returns it as persisted, it is active unless created as a draft
+func (service *PropertyService) AddProperty(ctx context.Context, property model.PropertyCreate) (domain.Property, error) {
+	if err := authorize(ctx, createProperty, nil); err != nil {
+		return domain.Property{}, err
+	}
 	err := validateProperty(property)
 	if err != nil {
-		return err
-	}
-	service.repository.AddProperty(domain.Property{
-		Location:    property.Location,
-		Price:       property.Price,
-		Title:       property.Title,
-		Description: property.Description,
-		Bedrooms:    property.Bedrooms,
-		Bathrooms:   property.Bathrooms,
-		SquareFeet:  property.SquareFeet,
-		AgentName:   property.AgentName,
-		AgentTitle:  property.AgentTitle,
-		ImageURLs:   property.ImageURLs,
+		return domain.Property{}, err
+	}
+	if property.Status == "" {
+		property.Status = domain.StatusActive
+	}
+	if err := validateNewStatus(property.Status); err != nil {
+		return domain.Property{}, err
+	}
+	return service.withAudit(ctx, domain.AuditCreate, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		added := toDomainProperty(property)
+		if principal, _ := requestctx.Principal(ctx); principal.UserID != 0 {
+			added.OwnerID = &principal.UserID
+		}
+		added, err := service.repository.AddProperty(ctx, added)
+		return nil, &added, err
 	})
-	return nil
 }
 
-// UpdateProperty updates a property
-func (service *PropertyService) UpdateProperty(id int64, property model.PropertyCreate) error {
+// UpdateProperty updates a property, a non-zero version must match the stored version
+func (service *PropertyService) UpdateProperty(ctx context.Context, id int64, property model.PropertyCreate, version int64) (domain.Property, error) {
 	err := validateProperty(property)
 	if err != nil {
-		return err
-	}
-
-	return service.repository.UpdateProperty(id, domain.Property{
-		Location:    property.Location,
-		Price:       property.Price,
-		Title:       property.Title,
-		Description: property.Description,
-		Bedrooms:    property.Bedrooms,
-		Bathrooms:   property.Bathrooms,
-		SquareFeet:  property.SquareFeet,
-		AgentName:   property.AgentName,
-		AgentTitle:  property.AgentTitle,
-		ImageURLs:   property.ImageURLs,
+		return domain.Property{}, err
+	}
+
+	return service.withAudit(ctx, domain.AuditUpdate, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		current, err := service.repository.GetPropertyForUpdate(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := authorize(ctx, editProperty, current.OwnerID); err != nil {
+			return nil, nil, err
+		}
+		if property.Status != "" && property.Status != current.Status {
+			return nil, nil, statusNotEditable()
+		}
+		updated := toDomainProperty(property)
+		updated.Version = version
+		updated, err = service.repository.UpdateProperty(ctx, id, updated)
+		return &current, &updated, err
+	})
+}
+
+// PatchProperty applies a partial update to the stored property, validates the result and saves the changed fields.
+// A non-zero version must match the stored version.
+func (service *PropertyService) PatchProperty(ctx context.Context, id int64, propertyPatch model.PropertyPatch, version int64) (domain.Property, error) {
+	return service.withAudit(ctx, domain.AuditUpdate, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		current, err := service.repository.GetPropertyForUpdate(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := authorize(ctx, editProperty, current.OwnerID); err != nil {
+			return nil, nil, err
+		}
+		if version != 0 && current.Version != version {
+			return nil, nil, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+		}
+		property, err := applyPatch(current, propertyPatch)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		updated := toDomainProperty(property)
+		updated.ID = current.ID
+		// the patch was computed from the version just read, so it must not overwrite a concurrent change
+		updated.Version = current.Version
+		fields := changedFields(current, updated)
+		if len(fields) == 0 {
+			return &current, &current, nil
+		}
+		updated, err = service.repository.PatchProperty(ctx, id, updated, fields)
+		return &current, &updated, err
+	})
+}
+
+// applyPatch applies a patch to the editable fields of a property and validates the result
+func applyPatch(current domain.Property, propertyPatch model.PropertyPatch) (model.PropertyCreate, error) {
+	document, err := json.Marshal(toPropertyCreate(current))
+	if err != nil {
+		return model.PropertyCreate{}, err
+	}
+
+	var patched []byte
+	switch propertyPatch.Type {
+	case model.MergePatch:
+		patched, err = patch.MergePatch(document, propertyPatch.Document)
+	case model.JSONPatch:
+		patched, err = patch.JSONPatch(document, propertyPatch.Document)
+	default:
+		return model.PropertyCreate{}, fmt.Errorf("unsupported patch type %q", propertyPatch.Type)
+	}
+	if errors.Is(err, patch.ErrTestFailed) {
+		return model.PropertyCreate{}, fmt.Errorf("%w: %v", domain.ErrConflict, err)
+	}
+	if err != nil {
+		return model.PropertyCreate{}, invalidField("patch", err.Error())
+	}
+
+	var property model.PropertyCreate
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&property); err != nil {
+		return model.PropertyCreate{}, invalidField("patch", fmt.Sprintf("patched property is invalid: %v", err))
+	}
+	if err := validateProperty(property); err != nil {
+		return model.PropertyCreate{}, err
+	}
+	if property.Status != "" && property.Status != current.Status {
+		return model.PropertyCreate{}, statusNotEditable()
+	}
+	return property, nil
+}
+
+// DeleteById moves a property to the trash, a non-zero version must match the stored version
+func (service *PropertyService) DeleteById(ctx context.Context, id int64, version int64) (bool, error) {
+	_, err := service.withAudit(ctx, domain.AuditDelete, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		current, err := service.repository.GetPropertyForUpdate(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := authorize(ctx, editProperty, current.OwnerID); err != nil {
+			return nil, nil, err
+		}
+		deleted, err := service.repository.DeleteById(ctx, id, version)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !deleted {
+			return nil, nil, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+		}
+		return &current, nil, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetDeletedProperties retrieves a page of the properties in the trash, most recently deleted first
+func (service *PropertyService) GetDeletedProperties(ctx context.Context, page int, pageSize int) (domain.PropertyPage, error) {
+	if err := authorize(ctx, manageTrash, nil); err != nil {
+		return domain.PropertyPage{}, err
+	}
+	criteria, err := normalizeCriteria(ctx, domain.PropertyCriteria{Page: page, PageSize: pageSize})
+	if err != nil {
+		return domain.PropertyPage{}, err
+	}
+	return service.repository.GetDeletedProperties(ctx, criteria.Page, criteria.PageSize)
+}
+
+// RestoreProperty takes a property out of the trash with the status it was deleted in
+func (service *PropertyService) RestoreProperty(ctx context.Context, id int64) (domain.Property, error) {
+	if err := authorize(ctx, manageTrash, nil); err != nil {
+		return domain.Property{}, err
+	}
+	return service.withAudit(ctx, domain.AuditRestore, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		deleted, err := service.repository.GetDeletedPropertyForUpdate(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		restored, err := service.repository.RestoreProperty(ctx, id)
+		return &deleted, &restored, err
+	})
+}
+
+// GetPropertyHistory retrieves a page of the audit entries of a property, newest first.
+// The history of a deleted property stays available.
+func (service *PropertyService) GetPropertyHistory(ctx context.Context, id int64, page int, pageSize int) (domain.AuditPage, error) {
+	if err := authorize(ctx, viewHistory, nil); err != nil {
+		return domain.AuditPage{}, err
+	}
+	criteria, err := normalizeCriteria(ctx, domain.PropertyCriteria{Page: page, PageSize: pageSize})
+	if err != nil {
+		return domain.AuditPage{}, err
+	}
+	history, err := service.audit.GetEntries(ctx, domain.AuditEntityProperty, id, criteria.Page, criteria.PageSize)
+	if err != nil {
+		return domain.AuditPage{}, err
+	}
+	if history.TotalCount == 0 {
+		if _, err := service.repository.GetPropertyById(ctx, id); err != nil {
+			return domain.AuditPage{}, err
+		}
+	}
+	return history, nil
+}
+
+// SearchProperties runs a full-text search over property titles, descriptions and locations
+func (service *PropertyService) SearchProperties(ctx context.Context, query string, page int, pageSize int) (domain.PropertySearchPage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return domain.PropertySearchPage{}, invalidField("q", "search query must not be empty")
+	}
+	criteria, err := normalizeCriteria(ctx, domain.PropertyCriteria{Page: page, PageSize: pageSize})
+	if err != nil {
+		return domain.PropertySearchPage{}, err
+	}
+	return service.repository.Search(ctx, query, criteria.Page, criteria.PageSize)
+}
+
+// TransitionProperty moves a property to another status of its lifecycle and records who made the change.
+// A non-zero version must match the stored version.
+func (service *PropertyService) TransitionProperty(ctx context.Context, id int64, transition model.StatusTransition, version int64) (domain.Property, error) {
+	transition.Note = strings.TrimSpace(transition.Note)
+	if err := validateTransition(transition); err != nil {
+		return domain.Property{}, err
+	}
+	return service.withAudit(ctx, domain.AuditUpdate, func(ctx context.Context) (*domain.Property, *domain.Property, error) {
+		current, err := service.repository.GetPropertyForUpdate(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := authorize(ctx, editProperty, current.OwnerID); err != nil {
+			return nil, nil, err
+		}
+		if version != 0 && current.Version != version {
+			return nil, nil, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+		}
+		if !domain.CanTransition(current.Status, transition.Status) {
+			return nil, nil, fmt.Errorf("a %s property cannot become %s, it can become %s: %w",
+				current.Status, transition.Status, joinStatuses(domain.AllowedTransitions(current.Status)), domain.ErrConflict)
+		}
+		transitioned, err := service.repository.TransitionProperty(ctx, id, domain.StatusChange{
+			PropertyID: id,
+			FromStatus: current.Status,
+			ToStatus:   transition.Status,
+			Actor:      requestctx.Actor(ctx),
+			Note:       transition.Note,
+		}, current.Version)
+		return &current, &transitioned, err
 	})
 }
 
-// DeleteById deletes a property by id
-func (service *PropertyService) DeleteById(id int64) (bool, error) {
-	return service.repository.DeleteById(id)
+// GetStatusHistory retrieves the status of a property, the statuses it can move to and its past transitions
+func (service *PropertyService) GetStatusHistory(ctx context.Context, id int64) (domain.StatusHistory, error) {
+	property, err := getVisibleProperty(ctx, service.repository, id)
+	if err != nil {
+		return domain.StatusHistory{}, err
+	}
+	changes, err := service.repository.GetStatusChanges(ctx, id)
+	if err != nil {
+		return domain.StatusHistory{}, err
+	}
+	return domain.StatusHistory{
+		Status:             property.Status,
+		StatusChangedAt:    property.StatusChangedAt,
+		AllowedTransitions: domain.AllowedTransitions(property.Status),
+		Changes:            changes,
+	}, nil
+}
+
+func toPropertyCreate(property domain.Property) model.PropertyCreate {
+	return model.PropertyCreate{
+		Location:     property.Location,
+		City:         property.City,
+		District:     property.District,
+		Neighborhood: property.Neighborhood,
+		PostalCode:   property.PostalCode,
+		Latitude:     property.Latitude,
+		Longitude:    property.Longitude,
+		Price:        property.Price,
+		Title:        property.Title,
+		Description:  property.Description,
+		Bedrooms:     property.Bedrooms,
+		Bathrooms:    property.Bathrooms,
+		SquareFeet:   property.SquareFeet,
+		AgentID:      property.AgentID,
+		Status:       property.Status,
+	}
+}
+
+func toDomainProperty(property model.PropertyCreate) domain.Property {
+	return domain.Property{
+		Location:     property.Location,
+		City:         property.City,
+		District:     property.District,
+		Neighborhood: property.Neighborhood,
+		PostalCode:   property.PostalCode,
+		Latitude:     property.Latitude,
+		Longitude:    property.Longitude,
+		Price:        property.Price,
+		Title:        property.Title,
+		Description:  property.Description,
+		Bedrooms:     property.Bedrooms,
+		Bathrooms:    property.Bathrooms,
+		SquareFeet:   property.SquareFeet,
+		AgentID:      property.AgentID,
+		Status:       property.Status,
+	}
+}
+
+// changedFields lists the json names of the fields that differ between the two properties
+func changedFields(current domain.Property, updated domain.Property) []string {
+	var fields []string
+	addIfChanged := func(changed bool, field string) {
+		if changed {
+			fields = append(fields, field)
+		}
+	}
+	addIfChanged(current.Location != updated.Location, "location")
+	addIfChanged(current.City != updated.City, "city")
+	addIfChanged(current.District != updated.District, "district")
+	addIfChanged(current.Neighborhood != updated.Neighborhood, "neighborhood")
+	addIfChanged(current.PostalCode != updated.PostalCode, "postal_code")
+	addIfChanged(!equalFloats(current.Latitude, updated.Latitude), "latitude")
+	addIfChanged(!equalFloats(current.Longitude, updated.Longitude), "longitude")
+	addIfChanged(current.Price != updated.Price, "price")
+	addIfChanged(current.Title != updated.Title, "title")
+	addIfChanged(current.Description != updated.Description, "description")
+	addIfChanged(current.Bedrooms != updated.Bedrooms, "bedrooms")
+	addIfChanged(current.Bathrooms != updated.Bathrooms, "bathrooms")
+	addIfChanged(current.SquareFeet != updated.SquareFeet, "square_feet")
+	addIfChanged(!equalIDs(current.AgentID, updated.AgentID), "agent_id")
+	return fields
+}
+
+func equalIDs(a *int64, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
-func validateProperty(property model.PropertyCreate) error {
-	if property.Price <= 0 {
-		return errors.New("Price must be greater than zero")
+func equalFloats(a *float64, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func statusNotEditable() error {
+	return invalidField("status", "can only be changed with a transition")
+}
+
+func invalidField(field string, message string) error {
+	return domain.NewValidationError(domain.FieldError{Field: field, Message: message})
+}
+
+// getVisibleProperty reads a property that the request of ctx may see, others are not found so their existence is not revealed
+func getVisibleProperty(ctx context.Context, repository persistence.IPropertyRepository, id int64) (domain.Property, error) {
+	property, err := repository.GetPropertyById(ctx, id)
+	if err != nil {
+		return domain.Property{}, err
+	}
+	if authorizeStatus(ctx, property.Status) != nil {
+		return domain.Property{}, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+	}
+	return property, nil
+}
+
+// normalizeCriteria applies paging, sorting and status defaults and rejects criteria the repository cannot handle
+// or the request of ctx may not ask for
+func normalizeCriteria(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyCriteria, error) {
+	if criteria.Page <= 0 {
+		criteria.Page = domain.DefaultPage
+	}
+	if criteria.Page > domain.MaxPage {
+		return criteria, invalidField("page", fmt.Sprintf("must be at most %d", domain.MaxPage))
+	}
+	if criteria.PageSize <= 0 {
+		criteria.PageSize = domain.DefaultPageSize
+	}
+	if criteria.PageSize > domain.MaxPageSize {
+		criteria.PageSize = domain.MaxPageSize
+	}
+	if criteria.SortField == "" {
+		criteria.SortField = domain.SortByID
+	}
+	if !domain.IsSortField(criteria.SortField) {
+		return criteria, invalidField("sort", fmt.Sprintf("unsupported sort field %s", criteria.SortField))
+	}
+	criteria.SortDirection = strings.ToLower(criteria.SortDirection)
+	if criteria.SortDirection == "" {
+		criteria.SortDirection = domain.SortAscending
+	}
+	if criteria.SortDirection != domain.SortAscending && criteria.SortDirection != domain.SortDescending {
+		return criteria, invalidField("order", fmt.Sprintf("unsupported sort direction %s", criteria.SortDirection))
+	}
+	if len(criteria.Statuses) == 0 {
+		criteria.Statuses = domain.ListedStatuses
+	}
+	for _, status := range criteria.Statuses {
+		if !domain.IsPropertyStatus(status) {
+			return criteria, invalidField("status", fmt.Sprintf("unsupported status %s", status))
+		}
+		if err := authorizeStatus(ctx, status); err != nil {
+			return criteria, err
+		}
+	}
+	if criteria.MinPrice != nil && criteria.MaxPrice != nil && *criteria.MinPrice > *criteria.MaxPrice {
+		return criteria, invalidField("min_price", "must not be greater than max_price")
+	}
+	if criteria.MinSquareFeet != nil && criteria.MaxSquareFeet != nil && *criteria.MinSquareFeet > *criteria.MaxSquareFeet {
+		return criteria, invalidField("min_square_feet", "must not be greater than max_square_feet")
+	}
+	return criteria, validateGeoCriteria(criteria)
+}
+
+// validateGeoCriteria checks the radius and bounding box of the criteria, a bounding box may cross the antimeridian but not the poles
+func validateGeoCriteria(criteria domain.PropertyCriteria) error {
+	validator := validation.NewValidator()
+	if criteria.Near != nil {
+		validateGeoPoint(validator, "lat", "lng", criteria.Near.Center)
+		validator.Check(criteria.Near.RadiusKm > 0 && criteria.Near.RadiusKm <= domain.MaxRadiusKm, "radius_km",
+			fmt.Sprintf("must be greater than 0 and at most %g", domain.MaxRadiusKm))
+	}
+	if criteria.Within != nil {
+		validateGeoPoint(validator, "min_lat", "min_lng", domain.GeoPoint{Latitude: criteria.Within.MinLatitude, Longitude: criteria.Within.MinLongitude})
+		validateGeoPoint(validator, "max_lat", "max_lng", domain.GeoPoint{Latitude: criteria.Within.MaxLatitude, Longitude: criteria.Within.MaxLongitude})
+		validator.Check(criteria.Within.MinLatitude <= criteria.Within.MaxLatitude, "min_lat", "must not be greater than max_lat")
 	}
-	return nil
+	return validator.Err()
 }
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/services/validation"
+	"regexp"
+	"strings"
+)
+
+// Limits for property fields, text limits match the VARCHAR(255) columns of the properties table
+const (
+	maxTextLength        = 255
+	maxDescriptionLength = 10000
+	maxPrice             = 1000000000
+	maxRooms             = 100
+	maxSquareFeet        = 1000000
+	maxPostalCodeLength  = 16
+	maxNoteLength        = 1000
+)
+
+var postalCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]*$`)
+
+// validateProperty checks every field of the property and returns all violations together
+func validateProperty(property model.PropertyCreate) error {
+	validator := validation.NewValidator()
+
+	validator.Required("title", property.Title)
+	validator.MaxLength("title", property.Title, maxTextLength)
+	validator.Required("location", property.Location)
+	validator.MaxLength("location", property.Location, maxTextLength)
+	validator.MaxLength("description", property.Description, maxDescriptionLength)
+	validator.MaxLength("city", property.City, maxTextLength)
+	validator.MaxLength("district", property.District, maxTextLength)
+	validator.MaxLength("neighborhood", property.Neighborhood, maxTextLength)
+	if property.PostalCode != "" {
+		validator.MaxLength("postal_code", property.PostalCode, maxPostalCodeLength)
+		validator.Check(postalCodePattern.MatchString(property.PostalCode), "postal_code", "must contain only letters, digits, spaces and -")
+	}
+	validator.Check(property.AgentID == nil || *property.AgentID > 0, "agent_id", "must be a positive id")
+
+	validator.Between("price", property.Price, 1, maxPrice)
+	validator.Between("bedrooms", property.Bedrooms, 0, maxRooms)
+	validator.Between("bathrooms", property.Bathrooms, 0, maxRooms)
+	validator.Between("square_feet", property.SquareFeet, 0, maxSquareFeet)
+
+	validator.Check((property.Latitude == nil) == (property.Longitude == nil), "latitude", "must be given together with longitude")
+	if property.Latitude != nil && property.Longitude != nil {
+		validateGeoPoint(validator, "latitude", "longitude", domain.GeoPoint{Latitude: *property.Latitude, Longitude: *property.Longitude})
+	}
+
+	return validator.Err()
+}
+
+// validateGeoPoint checks that point is a position on earth, reporting errors under the given field names
+func validateGeoPoint(validator *validation.Validator, latitudeField string, longitudeField string, point domain.GeoPoint) {
+	validator.BetweenFloat(latitudeField, point.Latitude, -90, 90)
+	validator.BetweenFloat(longitudeField, point.Longitude, -180, 180)
+}
+
+// validateNewStatus checks the status a property is created with, a property starts as a draft or goes live at once
+func validateNewStatus(status domain.PropertyStatus) error {
+	validator := validation.NewValidator()
+	validator.Check(status == domain.StatusDraft || status == domain.StatusActive, "status", "must be draft or active")
+	return validator.Err()
+}
+
+// validateTransition checks the target status and note of a transition
+func validateTransition(transition model.StatusTransition) error {
+	validator := validation.NewValidator()
+	validator.Required("status", string(transition.Status))
+	validator.Check(transition.Status == "" || domain.IsPropertyStatus(transition.Status), "status", fmt.Sprintf("must be one of %s", joinStatuses(domain.PropertyStatuses)))
+	validator.MaxLength("note", transition.Note, maxNoteLength)
+	return validator.Err()
+}
+
+func joinStatuses(statuses []domain.PropertyStatus) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ", ")
+}
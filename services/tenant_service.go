@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/services/model"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ITenantService is an interface for the agencies sharing the deployment
+type ITenantService interface {
+	ResolveTenant(ctx context.Context, host string) (domain.Tenant, error)
+	CreateTenant(ctx context.Context, tenant model.TenantCreate) (domain.Tenant, error)
+	GetTenants(ctx context.Context) ([]domain.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (domain.Tenant, error)
+}
+
+// TenantService is a struct for the tenant service
+type TenantService struct {
+	repository    persistence.ITenantRepository
+	defaultTenant string
+	cacheTTL      time.Duration
+
+	// the tenants are read at most once every cacheTTL, so finding the tenant of a host costs no query on most requests
+	mutex    sync.Mutex
+	tenants  []domain.Tenant
+	loadedAt time.Time
+}
+
+// NewTenantService creates a new instance of TenantService, requests on hosts of no tenant are served by the tenant
+// with the slug defaultTenant, or refused when it is empty. The tenants are cached for cacheTTL, so a tenant added
+// by another server is served once the cache expires.
+func NewTenantService(repository persistence.ITenantRepository, defaultTenant string, cacheTTL time.Duration) *TenantService {
+	return &TenantService{
+		repository:    repository,
+		defaultTenant: defaultTenant,
+		cacheTTL:      cacheTTL,
+	}
+}
+
+// ResolveTenant finds the tenant of a request made on host. Signed in requests belong to the tenant of their user
+// and are forbidden on the hosts of other tenants, anonymous requests belong to the tenant serving host.
+func (service *TenantService) ResolveTenant(ctx context.Context, host string) (domain.Tenant, error) {
+	hostTenant, found, err := service.tenantOfHost(ctx, host)
+	if err != nil {
+		return domain.Tenant{}, err
+	}
+	if principal, ok := requestctx.Principal(ctx); ok {
+		if principal.TenantID == 0 {
+			return domain.Tenant{}, fmt.Errorf("%w: the credentials belong to no tenant, sign in again", domain.ErrUnauthorized)
+		}
+		if !found {
+			return service.tenantById(ctx, principal.TenantID)
+		}
+		if hostTenant.ID != principal.TenantID {
+			return domain.Tenant{}, fmt.Errorf("%w: the account belongs to another agency than %s", domain.ErrForbidden, hostTenant.Name)
+		}
+	}
+	if found {
+		return hostTenant, nil
+	}
+	if service.defaultTenant == "" {
+		return domain.Tenant{}, fmt.Errorf("no agency is served on %q: %w", host, domain.ErrNotFound)
+	}
+	tenants, err := service.cachedTenants(ctx)
+	if err != nil {
+		return domain.Tenant{}, err
+	}
+	for _, tenant := range tenants {
+		if tenant.Slug == service.defaultTenant {
+			return tenant, nil
+		}
+	}
+	return domain.Tenant{}, fmt.Errorf("tenant %q: %w", service.defaultTenant, domain.ErrNotFound)
+}
+
+// CreateTenant adds an agency, the currency defaults to domain.DefaultCurrency
+func (service *TenantService) CreateTenant(ctx context.Context, tenant model.TenantCreate) (domain.Tenant, error) {
+	tenant = normalizeTenant(tenant)
+	if err := validateTenant(tenant); err != nil {
+		return domain.Tenant{}, err
+	}
+	created, err := service.repository.AddTenant(ctx, domain.Tenant{
+		Slug:        tenant.Slug,
+		Name:        tenant.Name,
+		Currency:    tenant.Currency,
+		Hosts:       tenant.Hosts,
+		CorsOrigins: tenant.CorsOrigins,
+	})
+	if err != nil {
+		return domain.Tenant{}, err
+	}
+	service.mutex.Lock()
+	service.loadedAt = time.Time{}
+	service.mutex.Unlock()
+	return created, nil
+}
+
+// GetTenants retrieves every tenant
+func (service *TenantService) GetTenants(ctx context.Context) ([]domain.Tenant, error) {
+	return service.repository.GetTenants(ctx)
+}
+
+// GetTenantBySlug retrieves a tenant by slug
+func (service *TenantService) GetTenantBySlug(ctx context.Context, slug string) (domain.Tenant, error) {
+	return service.repository.GetTenantBySlug(ctx, slug)
+}
+
+// tenantOfHost finds the tenant serving host, found is false when no tenant serves it.
+// It only reads the cached tenants, so any host a client sends costs no more than one query per cacheTTL.
+func (service *TenantService) tenantOfHost(ctx context.Context, host string) (tenant domain.Tenant, found bool, err error) {
+	host = normalizeHost(host)
+	if host == "" {
+		return domain.Tenant{}, false, nil
+	}
+	tenants, err := service.cachedTenants(ctx)
+	if err != nil {
+		return domain.Tenant{}, false, err
+	}
+	for _, tenant := range tenants {
+		for _, tenantHost := range tenant.Hosts {
+			if tenantHost == host {
+				return tenant, true, nil
+			}
+		}
+	}
+	return domain.Tenant{}, false, nil
+}
+
+// tenantById finds the tenant of signed in credentials, a tenant missing from the cache is read from the repository
+// as it may have been added by another server since the cache was loaded
+func (service *TenantService) tenantById(ctx context.Context, id int64) (domain.Tenant, error) {
+	tenants, err := service.cachedTenants(ctx)
+	if err != nil {
+		return domain.Tenant{}, err
+	}
+	for _, tenant := range tenants {
+		if tenant.ID == id {
+			return tenant, nil
+		}
+	}
+	return service.repository.GetTenantById(ctx, id)
+}
+
+// cachedTenants returns every tenant, reading them again once the cache is older than cacheTTL
+func (service *TenantService) cachedTenants(ctx context.Context) ([]domain.Tenant, error) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if !service.loadedAt.IsZero() && time.Since(service.loadedAt) < service.cacheTTL {
+		return service.tenants, nil
+	}
+	tenants, err := service.repository.GetTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	service.tenants, service.loadedAt = tenants, time.Now()
+	return tenants, nil
+}
+
+// normalizeHost lowercases host and drops its port
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+func normalizeTenant(tenant model.TenantCreate) model.TenantCreate {
+	tenant.Slug = strings.TrimSpace(tenant.Slug)
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	tenant.Currency = strings.ToUpper(strings.TrimSpace(tenant.Currency))
+	if tenant.Currency == "" {
+		tenant.Currency = domain.DefaultCurrency
+	}
+	hosts := make([]string, len(tenant.Hosts))
+	for i, host := range tenant.Hosts {
+		hosts[i] = normalizeHost(host)
+	}
+	tenant.Hosts = hosts
+	return tenant
+}
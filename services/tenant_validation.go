@@ -0,0 +1,44 @@
+package services
+
+import (
+	"fmt"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/services/validation"
+	"net/url"
+	"regexp"
+)
+
+// maxSlugLength keeps slugs usable as a DNS label
+const maxSlugLength = 63
+
+var (
+	slugPattern     = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	hostPattern     = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+)
+
+// validateTenant checks every field of a new tenant and returns all violations together
+func validateTenant(tenant model.TenantCreate) error {
+	validator := validation.NewValidator()
+
+	validator.Required("slug", tenant.Slug)
+	validator.MaxLength("slug", tenant.Slug, maxSlugLength)
+	if tenant.Slug != "" {
+		validator.Check(slugPattern.MatchString(tenant.Slug), "slug", "must contain only lowercase letters, digits and single dashes")
+	}
+	validator.Required("name", tenant.Name)
+	validator.MaxLength("name", tenant.Name, maxTextLength)
+	validator.Check(currencyPattern.MatchString(tenant.Currency), "currency", "must be a three letter ISO 4217 code such as TRY")
+	for i, host := range tenant.Hosts {
+		field := fmt.Sprintf("hosts[%d]", i)
+		validator.MaxLength(field, host, maxTextLength)
+		validator.Check(hostPattern.MatchString(host), field, "must be a host name such as kirmac.com.tr")
+	}
+	for i, origin := range tenant.CorsOrigins {
+		parsed, err := url.Parse(origin)
+		validator.Check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" && parsed.Path == "",
+			fmt.Sprintf("cors_origins[%d]", i), "must be a scheme://host origin")
+	}
+
+	return validator.Err()
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v3/log"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/common/storage"
+	"kirmac-site-backend/persistence"
+	"time"
+)
+
+// TrashPurger permanently removes the properties that have been in the trash for longer than the retention period
+// together with the files of their uploaded images
+type TrashPurger struct {
+	repository persistence.IPropertyRepository
+	tenants    persistence.ITenantRepository
+	storage    storage.Storage
+	retention  time.Duration
+	interval   time.Duration
+}
+
+// NewTrashPurger creates a purger keeping deleted properties for retention and checking the trash every interval
+func NewTrashPurger(repository persistence.IPropertyRepository, tenants persistence.ITenantRepository, storage storage.Storage, retention time.Duration, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		repository: repository,
+		tenants:    tenants,
+		storage:    storage,
+		retention:  retention,
+		interval:   interval,
+	}
+}
+
+// Purge removes the properties deleted more than the retention period before now from the trash of every tenant
+// and returns how many were removed, a tenant that fails does not stop the others
+func (purger *TrashPurger) Purge(ctx context.Context, now time.Time) (int64, error) {
+	tenants, err := purger.tenants.GetTenants(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	var errs []error
+	for _, tenant := range tenants {
+		count, images, err := purger.repository.PurgeDeletedProperties(requestctx.WithTenant(ctx, tenant), now.Add(-purger.retention))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.Slug, err))
+			continue
+		}
+		// only uploads have variants, an image added by URL may point at files that belong to another image
+		for _, image := range images {
+			if len(image.Variants) > 0 {
+				deleteStoredImage(ctx, purger.storage, image)
+			}
+		}
+		purged += count
+	}
+	return purged, errors.Join(errs...)
+}
+
+// Run purges the trash at once and then every interval until ctx is done, a failed purge is retried on the next tick
+func (purger *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(purger.interval)
+	defer ticker.Stop()
+	for {
+		purged, err := purger.Purge(ctx, time.Now())
+		if err != nil {
+			log.Errorf("Unable to purge deleted properties: %v", err)
+		} else if purged > 0 {
+			log.Infof("Purged %d deleted properties", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
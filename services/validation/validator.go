@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"fmt"
+	"kirmac-site-backend/domain"
+	"net/mail"
+	"net/url"
+	"unicode/utf8"
+)
+
+// Validator collects every field error of an input so they can be reported together
+type Validator struct {
+	fields []domain.FieldError
+}
+
+// NewValidator creates a validator without any errors
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// Check records message for field when ok is false
+func (validator *Validator) Check(ok bool, field string, message string) {
+	if !ok {
+		validator.fields = append(validator.fields, domain.FieldError{Field: field, Message: message})
+	}
+}
+
+// Required checks that value is not empty
+func (validator *Validator) Required(field string, value string) {
+	validator.Check(value != "", field, "is required")
+}
+
+// MaxLength checks that value has at most max characters
+func (validator *Validator) MaxLength(field string, value string, max int) {
+	validator.Check(utf8.RuneCountInString(value) <= max, field, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// Between checks that value is within min and max, inclusive
+func (validator *Validator) Between(field string, value int, min int, max int) {
+	validator.Check(value >= min && value <= max, field, fmt.Sprintf("must be between %d and %d", min, max))
+}
+
+// BetweenFloat checks that value is within min and max, inclusive
+func (validator *Validator) BetweenFloat(field string, value float64, min float64, max float64) {
+	validator.Check(value >= min && value <= max, field, fmt.Sprintf("must be between %g and %g", min, max))
+}
+
+// URL checks that value is an absolute URL using one of the allowed schemes
+func (validator *Validator) URL(field string, value string, schemes ...string) {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		validator.Check(false, field, "must be an absolute URL")
+		return
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return
+		}
+	}
+	validator.Check(false, field, fmt.Sprintf("must use one of the schemes %v", schemes))
+}
+
+// Email checks that value is a bare e-mail address such as agent@example.com
+func (validator *Validator) Email(field string, value string) {
+	address, err := mail.ParseAddress(value)
+	validator.Check(err == nil && address.Address == value, field, "must be an e-mail address")
+}
+
+// Valid reports whether no error has been recorded
+func (validator *Validator) Valid() bool {
+	return len(validator.fields) == 0
+}
+
+// Err returns a *domain.ValidationError with every recorded error, or nil when the input is valid
+func (validator *Validator) Err() error {
+	if validator.Valid() {
+		return nil
+	}
+	return domain.NewValidationError(validator.fields...)
+}
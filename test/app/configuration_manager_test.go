@@ -0,0 +1,134 @@
+package app
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/app"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func environment(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+// TestLoadDefaults tests that the defaults are used when there is no file and no environment variable
+func TestLoadDefaults(t *testing.T) {
+	configurationManager, err := app.LoadConfigurationManager("", environment(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", configurationManager.PostgreSqlConfig.Host)
+	assert.Equal(t, ":8080", configurationManager.Server.ListenAddress)
+	assert.Equal(t, []string{"*"}, configurationManager.Cors.AllowOrigins)
+	assert.Empty(t, configurationManager.Cors.AdminPolicy().AllowOrigins)
+}
+
+// TestLoadFileWithEnvironmentOverrides tests that environment variables take precedence over the file
+func TestLoadFileWithEnvironmentOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+postgresql:
+  host: db.internal
+  db_name: listings
+server:
+  listen_address: ":9090"
+  read_timeout: 5s
+cors:
+  allow_origins: ["https://kirmac.com"]
+log_level: debug
+`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	configurationManager, err := app.LoadConfigurationManager(path, environment(map[string]string{
+		"KIRMAC_DB_HOST":            "db.prod",
+		"KIRMAC_CORS_ALLOW_ORIGINS": "https://kirmac.com, https://admin.kirmac.com",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "db.prod", configurationManager.PostgreSqlConfig.Host)
+	assert.Equal(t, "listings", configurationManager.PostgreSqlConfig.DbName)
+	assert.Equal(t, ":9090", configurationManager.Server.ListenAddress)
+	assert.Equal(t, app.Duration(5*time.Second), configurationManager.Server.ReadTimeout)
+	assert.Equal(t, []string{"https://kirmac.com", "https://admin.kirmac.com"}, configurationManager.Cors.AllowOrigins)
+	assert.Equal(t, "debug", configurationManager.LogLevel)
+}
+
+// TestLoadReportsEveryInvalidField tests that validation reports all problems at once
+func TestLoadReportsEveryInvalidField(t *testing.T) {
+	_, err := app.LoadConfigurationManager("", environment(map[string]string{
+		"KIRMAC_DB_PORT":              "abc",
+		"KIRMAC_HTTP_LISTEN_ADDRESS":  "8080",
+		"KIRMAC_HTTP_READ_TIMEOUT":    "soon",
+		"KIRMAC_LOG_LEVEL":            "verbose",
+		"KIRMAC_CORS_ALLOW_ORIGINS":   "kirmac.com",
+		"KIRMAC_TRASH_PURGE_INTERVAL": "0s",
+		"KIRMAC_JWT_SECRET":           "too-short",
+	}))
+	assert.Error(t, err)
+	for _, field := range []string{"postgresql.port", "server.listen_address", "KIRMAC_HTTP_READ_TIMEOUT", "log_level", "cors.allow_origins", "trash.purge_interval", "auth.jwt_secret"} {
+		assert.Contains(t, err.Error(), field)
+	}
+}
+
+// TestLoadCorsPolicies tests that the admin CORS policy inherits the public one and replaces what it sets
+func TestLoadCorsPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+cors:
+  allow_origins: ["*"]
+  max_age: 1h
+  admin:
+    allow_origins: ["https://admin.kirmac.com"]
+    allow_credentials: true
+`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	configurationManager, err := app.LoadConfigurationManager(path, environment(map[string]string{
+		"KIRMAC_CORS_ADMIN_MAX_AGE": "5m",
+	}))
+	assert.NoError(t, err)
+	public := configurationManager.Cors.CorsPolicy
+	assert.Equal(t, []string{"GET", "HEAD"}, public.AllowMethods)
+	assert.Equal(t, app.Duration(time.Hour), public.MaxAge)
+	assert.False(t, public.AllowCredentials)
+	admin := configurationManager.Cors.AdminPolicy()
+	assert.Equal(t, []string{"https://admin.kirmac.com"}, admin.AllowOrigins)
+	assert.Contains(t, admin.AllowMethods, "DELETE")
+	assert.Contains(t, admin.AllowHeaders, "Authorization")
+	assert.True(t, admin.AllowCredentials)
+	assert.Equal(t, app.Duration(5*time.Minute), admin.MaxAge)
+
+	_, err = app.LoadConfigurationManager("", environment(map[string]string{
+		"KIRMAC_CORS_ADMIN_ALLOW_ORIGINS": "*",
+		"KIRMAC_CORS_ALLOW_METHODS":       "GET,TRACE",
+	}))
+	assert.ErrorContains(t, err, "cors.admin.allow_origins")
+	assert.ErrorContains(t, err, "cors.allow_methods")
+}
+
+// TestLoadRateLimits tests that the rate limits are read from the environment and the burst defaults to the requests
+func TestLoadRateLimits(t *testing.T) {
+	configurationManager, err := app.LoadConfigurationManager("", environment(map[string]string{
+		"KIRMAC_RATE_LIMIT_IP_HEADER":      "X-Forwarded-For",
+		"KIRMAC_RATE_LIMIT_LOGIN_REQUESTS": "10",
+		"KIRMAC_RATE_LIMIT_LOGIN_PERIOD":   "1h",
+		"KIRMAC_RATE_LIMIT_LOGIN_BURST":    "0",
+	}))
+	assert.NoError(t, err)
+	assert.True(t, configurationManager.RateLimit.Enabled)
+	assert.Equal(t, "X-Forwarded-For", configurationManager.RateLimit.IPHeader)
+	limit := configurationManager.RateLimit.Login.Limit()
+	assert.Equal(t, 10, limit.Burst)
+	assert.Equal(t, time.Hour, limit.Period)
+
+	_, err = app.LoadConfigurationManager("", environment(map[string]string{
+		"KIRMAC_RATE_LIMIT_WRITE_PERIOD":   "0s",
+		"KIRMAC_RATE_LIMIT_PUBLIC_BURST":   "-1",
+		"KIRMAC_RATE_LIMIT_LOGIN_REQUESTS": "many",
+	}))
+	assert.ErrorContains(t, err, "rate_limit.write.period")
+	assert.ErrorContains(t, err, "rate_limit.public.burst")
+	assert.ErrorContains(t, err, "KIRMAC_RATE_LIMIT_LOGIN_REQUESTS")
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/controller"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/test/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newAgentTestApp() *fiber.App {
+	agentID := int64(1)
+	agentController := controller.NewAgentController(services.NewAgentService(
+		service.NewFakeAgentRepository([]domain.Agent{{ID: 1, Name: "Ayse Kaya", Title: "Luxury Property Specialist"}}),
+		service.NewFakePropertyRepository([]domain.Property{
+			{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse in Antalya", AgentID: &agentID},
+			{ID: 2, Location: "Ankara, Turkey", Price: 800000, Title: "Modern City Apartment"},
+		}),
+	))
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	fiberApp.Use(controller.Authentication(services.NewAuthService(service.NewFakeUserRepository(), service.NewFakeAPIKeyRepository(), testIssuer)))
+	agentController.RegisterRoutes(fiberApp)
+	return fiberApp
+}
+
+// TestAgentRoutes tests the agent endpoints
+func TestAgentRoutes(t *testing.T) {
+	fiberApp := newAgentTestApp()
+
+	t.Run("Create", func(t *testing.T) {
+		req := signedIn(httptest.NewRequest(http.MethodPost, "/agents", strings.NewReader(`{"name":"Selin Aydin","languages":["Turkish","German"]}`)))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "/agents/2", res.Header.Get("Location"))
+	})
+	t.Run("Portfolio", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/agents/1/properties", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		var list response.PropertyListResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&list))
+		assert.Equal(t, int64(1), list.TotalCount)
+		assert.Equal(t, int64(1), list.Data[0].ID)
+	})
+	t.Run("UnknownAgent", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/agents/42/properties", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
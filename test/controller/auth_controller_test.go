@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/controller"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/test/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuthTestApp(t *testing.T) *fiber.App {
+	authService := services.NewAuthService(service.NewFakeUserRepository(), service.NewFakeAPIKeyRepository(), testIssuer)
+	tenantCtx := requestctx.WithTenant(context.Background(), testTenant)
+	_, err := authService.CreateUser(tenantCtx, model.UserCreate{Username: "ayse.kaya", Password: "correct horse battery", Role: domain.RoleAgent})
+	assert.NoError(t, err)
+	_, err = authService.CreateUser(tenantCtx, model.UserCreate{Username: "mehmet.demir", Password: "correct horse battery"})
+	assert.NoError(t, err)
+
+	propertyService := services.NewPropertyService(service.NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse in Antalya", Version: 1},
+	}), service.NewFakeAuditRepository(), service.FakeTransactionManager{})
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	fiberApp.Use(controller.Authentication(authService))
+	fiberApp.Use(controller.Tenancy(services.NewTenantService(service.NewFakeTenantRepository([]domain.Tenant{testTenant}), testTenant.Slug, time.Minute), "Host"))
+	controller.NewAuthController(authService).RegisterRoutes(fiberApp)
+	controller.NewPropertyController(propertyService).RegisterRoutes(fiberApp)
+	return fiberApp
+}
+
+func jsonRequest(method string, target string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// TestAuthentication tests that writes need a token or an API key while reads stay public
+func TestAuthentication(t *testing.T) {
+	fiberApp := newAuthTestApp(t)
+	newProperty := `{"title":"Cave House","location":"Cappadocia, Turkey","price":950000}`
+
+	t.Run("ReadsArePublic", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/1", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+	t.Run("WritesNeedCredentials", func(t *testing.T) {
+		res, err := fiberApp.Test(jsonRequest(http.MethodPost, "/properties", newProperty))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, `Bearer realm="kirmac"`, res.Header.Get("WWW-Authenticate"))
+
+		req := httptest.NewRequest(http.MethodGet, "/properties/1", nil)
+		req.Header.Set("Authorization", "Bearer not.a.token")
+		res, err = fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	var tokens domain.TokenPair
+	t.Run("Login", func(t *testing.T) {
+		res, err := fiberApp.Test(jsonRequest(http.MethodPost, "/auth/login", `{"username":"ayse.kaya","password":"wrong password"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res, err = fiberApp.Test(jsonRequest(http.MethodPost, "/auth/login", `{"username":"ayse.kaya","password":"correct horse battery"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&tokens))
+
+		req := jsonRequest(http.MethodPost, "/properties", newProperty)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		res, err = fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+	})
+	t.Run("Refresh", func(t *testing.T) {
+		res, err := fiberApp.Test(jsonRequest(http.MethodPost, "/auth/refresh", fmt.Sprintf(`{"refresh_token":%q}`, tokens.RefreshToken)))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		req := jsonRequest(http.MethodPost, "/properties", newProperty)
+		req.Header.Set("Authorization", "Bearer "+tokens.RefreshToken)
+		res, err = fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+	t.Run("Roles", func(t *testing.T) {
+		req := jsonRequest(http.MethodPatch, "/properties/1", `{"price":1750000}`)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Equal(t, "forbidden: agent users can only change listings they own", readProblem(t, res).Detail)
+
+		res, err = fiberApp.Test(jsonRequest(http.MethodPost, "/auth/login", `{"username":"mehmet.demir","password":"correct horse battery"}`))
+		assert.NoError(t, err)
+		var viewerTokens domain.TokenPair
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&viewerTokens))
+		req = jsonRequest(http.MethodPost, "/properties", newProperty)
+		req.Header.Set("Authorization", "Bearer "+viewerTokens.AccessToken)
+		res, err = fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+	t.Run("APIKeys", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/auth/api-keys", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res, err = fiberApp.Test(signedIn(jsonRequest(http.MethodPost, "/auth/api-keys", `{"name":"crm-sync"}`)))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		var created domain.NewAPIKey
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+
+		req := jsonRequest(http.MethodPost, "/properties", newProperty)
+		req.Header.Set(controller.APIKeyHeader, created.Key)
+		res, err = fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, "/auth/api-keys", nil)))
+		assert.NoError(t, err)
+		var keys response.APIKeyListResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&keys))
+		assert.Equal(t, "crm-sync", keys.Data[0].Name)
+
+		res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/auth/api-keys/%d", created.ID), nil)))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		req = jsonRequest(http.MethodPost, "/properties", newProperty)
+		req.Header.Set(controller.APIKeyHeader, created.Key)
+		res, err = fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
@@ -0,0 +1,509 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/app"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/common/token"
+	"kirmac-site-backend/controller"
+	"kirmac-site-backend/controller/response"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/test/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestApp() *fiber.App {
+	fakePropertyRepository := service.NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse in Antalya", Version: 1},
+	})
+	propertyController := controller.NewPropertyController(services.NewPropertyService(fakePropertyRepository, service.NewFakeAuditRepository(), service.FakeTransactionManager{}))
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	controller.UseMiddleware(fiberApp, testMiddleware(testTenant))
+	propertyController.RegisterRoutes(fiberApp)
+	return fiberApp
+}
+
+// testTenant is the agency every request of the tests is served for
+var testTenant = domain.Tenant{ID: 1, Slug: "default", Name: "Default", Currency: domain.DefaultCurrency}
+
+// testMiddleware is the middleware of the tests with the default configuration, serving tenants
+func testMiddleware(tenants ...domain.Tenant) controller.MiddlewareConfig {
+	configurationManager, _ := app.LoadConfigurationManager("", func(string) (string, bool) { return "", false })
+	return controller.MiddlewareConfig{
+		AuthService:   services.NewAuthService(service.NewFakeUserRepository(), service.NewFakeAPIKeyRepository(), testIssuer),
+		TenantService: services.NewTenantService(service.NewFakeTenantRepository(tenants), tenants[0].Slug, time.Minute),
+		Tenancy:       configurationManager.Tenancy,
+		Cors:          configurationManager.Cors,
+		RateLimit:     configurationManager.RateLimit,
+	}
+}
+
+var testIssuer = token.NewIssuer([]byte("0123456789abcdef0123456789abcdef"), "kirmac-test", time.Minute, time.Hour)
+
+// signedIn authenticates req as the admin ayse.kaya
+func signedIn(req *http.Request) *http.Request {
+	accessToken, _, _ := testIssuer.Issue(token.Access, token.Identity{Subject: "1", Name: "ayse.kaya", Role: string(domain.RoleAdmin), Tenant: 1}, time.Now())
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return req
+}
+
+func readProblem(t *testing.T, res *http.Response) response.Problem {
+	var problem response.Problem
+	assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+	return problem
+}
+
+// TestErrorHandler tests that domain errors are mapped to problem details responses
+func TestErrorHandler(t *testing.T) {
+	fiberApp := newTestApp()
+
+	t.Run("NotFound", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/42", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		problem := readProblem(t, res)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+		assert.Equal(t, "Not Found", problem.Title)
+		assert.Equal(t, "/properties/42", problem.Instance)
+	})
+	t.Run("InvalidId", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/abc", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		problem := readProblem(t, res)
+		assert.Equal(t, []domain.FieldError{{Field: "id", Message: "Invalid ID"}}, problem.Errors)
+	})
+	t.Run("PageOutOfRange", func(t *testing.T) {
+		for _, page := range []string{"2147483648", "9223372036854775807"} {
+			res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties?page="+page+"&page_size=100", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+			assert.Equal(t, "page", readProblem(t, res).Errors[0].Field)
+		}
+	})
+	t.Run("ValidationFailure", func(t *testing.T) {
+		req := signedIn(httptest.NewRequest(http.MethodPost, "/properties", strings.NewReader(`{"title":"Cave House","location":"Cappadocia, Turkey","price":0}`)))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		problem := readProblem(t, res)
+		assert.Equal(t, "price", problem.Errors[0].Field)
+	})
+}
+
+type slowPropertyRepository struct {
+	persistence.IPropertyRepository
+}
+
+func (slowPropertyRepository) GetPropertyById(ctx context.Context, id int64) (domain.Property, error) {
+	return domain.Property{}, context.DeadlineExceeded
+}
+
+// TestSearchEnvelope tests that search results come in the envelope of the property list
+func TestSearchEnvelope(t *testing.T) {
+	fiberApp := newTestApp()
+
+	res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/search?q=antalya&page_size=5", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var body response.PropertySearchResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "antalya", body.Query)
+	assert.Equal(t, int64(1), body.TotalCount)
+	assert.Equal(t, 1, len(body.Data))
+	assert.Equal(t, 1, body.Page)
+	assert.Equal(t, 5, body.PageSize)
+	assert.Equal(t, "/properties/search?page=1&page_size=5&q=antalya", body.Links.Self)
+	assert.Empty(t, body.Links.Next)
+}
+
+// TestQueryTimeout tests that a query running out of time is answered with 504
+func TestQueryTimeout(t *testing.T) {
+	propertyController := controller.NewPropertyController(services.NewPropertyService(slowPropertyRepository{}, service.NewFakeAuditRepository(), service.FakeTransactionManager{}))
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	propertyController.RegisterRoutes(fiberApp)
+
+	res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/1", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	assert.Equal(t, http.StatusGatewayTimeout, readProblem(t, res).Status)
+}
+
+// TestETags tests the ETag and If-Match handling of the property endpoints
+func TestETags(t *testing.T) {
+	fiberApp := newTestApp()
+
+	res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/1", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+
+	req := httptest.NewRequest(http.MethodGet, "/properties/1", nil)
+	req.Header.Set("If-None-Match", `"1"`)
+	res, err = fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	body := `{"title":"Seaside Penthouse in Antalya","location":"Antalya, Turkey","price":1900000}`
+	req = signedIn(httptest.NewRequest(http.MethodPut, "/properties/1", strings.NewReader(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	res, err = fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+	req = signedIn(httptest.NewRequest(http.MethodDelete, "/properties/1", nil))
+	req.Header.Set("If-Match", `"1"`)
+	res, err = fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+}
+
+// TestGeoRoutes tests that the radius and bounding box routes read their coordinates from the query string
+func TestGeoRoutes(t *testing.T) {
+	fiberApp := newTestApp()
+
+	t.Run("MissingRadius", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/nearby?lat=36.88&lng=abc", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		problem := readProblem(t, res)
+		assert.Equal(t, []domain.FieldError{{Field: "lng", Message: `"abc" is not a number`}, {Field: "radius_km", Message: "is required"}}, problem.Errors)
+	})
+	t.Run("Nearby", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/nearby?lat=36.88&lng=30.70&radius_km=5", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		var body response.NearbyPropertiesResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		assert.Equal(t, []domain.PropertyDistance{}, body.Data)
+	})
+	t.Run("Within", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/within?min_lat=36&min_lng=30&max_lat=35&max_lng=31", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		problem := readProblem(t, res)
+		assert.Equal(t, "min_lat", problem.Errors[0].Field)
+	})
+	t.Run("GeoJSON", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties.geojson?min_price=1000000", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/geo+json", res.Header.Get("Content-Type"))
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		assert.Equal(t, map[string]interface{}{"type": "FeatureCollection", "features": []interface{}{}}, body)
+	})
+	t.Run("GeoJSONClusterWithoutZoom", func(t *testing.T) {
+		res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties.geojson?cluster=true", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		problem := readProblem(t, res)
+		assert.Equal(t, []domain.FieldError{{Field: "zoom", Message: "is required"}}, problem.Errors)
+	})
+}
+
+func TestStatusTransitions(t *testing.T) {
+	fiberApp := newTestApp()
+
+	t.Run("Transition", func(t *testing.T) {
+		req := signedIn(httptest.NewRequest(http.MethodPost, "/properties/1/transitions", strings.NewReader(`{"status": "under_offer", "note": "Offer accepted"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"1"`)
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+		res, err = fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties/1/transitions", nil))
+		assert.NoError(t, err)
+		var history domain.StatusHistory
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&history))
+		assert.Equal(t, domain.StatusUnderOffer, history.Status)
+		assert.Equal(t, "ayse.kaya", history.Changes[0].Actor)
+	})
+	t.Run("InvalidTransition", func(t *testing.T) {
+		req := signedIn(httptest.NewRequest(http.MethodPost, "/properties/1/transitions", strings.NewReader(`{"status": "draft"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+	t.Run("ListByStatus", func(t *testing.T) {
+		res, err := fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, "/properties?status=draft,sold", nil)))
+		assert.NoError(t, err)
+		var body response.PropertyListResponse
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		assert.Equal(t, int64(0), body.TotalCount)
+
+		res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, "/properties?status=all", nil)))
+		assert.NoError(t, err)
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		assert.Equal(t, int64(1), body.TotalCount)
+	})
+	t.Run("UnlistedHiddenFromAnonymous", func(t *testing.T) {
+		for _, status := range []string{"draft", "all"} {
+			res, err := fiberApp.Test(httptest.NewRequest(http.MethodGet, "/properties?status="+status, nil))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		}
+
+		req := signedIn(httptest.NewRequest(http.MethodPost, "/properties/1/transitions", strings.NewReader(`{"status": "archived"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		for _, path := range []string{"/properties/1", "/properties/1/transitions"} {
+			res, err = fiberApp.Test(httptest.NewRequest(http.MethodGet, path, nil))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode, path)
+			res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, path, nil)))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode, path)
+		}
+	})
+}
+
+func TestTrashRoutes(t *testing.T) {
+	fiberApp := newTestApp()
+
+	res, err := fiberApp.Test(signedIn(httptest.NewRequest(http.MethodDelete, "/properties/1", nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, "/properties/trash", nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var body response.PropertyListResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, int64(1), body.TotalCount)
+
+	res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodPost, "/properties/1/restore", nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"3"`, res.Header.Get("ETag"))
+
+	res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodPost, "/properties/1/restore", nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestPropertyHistory(t *testing.T) {
+	fiberApp := newTestApp()
+
+	req := signedIn(httptest.NewRequest(http.MethodPatch, "/properties/1", strings.NewReader(`{"price": 1750000}`)))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("X-Request-ID", "req-42")
+	res, err := fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "req-42", res.Header.Get("X-Request-ID"))
+
+	res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, "/properties/1/history", nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, res.Header.Get("X-Request-ID"))
+	var body response.AuditListResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, int64(1), body.TotalCount)
+	assert.Equal(t, domain.AuditUpdate, body.Data[0].Action)
+	assert.Equal(t, "ayse.kaya", body.Data[0].Actor)
+	assert.Equal(t, "req-42", body.Data[0].RequestID)
+	assert.Equal(t, []domain.FieldChange{{Field: "price", Before: float64(1800000), After: float64(1750000)}}, body.Data[0].Changes)
+
+	res, err = fiberApp.Test(signedIn(httptest.NewRequest(http.MethodGet, "/properties/99/history", nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+// TestTenancy tests that requests are served for the agency of their host or credentials with its CORS origins
+func TestTenancy(t *testing.T) {
+	egeEmlak := domain.Tenant{ID: 2, Slug: "ege-emlak", Name: "Ege Emlak", Currency: "EUR", Hosts: []string{"ege-emlak.com"}, CorsOrigins: []string{"https://ege-emlak.com"}}
+	middleware := testMiddleware(testTenant, egeEmlak)
+	middleware.Cors.AllowOrigins = []string{"https://kirmac.com"}
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	controller.UseMiddleware(fiberApp, middleware)
+	controller.NewTenantController().RegisterRoutes(fiberApp)
+	controller.NewPropertyController(services.NewPropertyService(service.NewFakePropertyRepository(nil), service.NewFakeAuditRepository(), service.FakeTransactionManager{})).RegisterRoutes(fiberApp)
+
+	req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	req.Host = "ege-emlak.com"
+	res, err := fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var tenant domain.Tenant
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&tenant))
+	assert.Equal(t, "EUR", tenant.Currency)
+
+	req = httptest.NewRequest(http.MethodGet, "/properties", nil)
+	req.Host = "ege-emlak.com"
+	req.Header.Set("Origin", "https://ege-emlak.com")
+	res, err = fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ege-emlak.com", res.Header.Get("Access-Control-Allow-Origin"))
+	req = httptest.NewRequest(http.MethodGet, "/properties", nil)
+	req.Header.Set("Origin", "https://kirmac.com")
+	res, err = fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://kirmac.com", res.Header.Get("Access-Control-Allow-Origin"))
+
+	req = signedIn(httptest.NewRequest(http.MethodGet, "/tenant", nil))
+	req.Host = "ege-emlak.com"
+	res, err = fiberApp.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+// TestCORS tests that anonymous reads get the public CORS policy and writes and credentialed requests the admin one
+func TestCORS(t *testing.T) {
+	middleware := testMiddleware(testTenant)
+	middleware.Cors.AllowOrigins = []string{"*"}
+	middleware.Cors.Admin.AllowOrigins = []string{"https://admin.kirmac.com"}
+	allowCredentials := true
+	middleware.Cors.Admin.AllowCredentials = &allowCredentials
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	controller.UseMiddleware(fiberApp, middleware)
+	controller.NewPropertyController(services.NewPropertyService(service.NewFakePropertyRepository(nil), service.NewFakeAuditRepository(), service.FakeTransactionManager{})).RegisterRoutes(fiberApp)
+
+	preflight := func(method string, headers string) *http.Response {
+		req := httptest.NewRequest(http.MethodOptions, "/properties", nil)
+		req.Header.Set("Origin", "https://admin.kirmac.com")
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", headers)
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		return res
+	}
+
+	t.Run("PublicReads", func(t *testing.T) {
+		res := preflight(http.MethodGet, "If-None-Match")
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, "*", res.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET,HEAD", res.Header.Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "600", res.Header.Get("Access-Control-Max-Age"))
+		assert.Empty(t, res.Header.Get("Access-Control-Allow-Credentials"))
+	})
+	t.Run("AdminWrites", func(t *testing.T) {
+		res := preflight(http.MethodPatch, "Content-Type")
+		assert.Equal(t, "https://admin.kirmac.com", res.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", res.Header.Get("Access-Control-Allow-Credentials"))
+		assert.Contains(t, res.Header.Get("Access-Control-Allow-Methods"), "PATCH")
+		assert.Contains(t, res.Header.Get("Access-Control-Allow-Headers"), "If-Match")
+	})
+	t.Run("RefusedRequests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/properties", strings.NewReader(`{}`))
+		req.Header.Set("Origin", "https://admin.kirmac.com")
+		req.Header.Set("Authorization", "Bearer not.a.token")
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, "https://admin.kirmac.com", res.Header.Get("Access-Control-Allow-Origin"))
+	})
+	t.Run("AdminClosedByDefault", func(t *testing.T) {
+		fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+		controller.UseMiddleware(fiberApp, testMiddleware(testTenant))
+		controller.NewPropertyController(services.NewPropertyService(service.NewFakePropertyRepository(nil), service.NewFakeAuditRepository(), service.FakeTransactionManager{})).RegisterRoutes(fiberApp)
+		req := httptest.NewRequest(http.MethodOptions, "/properties", nil)
+		req.Header.Set("Origin", "https://admin.kirmac.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+	})
+	t.Run("CredentialedReads", func(t *testing.T) {
+		res := preflight(http.MethodGet, "authorization")
+		assert.Equal(t, "https://admin.kirmac.com", res.Header.Get("Access-Control-Allow-Origin"))
+
+		req := signedIn(httptest.NewRequest(http.MethodGet, "/properties", nil))
+		req.Header.Set("Origin", "https://kirmac.com")
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+	})
+}
+
+// TestRateLimit tests that clients over their limit are refused with 429 and told when to retry
+func TestRateLimit(t *testing.T) {
+	middleware := testMiddleware(testTenant)
+	middleware.RateLimit.Public = app.RateLimit{Requests: 1, Period: app.Duration(time.Minute), Burst: 2}
+	middleware.RateLimit.Login = app.RateLimit{Requests: 1, Period: app.Duration(time.Minute)}
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	controller.UseMiddleware(fiberApp, middleware)
+	controller.NewAuthController(middleware.AuthService).RegisterRoutes(fiberApp)
+	controller.NewPropertyController(services.NewPropertyService(service.NewFakePropertyRepository(nil), service.NewFakeAuditRepository(), service.FakeTransactionManager{})).RegisterRoutes(fiberApp)
+
+	get := func(apiKey string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/properties", nil)
+		if apiKey != "" {
+			req.Header.Set(controller.APIKeyHeader, apiKey)
+		}
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		return res
+	}
+
+	res := get("")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "2", res.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "1", res.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, "1;w=60;burst=2", res.Header.Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusOK, get("").StatusCode)
+	res = get("")
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "60", res.Header.Get("Retry-After"))
+	assert.Equal(t, "0", res.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, readProblem(t, res).Status)
+
+	// claiming an API key does not get around the bucket of the address
+	assert.Equal(t, http.StatusTooManyRequests, get("kk_unknown").StatusCode)
+
+	login := func() int {
+		res, err := fiberApp.Test(jsonRequest(http.MethodPost, "/auth/login", `{"username":"ayse.kaya","password":"wrong password"}`))
+		assert.NoError(t, err)
+		return res.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, login())
+	assert.Equal(t, http.StatusTooManyRequests, login())
+}
+
+// TestAPIKeyRateLimit tests that verified API keys are limited by a bucket of their own besides the one of their address
+func TestAPIKeyRateLimit(t *testing.T) {
+	middleware := testMiddleware(testTenant)
+	middleware.RateLimit.Public = app.RateLimit{Requests: 1, Period: app.Duration(time.Minute), Burst: 2}
+	middleware.RateLimit.IPHeader = "X-Forwarded-For"
+	tenantCtx := requestctx.WithTenant(context.Background(), testTenant)
+	user, err := middleware.AuthService.CreateUser(tenantCtx, model.UserCreate{Username: "ayse.kaya", Password: "correct horse battery", Role: domain.RoleAgent})
+	assert.NoError(t, err)
+	key, err := middleware.AuthService.CreateAPIKey(requestctx.WithPrincipal(tenantCtx, domain.Principal{Name: user.Username, UserID: user.ID, Role: user.Role, TenantID: testTenant.ID}), model.APIKeyCreate{Name: "crm-sync"})
+	assert.NoError(t, err)
+	fiberApp := fiber.New(fiber.Config{ErrorHandler: controller.ErrorHandler})
+	controller.UseMiddleware(fiberApp, middleware)
+	controller.NewPropertyController(services.NewPropertyService(service.NewFakePropertyRepository(nil), service.NewFakeAuditRepository(), service.FakeTransactionManager{})).RegisterRoutes(fiberApp)
+
+	get := func(ip string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/properties", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		req.Header.Set(controller.APIKeyHeader, key.Key)
+		res, err := fiberApp.Test(req)
+		assert.NoError(t, err)
+		return res
+	}
+	assert.Equal(t, http.StatusOK, get("10.0.0.1").StatusCode)
+	res := get("10.0.0.2")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0", res.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.3").StatusCode)
+}
@@ -0,0 +1,39 @@
+package imaging
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"kirmac-site-backend/common/imaging"
+	"testing"
+)
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func TestOrientation(t *testing.T) {
+	assert.Equal(t, 6, imaging.Orientation(JPEGWithOrientation(t, 8, 4, 6)))
+	assert.Equal(t, 1, imaging.Orientation([]byte("not a jpeg")))
+}
+
+func TestDecode(t *testing.T) {
+	// the camera stored the picture sideways, orientation 6 means it must be turned 90° clockwise
+	img, format, err := imaging.Decode(JPEGWithOrientation(t, 80, 40, 6))
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 40, 80), img.Bounds())
+	assert.True(t, isRed(img.At(35, 5)), "the red corner moves to the top right")
+	assert.False(t, isRed(img.At(5, 5)))
+
+	_, _, err = imaging.Decode([]byte("<svg></svg>"))
+	assert.ErrorIs(t, err, imaging.ErrUnsupported)
+}
+
+func TestFit(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	assert.Equal(t, image.Rect(0, 0, 320, 160), imaging.Fit(img, 320, 320).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 800, 400), imaging.Fit(img, 800, 600).Bounds())
+	assert.Equal(t, img.Bounds(), imaging.Fit(img, 1920, 1920).Bounds(), "images are never enlarged")
+}
@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"testing"
+	"time"
+)
+
+func TestAgentRepository(t *testing.T) {
+	agentRepository := persistence.NewAgentRepository(dbPool, persistence.QueryTimeouts{})
+
+	agent, err := agentRepository.AddAgent(ctx, domain.Agent{Name: "Mehmet Yilmaz", Title: "Luxury Property Consultant", Languages: []string{"Turkish"}})
+	assert.NoError(t, err)
+	assert.NotZero(t, agent.ID)
+
+	property, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Istanbul, Turkey", Price: 3100000, Title: "Bosphorus Yali", AgentID: &agent.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, "Mehmet Yilmaz", property.AgentName)
+
+	t.Run("RenameShowsOnProperties", func(t *testing.T) {
+		agent.Name = "Mehmet Yılmaz"
+		_, err := agentRepository.UpdateAgent(ctx, agent.ID, agent)
+		assert.NoError(t, err)
+		storedProperty, err := propertyRepository.GetPropertyById(ctx, property.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Mehmet Yılmaz", storedProperty.AgentName)
+		assert.Equal(t, "Luxury Property Consultant", storedProperty.AgentTitle)
+	})
+	t.Run("Portfolio", func(t *testing.T) {
+		page, err := propertyRepository.GetAllProperties(ctx, domain.PropertyCriteria{AgentID: &agent.ID, SortField: domain.SortByID, SortDirection: domain.SortAscending, Page: 1, PageSize: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+	})
+	t.Run("UnknownAgent", func(t *testing.T) {
+		unknown := int64(999999)
+		_, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Izmir, Turkey", Price: 1, Title: "Orphan", AgentID: &unknown})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+	t.Run("DeleteKeepsProperties", func(t *testing.T) {
+		deleted, err := agentRepository.DeleteById(ctx, agent.ID)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		storedProperty, err := propertyRepository.GetPropertyById(ctx, property.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, storedProperty.AgentID)
+	})
+}
+
+func TestAgentTenantScoping(t *testing.T) {
+	agentRepository := persistence.NewAgentRepository(dbPool, persistence.QueryTimeouts{})
+	tenant, err := persistence.NewTenantRepository(dbPool, persistence.QueryTimeouts{}).AddTenant(ctx, domain.Tenant{Slug: "agents-" + time.Now().Format("20060102150405000000"), Name: "Agents", Currency: "TRY"})
+	assert.NoError(t, err)
+	otherCtx := requestctx.WithTenant(context.Background(), tenant)
+
+	agent, err := agentRepository.AddAgent(ctx, domain.Agent{Name: "Ayse Kaya", Languages: []string{"Turkish"}})
+	assert.NoError(t, err)
+	otherAgent, err := agentRepository.AddAgent(otherCtx, domain.Agent{Name: "Zeynep Aydin", Languages: []string{"English"}})
+	assert.NoError(t, err)
+
+	agents, err := agentRepository.GetAllAgents(otherCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Agent{otherAgent}, agents)
+	_, err = agentRepository.GetAgentById(otherCtx, agent.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = agentRepository.UpdateAgent(otherCtx, agent.ID, domain.Agent{Name: "Taken"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	deleted, err := agentRepository.DeleteById(otherCtx, agent.ID)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	_, err = propertyRepository.AddProperty(ctx, domain.Property{Location: "Bodrum, Turkey", Price: 2500000, Title: "Bodrum Villa", AgentID: &otherAgent.ID})
+	assert.ErrorIs(t, err, domain.ErrValidation)
+}
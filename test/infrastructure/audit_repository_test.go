@@ -0,0 +1,61 @@
+package infrastructure
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"testing"
+)
+
+func TestAuditRepository(t *testing.T) {
+	auditRepository := persistence.NewAuditRepository(dbPool, persistence.QueryTimeouts{})
+	transactions := persistence.NewTransactionManager(dbPool)
+
+	property, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Fethiye, Turkey", Price: 1400000, Title: "Lagoon View Villa"})
+	assert.NoError(t, err)
+
+	created := domain.AuditEntry{
+		EntityType: domain.AuditEntityProperty,
+		EntityID:   property.ID,
+		Action:     domain.AuditCreate,
+		Actor:      "ayse.kaya",
+		RequestID:  "req-1",
+		Changes:    []domain.FieldChange{{Field: "title", After: "Lagoon View Villa"}},
+	}
+	assert.NoError(t, auditRepository.AddEntry(ctx, created))
+	assert.NoError(t, transactions.WithTx(ctx, func(ctx context.Context) error {
+		return auditRepository.AddEntry(ctx, domain.AuditEntry{
+			EntityType: domain.AuditEntityProperty,
+			EntityID:   property.ID,
+			Action:     domain.AuditUpdate,
+			Changes:    []domain.FieldChange{{Field: "price", Before: float64(1400000), After: float64(1350000)}},
+		})
+	}))
+
+	t.Run("NewestFirst", func(t *testing.T) {
+		history, err := auditRepository.GetEntries(ctx, domain.AuditEntityProperty, property.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), history.TotalCount)
+		assert.Equal(t, domain.AuditUpdate, history.Entries[0].Action)
+		assert.Equal(t, []domain.FieldChange{{Field: "price", Before: float64(1400000), After: float64(1350000)}}, history.Entries[0].Changes)
+		assert.Equal(t, "ayse.kaya", history.Entries[1].Actor)
+		assert.Equal(t, "req-1", history.Entries[1].RequestID)
+		assert.Equal(t, created.Changes, history.Entries[1].Changes)
+	})
+	t.Run("AppendOnly", func(t *testing.T) {
+		_, err := dbPool.Exec(ctx, "UPDATE audit_log SET actor = 'someone' WHERE entity_id = $1", property.ID)
+		assert.Error(t, err)
+		_, err = dbPool.Exec(ctx, "DELETE FROM audit_log WHERE entity_id = $1", property.ID)
+		assert.Error(t, err)
+	})
+	t.Run("RolledBackWithChange", func(t *testing.T) {
+		_ = transactions.WithTx(ctx, func(ctx context.Context) error {
+			assert.NoError(t, auditRepository.AddEntry(ctx, domain.AuditEntry{EntityType: domain.AuditEntityProperty, EntityID: property.ID, Action: domain.AuditDelete}))
+			return domain.ErrConflict
+		})
+		history, err := auditRepository.GetEntries(ctx, domain.AuditEntityProperty, property.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), history.TotalCount)
+	})
+}
@@ -0,0 +1,67 @@
+package infrastructure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"testing"
+)
+
+func TestPropertyImageRepository(t *testing.T) {
+	imageRepository := persistence.NewPropertyImageRepository(dbPool, persistence.QueryTimeouts{})
+
+	property, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Kas, Turkey", Price: 2100000, Title: "Cliffside Villa in Kas"})
+	assert.NoError(t, err)
+
+	cover, err := imageRepository.AddImage(ctx, property.ID, domain.PropertyImage{
+		URL:      "https://example.com/kas_villa1.jpg",
+		Alt:      "Pool overlooking the sea",
+		IsCover:  true,
+		Width:    1920,
+		Height:   1280,
+		Variants: map[string]domain.ImageVariant{"thumbnail": {URL: "https://example.com/kas_villa1_thumbnail.jpg", Width: 320, Height: 213}},
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, cover.ID)
+	linked, err := imageRepository.AddImage(ctx, property.ID, domain.PropertyImage{URL: "https://example.com/kas_villa2.jpg", Position: 1})
+	assert.NoError(t, err)
+
+	t.Run("ShowOnProperty", func(t *testing.T) {
+		storedProperty, err := propertyRepository.GetPropertyById(ctx, property.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.PropertyImage{cover, linked}, withoutEmptyVariants(storedProperty.Images))
+	})
+	t.Run("Update", func(t *testing.T) {
+		linked.Caption = "Bedroom"
+		linked.Position = 0
+		assert.NoError(t, imageRepository.UpdateImage(ctx, property.ID, linked))
+		images, err := imageRepository.GetImages(ctx, property.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bedroom", images[0].Caption)
+		assert.Equal(t, linked.ID, images[0].ID)
+
+		err = imageRepository.UpdateImage(ctx, property.ID+1000000, linked)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+	t.Run("DuplicateURL", func(t *testing.T) {
+		_, err := imageRepository.AddImage(ctx, property.ID, domain.PropertyImage{URL: "https://example.com/kas_villa2.jpg"})
+		assert.ErrorIs(t, err, domain.ErrConflict)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		deleted, err := imageRepository.DeleteImage(ctx, property.ID, cover.ID)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		images, err := imageRepository.GetImages(ctx, property.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(images))
+	})
+}
+
+func withoutEmptyVariants(images []domain.PropertyImage) []domain.PropertyImage {
+	for i := range images {
+		if len(images[i].Variants) == 0 {
+			images[i].Variants = nil
+		}
+	}
+	return images
+}
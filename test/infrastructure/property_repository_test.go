@@ -2,13 +2,16 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"kirmac-site-backend/common/postgresql"
+	"kirmac-site-backend/common/requestctx"
 	"kirmac-site-backend/domain"
 	"kirmac-site-backend/persistence"
 	"os"
 	"testing"
+	"time"
 )
 
 var propertyRepository persistence.IPropertyRepository
@@ -17,7 +20,8 @@ var ctx context.Context
 
 func TestMain(m *testing.M) {
 
-	ctx = context.Background()
+	// the rows of the test database belong to the default tenant the tenants migration creates
+	ctx = requestctx.WithTenant(context.Background(), domain.Tenant{ID: 1, Slug: "default"})
 	dbPool = postgresql.GetConnectionPool(ctx, postgresql.Config{
 		Host:                  "localhost",
 		Port:                  "5433",
@@ -28,7 +32,7 @@ func TestMain(m *testing.M) {
 		MaxConnectionIdleTime: "30s",
 	})
 
-	propertyRepository = persistence.NewPropertyRepository(dbPool)
+	propertyRepository = persistence.NewPropertyRepository(dbPool, persistence.QueryTimeouts{})
 	exitCode := m.Run()
 	dbPool.Close()
 	os.Exit(exitCode)
@@ -39,6 +43,7 @@ func TestGetAllProperties(t *testing.T) {
 		{
 			ID:          3,
 			Location:    "Antalya, Turkey",
+			City:        "Antalya",
 			Price:       1800000,
 			Title:       "Seaside Penthouse in Antalya",
 			Description: "Stunning penthouse apartment with panoramic sea views in the beautiful coastal city of Antalya.",
@@ -47,11 +52,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  2200,
 			AgentName:   "Ayse Kaya",
 			AgentTitle:  "Luxury Property Specialist",
-			ImageURLs:   []string{"https://example.com/antalya_penthouse1.jpg", "https://example.com/antalya_penthouse2.jpg", "https://example.com/antalya_penthouse3.jpg"},
+			Images:      images("https://example.com/antalya_penthouse1.jpg", "https://example.com/antalya_penthouse2.jpg", "https://example.com/antalya_penthouse3.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          4,
 			Location:    "Bodrum, Turkey",
+			City:        "Bodrum",
 			Price:       3500000,
 			Title:       "Luxury Beach Villa in Bodrum",
 			Description: "Stunning beachfront villa with private pool and direct access to the Aegean Sea.",
@@ -60,11 +68,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  5000,
 			AgentName:   "Mehmet Yilmaz",
 			AgentTitle:  "Luxury Property Consultant",
-			ImageURLs:   []string{"https://example.com/bodrum_villa1.jpg", "https://example.com/bodrum_villa2.jpg"},
+			Images:      images("https://example.com/bodrum_villa1.jpg", "https://example.com/bodrum_villa2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          5,
 			Location:    "Ankara, Turkey",
+			City:        "Ankara",
 			Price:       800000,
 			Title:       "Modern City Apartment",
 			Description: "Centrally located modern apartment with panoramic city views in Ankara.",
@@ -73,11 +84,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  1500,
 			AgentName:   "Zeynep Kaya",
 			AgentTitle:  "City Center Specialist",
-			ImageURLs:   []string{"https://example.com/ankara_apt1.jpg", "https://example.com/ankara_apt2.jpg"},
+			Images:      images("https://example.com/ankara_apt1.jpg", "https://example.com/ankara_apt2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          6,
 			Location:    "Izmir, Turkey",
+			City:        "Izmir",
 			Price:       1200000,
 			Title:       "Seaside Condo in Izmir",
 			Description: "Beautiful condo with sea view, located in the vibrant Alsancak district of Izmir.",
@@ -86,11 +100,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  2000,
 			AgentName:   "Can Demir",
 			AgentTitle:  "Izmir Coast Expert",
-			ImageURLs:   []string{"https://example.com/izmir_condo1.jpg", "https://example.com/izmir_condo2.jpg"},
+			Images:      images("https://example.com/izmir_condo1.jpg", "https://example.com/izmir_condo2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          7,
 			Location:    "Cappadocia, Turkey",
+			City:        "Cappadocia",
 			Price:       950000,
 			Title:       "Unique Cave House in Cappadocia",
 			Description: "One-of-a-kind cave house with modern amenities in the heart of Cappadocia.",
@@ -99,11 +116,30 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  1200,
 			AgentName:   "Ayse Yildiz",
 			AgentTitle:  "Cappadocia Property Specialist",
-			ImageURLs:   []string{"https://example.com/cappadocia_cave1.jpg", "https://example.com/cappadocia_cave2.jpg"},
+			Images:      images("https://example.com/cappadocia_cave1.jpg", "https://example.com/cappadocia_cave2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
+		},
+		{
+			ID:          8,
+			Location:    "Bursa, Turkey",
+			City:        "Bursa",
+			Price:       600000,
+			Title:       "Traditional Ottoman House",
+			Description: "Beautifully restored Ottoman-era house in the historic district of Bursa.",
+			Bedrooms:    10,
+			Bathrooms:   10,
+			SquareFeet:  1800,
+			AgentName:   "Leyla Ozturk",
+			AgentTitle:  "Historical Property Consultant",
+			Images:      images("https://example.com/bursa_ottoman1.jpg", "https://example.com/bursa_ottoman2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          10,
 			Location:    "Trabzon, Turkey",
+			City:        "Trabzon",
 			Price:       750000,
 			Title:       "Black Sea View Apartment",
 			Description: "Modern apartment with stunning Black Sea views in Trabzon.",
@@ -112,11 +148,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  1600,
 			AgentName:   "Emre Sahin",
 			AgentTitle:  "Black Sea Region Specialist",
-			ImageURLs:   []string{"https://example.com/trabzon_apt1.jpg", "https://example.com/trabzon_apt2.jpg"},
+			Images:      images("https://example.com/trabzon_apt1.jpg", "https://example.com/trabzon_apt2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          11,
 			Location:    "Alanya, Turkey",
+			City:        "Alanya",
 			Price:       450000,
 			Title:       "Beachfront Studio in Alanya",
 			Description: "Cozy beachfront studio apartment in the popular tourist destination of Alanya.",
@@ -125,11 +164,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  600,
 			AgentName:   "Selin Aydin",
 			AgentTitle:  "Alanya Beach Property Expert",
-			ImageURLs:   []string{"https://example.com/alanya_studio1.jpg", "https://example.com/alanya_studio2.jpg"},
+			Images:      images("https://example.com/alanya_studio1.jpg", "https://example.com/alanya_studio2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          12,
 			Location:    "Eskisehir, Turkey",
+			City:        "Eskisehir",
 			Price:       350000,
 			Title:       "Student-Friendly Apartment",
 			Description: "Modern apartment ideal for students, close to university campuses in Eskisehir.",
@@ -138,11 +180,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  900,
 			AgentName:   "Ahmet Celik",
 			AgentTitle:  "Student Housing Specialist",
-			ImageURLs:   []string{"https://example.com/eskisehir_apt1.jpg", "https://example.com/eskisehir_apt2.jpg"},
+			Images:      images("https://example.com/eskisehir_apt1.jpg", "https://example.com/eskisehir_apt2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          13,
 			Location:    "Cesme, Turkey",
+			City:        "Cesme",
 			Price:       2200000,
 			Title:       "Luxury Beach House in Cesme",
 			Description: "Elegant beach house with private garden and pool in the exclusive Cesme Peninsula.",
@@ -151,11 +196,14 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  2800,
 			AgentName:   "Deniz Korkmaz",
 			AgentTitle:  "Cesme Luxury Property Advisor",
-			ImageURLs:   []string{"https://example.com/cesme_house1.jpg", "https://example.com/cesme_house2.jpg"},
+			Images:      images("https://example.com/cesme_house1.jpg", "https://example.com/cesme_house2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 		{
 			ID:          14,
 			Location:    "Cesme, Turkey",
+			City:        "Cesme",
 			Price:       2200000,
 			Title:       "Luxury Beach House in Cesme",
 			Description: "Elegant beach house with private garden and pool in the exclusive Cesme Peninsula.",
@@ -164,31 +212,25 @@ func TestGetAllProperties(t *testing.T) {
 			SquareFeet:  2800,
 			AgentName:   "Deniz Korkmaz",
 			AgentTitle:  "Cesme Luxury Property Advisor",
-			ImageURLs:   []string{"https://example.com/cesme_house1.jpg", "https://example.com/cesme_house2.jpg"},
-		},
-		{
-			ID:          8,
-			Location:    "Bursa, Turkey",
-			Price:       600000,
-			Title:       "Traditional Ottoman House",
-			Description: "Beautifully restored Ottoman-era house in the historic district of Bursa.",
-			Bedrooms:    10,
-			Bathrooms:   10,
-			SquareFeet:  1800,
-			AgentName:   "Leyla Ozturk",
-			AgentTitle:  "Historical Property Consultant",
-			ImageURLs:   []string{"https://example.com/bursa_ottoman1.jpg", "https://example.com/bursa_ottoman2.jpg"},
+			Images:      images("https://example.com/cesme_house1.jpg", "https://example.com/cesme_house2.jpg"),
+			Status:      domain.StatusActive,
+			Version:     1,
 		},
 	}
-	allProperties, err := propertyRepository.GetAllProperties()
+	allProperties, err := propertyRepository.GetAllProperties(ctx, domain.PropertyCriteria{
+		SortField:     domain.SortByID,
+		SortDirection: domain.SortAscending,
+		Page:          domain.DefaultPage,
+		PageSize:      domain.DefaultPageSize,
+	})
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
-	if len(allProperties) != len(properties) {
-		t.Errorf("Expected %v properties, but got %v", len(properties), len(allProperties))
+	if len(allProperties.Properties) != len(properties) {
+		t.Errorf("Expected %v properties, but got %v", len(properties), len(allProperties.Properties))
 	}
 	t.Run("TestPropertyRepository", func(t *testing.T) {
-		assert.Equal(t, properties, allProperties)
+		assert.Equal(t, properties, withoutGeneratedValues(allProperties.Properties...))
 	})
 }
 
@@ -196,6 +238,7 @@ func TestGetPropertyById(t *testing.T) {
 	property := domain.Property{
 		ID:          3,
 		Location:    "Antalya, Turkey",
+		City:        "Antalya",
 		Price:       1800000,
 		Title:       "Seaside Penthouse in Antalya",
 		Description: "Stunning penthouse apartment with panoramic sea views in the beautiful coastal city of Antalya.",
@@ -204,14 +247,16 @@ func TestGetPropertyById(t *testing.T) {
 		SquareFeet:  2200,
 		AgentName:   "Ayse Kaya",
 		AgentTitle:  "Luxury Property Specialist",
-		ImageURLs:   []string{"https://example.com/antalya_penthouse1.jpg", "https://example.com/antalya_penthouse2.jpg", "https://example.com/antalya_penthouse3.jpg"},
+		Images:      images("https://example.com/antalya_penthouse1.jpg", "https://example.com/antalya_penthouse2.jpg", "https://example.com/antalya_penthouse3.jpg"),
+		Status:      domain.StatusActive,
+		Version:     1,
 	}
-	propertyById, err := propertyRepository.GetPropertyById(3)
+	propertyById, err := propertyRepository.GetPropertyById(ctx, 3)
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
 	t.Run("TestPropertyRepository", func(t *testing.T) {
-		assert.Equal(t, property, propertyById)
+		assert.Equal(t, property, withoutGeneratedValues(propertyById)[0])
 	})
 }
 
@@ -224,18 +269,23 @@ func TestAddProperty(t *testing.T) {
 		Bedrooms:    5,
 		Bathrooms:   4,
 		SquareFeet:  4000,
-		AgentName:   "Mehmet Yilmaz",
-		AgentTitle:  "Luxury Property Consultant",
-		ImageURLs:   []string{"https://example.com/istanbul_villa1.jpg", "https://example.com/istanbul_villa2.jpg"},
+		Images:      []domain.PropertyImage{},
+		Status:      domain.StatusActive,
+		Version:     1,
+	}
+	addProperty, err := propertyRepository.AddProperty(ctx, property)
+	if err != nil {
+		t.Errorf("Error: %v", err)
 	}
-	addProperty := propertyRepository.AddProperty(property)
 	t.Run("TestPropertyRepository", func(t *testing.T) {
-		assert.Equal(t, property, addProperty)
+		assert.NotZero(t, addProperty.ID)
+		property.ID = addProperty.ID
+		assert.Equal(t, property, withoutGeneratedValues(addProperty)[0])
 	})
 }
 
 func TestDeleteProperty(t *testing.T) {
-	_, err := propertyRepository.DeleteById(14)
+	_, err := propertyRepository.DeleteById(ctx, 14, 0)
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
@@ -243,3 +293,198 @@ func TestDeleteProperty(t *testing.T) {
 		assert.Equal(t, true, true)
 	})
 }
+
+func TestUpdateProperty(t *testing.T) {
+	property, err := propertyRepository.AddProperty(ctx, domain.Property{
+		Location: "Bodrum, Turkey",
+		Price:    950000,
+		Title:    "Stone House in Bodrum",
+	})
+	assert.NoError(t, err)
+
+	property.Price = 990000
+	updatedProperty, err := propertyRepository.UpdateProperty(ctx, property.ID, property)
+	t.Run("KeepsId", func(t *testing.T) {
+		assert.NoError(t, err)
+		assert.Equal(t, property.ID, updatedProperty.ID)
+		assert.Equal(t, int64(2), updatedProperty.Version)
+		storedProperty, err := propertyRepository.GetPropertyById(ctx, property.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 990000, storedProperty.Price)
+	})
+	t.Run("StaleVersion", func(t *testing.T) {
+		_, err := propertyRepository.UpdateProperty(ctx, property.ID, property)
+		assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := propertyRepository.UpdateProperty(ctx, 999999, domain.Property{Title: "Missing"})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestWithTx(t *testing.T) {
+	transactionManager := persistence.NewTransactionManager(dbPool)
+	rollback := errors.New("rollback")
+	var added domain.Property
+	err := transactionManager.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		added, err = propertyRepository.AddProperty(ctx, domain.Property{Location: "Izmir, Turkey", Price: 700000, Title: "Rolled Back Flat"})
+		assert.NoError(t, err)
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+	_, err = propertyRepository.GetPropertyById(ctx, added.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+// images lists the images of a property created from image URLs by the migrations, the first one is the cover
+func images(urls ...string) []domain.PropertyImage {
+	var propertyImages []domain.PropertyImage
+	for i, url := range urls {
+		propertyImages = append(propertyImages, domain.PropertyImage{URL: url, Position: i, IsCover: i == 0})
+	}
+	return propertyImages
+}
+
+// withoutGeneratedValues clears the status timestamps, the generated ids and empty variants of the images so they can be compared with images
+func withoutGeneratedValues(properties ...domain.Property) []domain.Property {
+	for i := range properties {
+		properties[i].StatusChangedAt = time.Time{}
+		for j := range properties[i].Images {
+			properties[i].Images[j].ID = 0
+			if len(properties[i].Images[j].Variants) == 0 {
+				properties[i].Images[j].Variants = nil
+			}
+		}
+	}
+	return properties
+}
+
+func TestGetNearbyProperties(t *testing.T) {
+	latitude, longitude := 36.5437, 29.1166
+	nearby, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Fethiye, Turkey", City: "Fethiye", Latitude: &latitude, Longitude: &longitude, Price: 1300000, Title: "Lagoon View Villa"})
+	assert.NoError(t, err)
+	latitude, longitude = 36.6203, 29.1164
+	_, err = propertyRepository.AddProperty(ctx, domain.Property{Location: "Fethiye, Turkey", City: "Fethiye", Latitude: &latitude, Longitude: &longitude, Price: 900000, Title: "Harbour Flat"})
+	assert.NoError(t, err)
+
+	page, err := propertyRepository.GetNearbyProperties(ctx, domain.PropertyCriteria{
+		Near:     &domain.GeoRadius{Center: domain.GeoPoint{Latitude: 36.5440, Longitude: 29.1170}, RadiusKm: 5},
+		Page:     1,
+		PageSize: 10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+	assert.Equal(t, nearby.ID, page.Results[0].Property.ID)
+	assert.InDelta(t, 0.05, page.Results[0].DistanceKm, 0.05)
+
+	within, err := propertyRepository.GetAllProperties(ctx, domain.PropertyCriteria{
+		Within:        &domain.BoundingBox{MinLatitude: 36.5, MinLongitude: 29, MaxLatitude: 36.7, MaxLongitude: 29.2},
+		SortField:     domain.SortByID,
+		SortDirection: domain.SortAscending,
+		Page:          1,
+		PageSize:      10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), within.TotalCount)
+}
+
+func TestQueryPropertiesAndClusters(t *testing.T) {
+	latitude, longitude := 37.0344, 27.4305
+	first, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Bodrum, Turkey", City: "Bodrum", Latitude: &latitude, Longitude: &longitude, Price: 3200000, Title: "Marina Villa"})
+	assert.NoError(t, err)
+	latitude, longitude = 37.0402, 27.4401
+	second, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Bodrum, Turkey", City: "Bodrum", Latitude: &latitude, Longitude: &longitude, Price: 1400000, Title: "Castle View Flat"})
+	assert.NoError(t, err)
+	_, err = propertyRepository.AddProperty(ctx, domain.Property{Location: "Bodrum, Turkey", City: "Bodrum", Price: 800000, Title: "Unmapped Studio"})
+	assert.NoError(t, err)
+
+	criteria := domain.PropertyCriteria{City: "Bodrum", HasCoordinates: true, SortField: domain.SortByPrice, SortDirection: domain.SortAscending}
+	rows, err := propertyRepository.QueryProperties(ctx, criteria, 10)
+	assert.NoError(t, err)
+	var ids []int64
+	for rows.Next() {
+		property, err := rows.Property()
+		assert.NoError(t, err)
+		ids = append(ids, property.ID)
+	}
+	assert.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, []int64{second.ID, first.ID}, ids)
+
+	clusters, err := propertyRepository.GetPropertyClusters(ctx, domain.PropertyCriteria{City: "Bodrum"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(clusters))
+	assert.Equal(t, int64(2), clusters[0].Count)
+	assert.Equal(t, 1400000, clusters[0].MinPrice)
+	assert.Equal(t, 3200000, clusters[0].MaxPrice)
+	assert.InDelta(t, 37.0373, clusters[0].Center.Latitude, 0.0001)
+}
+
+func TestTransitionProperty(t *testing.T) {
+	property, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio", Status: domain.StatusDraft})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusDraft, property.Status)
+
+	change := domain.StatusChange{FromStatus: domain.StatusDraft, ToStatus: domain.StatusActive, Actor: "ayse.kaya", Note: "Photos are ready"}
+	transitioned, err := propertyRepository.TransitionProperty(ctx, property.ID, change, property.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusActive, transitioned.Status)
+	assert.Equal(t, property.Version+1, transitioned.Version)
+
+	_, err = propertyRepository.TransitionProperty(ctx, property.ID, change, 0)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	changes, err := propertyRepository.GetStatusChanges(ctx, property.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, "ayse.kaya", changes[0].Actor)
+	assert.Equal(t, transitioned.StatusChangedAt, changes[0].ChangedAt)
+
+	listed, err := propertyRepository.GetAllProperties(ctx, domain.PropertyCriteria{
+		Location:      "Kas",
+		Statuses:      []domain.PropertyStatus{domain.StatusDraft},
+		SortField:     domain.SortByID,
+		SortDirection: domain.SortAscending,
+		Page:          1,
+		PageSize:      10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), listed.TotalCount)
+}
+
+func TestSoftDelete(t *testing.T) {
+	property, err := propertyRepository.AddProperty(ctx, domain.Property{Location: "Datca, Turkey", Price: 1100000, Title: "Olive Grove House"})
+	assert.NoError(t, err)
+	image, err := persistence.NewPropertyImageRepository(dbPool, persistence.QueryTimeouts{}).AddImage(ctx, property.ID, domain.PropertyImage{URL: "https://example.com/datca_house1.jpg"})
+	assert.NoError(t, err)
+
+	deleted, err := propertyRepository.DeleteById(ctx, property.ID, property.Version)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = propertyRepository.GetPropertyById(ctx, property.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	trash, err := propertyRepository.GetDeletedProperties(ctx, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, property.ID, trash.Properties[0].ID)
+	assert.NotNil(t, trash.Properties[0].DeletedAt)
+
+	restored, err := propertyRepository.RestoreProperty(ctx, property.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, property.Version+2, restored.Version)
+
+	_, err = propertyRepository.DeleteById(ctx, property.ID, 0)
+	assert.NoError(t, err)
+	purged, images, err := propertyRepository.PurgeDeletedProperties(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+	var purgedImageIDs []int64
+	for _, purgedImage := range images {
+		purgedImageIDs = append(purgedImageIDs, purgedImage.ID)
+	}
+	assert.Contains(t, purgedImageIDs, image.ID, "the images of purged properties are returned to delete their files")
+	_, err = propertyRepository.RestoreProperty(ctx, property.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
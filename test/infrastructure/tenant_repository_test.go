@@ -0,0 +1,68 @@
+package infrastructure
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"testing"
+	"time"
+)
+
+func TestTenantRepository(t *testing.T) {
+	tenantRepository := persistence.NewTenantRepository(dbPool, persistence.QueryTimeouts{})
+	slug := "agency-" + time.Now().Format("20060102150405000000")
+
+	tenant, err := tenantRepository.AddTenant(ctx, domain.Tenant{Slug: slug, Name: "Ege Emlak", Currency: "EUR", Hosts: []string{slug + ".example.com"}, CorsOrigins: []string{"https://" + slug + ".example.com"}})
+	assert.NoError(t, err)
+	assert.NotZero(t, tenant.ID)
+	_, err = tenantRepository.AddTenant(ctx, domain.Tenant{Slug: slug + "-other", Name: "Other", Currency: "TRY", Hosts: []string{slug + ".example.com"}})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	stored, err := tenantRepository.GetTenantByHost(ctx, slug+".example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, tenant, stored)
+	stored, err = tenantRepository.GetTenantBySlug(ctx, slug)
+	assert.NoError(t, err)
+	assert.Equal(t, tenant.ID, stored.ID)
+	_, err = tenantRepository.GetTenantBySlug(ctx, slug+"-other")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestTenantScoping(t *testing.T) {
+	tenantRepository := persistence.NewTenantRepository(dbPool, persistence.QueryTimeouts{})
+	tenant, err := tenantRepository.AddTenant(ctx, domain.Tenant{Slug: "scoped-" + time.Now().Format("20060102150405000000"), Name: "Scoped", Currency: "TRY"})
+	assert.NoError(t, err)
+	tenantCtx := requestctx.WithTenant(context.Background(), tenant)
+
+	property, err := propertyRepository.AddProperty(tenantCtx, domain.Property{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio"})
+	assert.NoError(t, err)
+
+	_, err = propertyRepository.GetPropertyById(tenantCtx, property.ID)
+	assert.NoError(t, err)
+	_, err = propertyRepository.GetPropertyById(ctx, property.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = propertyRepository.UpdateProperty(ctx, property.ID, domain.Property{Location: "Kas, Turkey", Price: 1, Title: "Taken"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	page, err := propertyRepository.GetAllProperties(tenantCtx, domain.PropertyCriteria{Page: 1, PageSize: 10, SortField: "id", SortDirection: "asc"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+
+	_, err = propertyRepository.GetPropertyById(context.Background(), property.ID)
+	assert.Error(t, err)
+}
+
+func TestRowLevelSecurityPolicies(t *testing.T) {
+	rows, err := dbPool.Query(ctx, `SELECT tablename FROM pg_policies WHERE policyname LIKE '%_tenant_isolation' ORDER BY tablename`)
+	assert.NoError(t, err)
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		assert.NoError(t, rows.Scan(&table))
+		tables = append(tables, table)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, []string{"agents", "api_keys", "audit_log", "properties", "property_images", "property_status_changes", "users"}, tables)
+}
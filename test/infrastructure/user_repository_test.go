@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"testing"
+	"time"
+)
+
+func TestUserRepository(t *testing.T) {
+	userRepository := persistence.NewUserRepository(dbPool, persistence.QueryTimeouts{})
+	username := "user-" + time.Now().Format("20060102150405.000000")
+
+	user, err := userRepository.AddUser(ctx, domain.User{Username: username, PasswordHash: "$2a$10$hash", Role: domain.RoleAgent, TenantID: 1})
+	assert.NoError(t, err)
+	assert.NotZero(t, user.ID)
+	_, err = userRepository.AddUser(ctx, domain.User{Username: username, PasswordHash: "$2a$10$hash", TenantID: 1})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	stored, err := userRepository.GetUserByUsername(ctx, username)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, stored.ID)
+	stored, err = userRepository.GetUserById(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "$2a$10$hash", stored.PasswordHash)
+	assert.Equal(t, domain.RoleAgent, stored.Role)
+	assert.Equal(t, int64(1), stored.TenantID)
+	_, err = userRepository.GetUserByUsername(ctx, username+"-missing")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestAPIKeyRepository(t *testing.T) {
+	apiKeyRepository := persistence.NewAPIKeyRepository(dbPool, persistence.QueryTimeouts{})
+	keyHash := time.Now().Format("20060102150405.000000000")
+	user, err := persistence.NewUserRepository(dbPool, persistence.QueryTimeouts{}).AddUser(ctx, domain.User{Username: "key-" + keyHash, PasswordHash: "$2a$10$hash", Role: domain.RoleAgent, TenantID: 1})
+	assert.NoError(t, err)
+
+	key, err := apiKeyRepository.AddAPIKey(ctx, domain.APIKey{UserID: user.ID, Name: "crm-sync", Prefix: "kk_abcdefg", KeyHash: keyHash})
+	assert.NoError(t, err)
+	keys, err := apiKeyRepository.GetAPIKeys(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	used, err := apiKeyRepository.UseAPIKey(ctx, keyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, used.ID)
+	assert.NotNil(t, used.LastUsedAt)
+
+	_, err = apiKeyRepository.RevokeAPIKey(ctx, key.ID, user.ID+1)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	revoked, err := apiKeyRepository.RevokeAPIKey(ctx, key.ID, user.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+	_, err = apiKeyRepository.UseAPIKey(ctx, keyHash)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = apiKeyRepository.RevokeAPIKey(ctx, key.ID, 0)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
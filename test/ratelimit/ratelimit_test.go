@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/ratelimit"
+	"testing"
+	"time"
+)
+
+// TestMemoryStore tests that the buckets of the memory store empty, refill and are kept apart by key
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 60, Period: time.Minute, Burst: 3}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := store.Take(ctx, "ip:10.0.0.1", limit, now)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+	result, err := store.Take(ctx, "ip:10.0.0.1", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.ResetAfter)
+
+	result, err = store.Take(ctx, "ip:10.0.0.2", limit, now)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = store.Take(ctx, "ip:10.0.0.1", limit, now.Add(1500*time.Millisecond))
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result, err = store.Take(ctx, "ip:10.0.0.1", limit, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+}
+
+// TestBucketFull tests when a bucket has refilled and can be forgotten
+func TestBucketFull(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 10, Period: 10 * time.Second, Burst: 2}
+	now := time.Now()
+	assert.True(t, ratelimit.Bucket{}.Full(limit, now))
+
+	bucket, _ := ratelimit.Bucket{}.Take(limit, now)
+	assert.False(t, bucket.Full(limit, now))
+	assert.True(t, bucket.Full(limit, now.Add(time.Second)))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"testing"
+)
+
+func newAgentService() services.IAgentService {
+	agentID := int64(1)
+	return services.NewAgentService(
+		NewFakeAgentRepository([]domain.Agent{
+			{ID: 1, Name: "Ayse Kaya", Title: "Luxury Property Specialist", Languages: []string{"Turkish", "English"}},
+			{ID: 2, Name: "Deniz Korkmaz", Title: "Coastal Property Expert"},
+		}),
+		NewFakePropertyRepository([]domain.Property{
+			{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse in Antalya", AgentID: &agentID},
+			{ID: 2, Location: "Ankara, Turkey", Price: 800000, Title: "Modern City Apartment"},
+			{ID: 3, Location: "Alanya, Turkey", Price: 950000, Title: "Beachfront Villa in Alanya", AgentID: &agentID},
+		}),
+	)
+}
+
+// TestAddAgent tests the AddAgent method of the AgentService
+func TestAddAgent(t *testing.T) {
+	agentService := newAgentService()
+
+	t.Run("TrimsAndAdds", func(t *testing.T) {
+		agent, err := agentService.AddAgent(ctx, model.AgentCreate{Name: "  Selin Aydin ", Email: "selin@example.com", Phone: "+90 532 123 45 67"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), agent.ID)
+		assert.Equal(t, "Selin Aydin", agent.Name)
+	})
+	t.Run("Validation", func(t *testing.T) {
+		_, err := agentService.AddAgent(ctx, model.AgentCreate{Email: "selin", Phone: "call me", PhotoURL: "ftp://example.com/selin.jpg", Languages: []string{""}})
+		var validationError *domain.ValidationError
+		assert.ErrorAs(t, err, &validationError)
+		var fields []string
+		for _, fieldError := range validationError.Fields {
+			fields = append(fields, fieldError.Field)
+		}
+		assert.Equal(t, []string{"name", "phone", "email", "photo_url", "languages[0]"}, fields)
+	})
+}
+
+// TestUpdateAgent tests the UpdateAgent and DeleteById methods of the AgentService
+func TestUpdateAgent(t *testing.T) {
+	agentService := newAgentService()
+
+	updated, err := agentService.UpdateAgent(ctx, 2, model.AgentCreate{Name: "Deniz Korkmaz", Title: "Senior Coastal Property Expert"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Senior Coastal Property Expert", updated.Title)
+
+	_, err = agentService.UpdateAgent(ctx, 42, model.AgentCreate{Name: "Nobody"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	deleted, err := agentService.DeleteById(ctx, 2)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = agentService.DeleteById(ctx, 2)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+// TestGetAgentProperties tests the GetAgentProperties method of the AgentService
+func TestGetAgentProperties(t *testing.T) {
+	agentService := newAgentService()
+
+	page, err := agentService.GetAgentProperties(ctx, 1, domain.PropertyCriteria{SortField: domain.SortByPrice})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.TotalCount)
+	assert.Equal(t, int64(3), page.Properties[0].ID)
+	assert.Equal(t, int64(1), page.Properties[1].ID)
+
+	page, err = agentService.GetAgentProperties(ctx, 2, domain.PropertyCriteria{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), page.TotalCount)
+
+	_, err = agentService.GetAgentProperties(ctx, 42, domain.PropertyCriteria{})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
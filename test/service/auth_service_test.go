@@ -0,0 +1,109 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/common/token"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// TestAuthService tests accounts, sign in, token refresh and API keys of the AuthService
+func TestAuthService(t *testing.T) {
+	issuer := token.NewIssuer(testSecret, "kirmac-test", 15*time.Minute, 24*time.Hour)
+	apiKeys := NewFakeAPIKeyRepository()
+	authService := services.NewAuthService(NewFakeUserRepository(), apiKeys, issuer)
+
+	user, err := authService.CreateUser(ctx, model.UserCreate{Username: " ayse.kaya ", Password: "correct horse battery", Role: domain.RoleAgent})
+	assert.NoError(t, err)
+	assert.Equal(t, "ayse.kaya", user.Username)
+	assert.Equal(t, domain.RoleAgent, user.Role)
+	assert.NotContains(t, user.PasswordHash, "correct horse")
+
+	t.Run("CreateUserValidation", func(t *testing.T) {
+		_, err := authService.CreateUser(ctx, model.UserCreate{Username: "bad name", Password: "short", Role: "owner"})
+		var validationError *domain.ValidationError
+		assert.ErrorAs(t, err, &validationError)
+		assert.Len(t, validationError.Fields, 3)
+		_, err = authService.CreateUser(ctx, model.UserCreate{Username: "ayse.kaya", Password: "another long password"})
+		assert.ErrorIs(t, err, domain.ErrConflict)
+
+		viewer, err := authService.CreateUser(ctx, model.UserCreate{Username: "mehmet.demir", Password: "another long password"})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RoleViewer, viewer.Role)
+	})
+	t.Run("Login", func(t *testing.T) {
+		tokens, err := authService.Login(ctx, model.Credentials{Username: "ayse.kaya", Password: "correct horse battery"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", tokens.TokenType)
+
+		principal, err := authService.Authenticate(ctx, tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.Principal{Name: "ayse.kaya", UserID: user.ID, Role: domain.RoleAgent, TenantID: 1}, principal)
+
+		_, err = authService.Authenticate(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+		refreshed, err := authService.Refresh(ctx, tokens.RefreshToken)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, refreshed.AccessToken)
+		_, err = authService.Refresh(ctx, tokens.AccessToken)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+	t.Run("InvalidCredentials", func(t *testing.T) {
+		_, err := authService.Login(ctx, model.Credentials{Username: "ayse.kaya", Password: "wrong password"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+		_, err = authService.Login(ctx, model.Credentials{Username: "nobody", Password: "correct horse battery"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+	t.Run("InvalidTokens", func(t *testing.T) {
+		expired, _, err := issuer.Issue(token.Access, token.Identity{Subject: "1", Name: "ayse.kaya", Role: "agent", Tenant: 1}, time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		_, err = authService.Authenticate(ctx, expired)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+
+		forged, _, err := token.NewIssuer([]byte("another secret another secret 32"), "kirmac-test", time.Minute, time.Hour).Issue(token.Access, token.Identity{Subject: "1", Name: "ayse.kaya", Role: "agent", Tenant: 1}, time.Now())
+		assert.NoError(t, err)
+		_, err = authService.Authenticate(ctx, forged)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+		_, err = authService.Authenticate(ctx, "not.a.token")
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+	t.Run("APIKeys", func(t *testing.T) {
+		agentCtx := requestctx.WithPrincipal(ctx, domain.Principal{Name: "ayse.kaya", UserID: user.ID, Role: domain.RoleAgent})
+		created, err := authService.CreateAPIKey(agentCtx, model.APIKeyCreate{Name: "crm-sync"})
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, created.UserID)
+		assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+		assert.NotContains(t, created.KeyHash, created.Key)
+
+		principal, err := authService.AuthenticateAPIKey(ctx, created.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.Principal{Name: "api-key:crm-sync", UserID: user.ID, Role: domain.RoleAgent, TenantID: 1, KeyID: created.ID}, principal)
+		keys, err := authService.GetAPIKeys(agentCtx)
+		assert.NoError(t, err)
+		assert.NotNil(t, keys[0].LastUsedAt)
+
+		otherAgentCtx := requestctx.WithPrincipal(ctx, domain.Principal{Name: "zeynep.aydin", UserID: 99, Role: domain.RoleAgent})
+		keys, err = authService.GetAPIKeys(otherAgentCtx)
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+		_, err = authService.RevokeAPIKey(otherAgentCtx, created.ID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		viewerCtx := requestctx.WithPrincipal(ctx, domain.Principal{Name: "mehmet.demir", UserID: 2, Role: domain.RoleViewer})
+		_, err = authService.CreateAPIKey(viewerCtx, model.APIKeyCreate{Name: "crm-sync"})
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		_, err = authService.RevokeAPIKey(ctx, created.ID)
+		assert.NoError(t, err)
+		_, err = authService.AuthenticateAPIKey(ctx, created.Key)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+		_, err = authService.AuthenticateAPIKey(ctx, "kk_unknown")
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/domain"
+	"sort"
+)
+
+type FakeAgentRepository struct {
+	agents []domain.Agent
+}
+
+func NewFakeAgentRepository(initialAgents []domain.Agent) *FakeAgentRepository {
+	return &FakeAgentRepository{
+		agents: initialAgents,
+	}
+}
+
+func (repository *FakeAgentRepository) GetAllAgents(ctx context.Context) ([]domain.Agent, error) {
+	agents := append([]domain.Agent(nil), repository.agents...)
+	sort.SliceStable(agents, func(i, j int) bool {
+		return agents[i].Name < agents[j].Name
+	})
+	return agents, nil
+}
+
+func (repository *FakeAgentRepository) GetAgentById(ctx context.Context, id int64) (domain.Agent, error) {
+	for _, agent := range repository.agents {
+		if agent.ID == id {
+			return agent, nil
+		}
+	}
+	return domain.Agent{}, fmt.Errorf("agent %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakeAgentRepository) AddAgent(ctx context.Context, agent domain.Agent) (domain.Agent, error) {
+	for _, a := range repository.agents {
+		if a.ID > agent.ID {
+			agent.ID = a.ID
+		}
+	}
+	agent.ID++
+	repository.agents = append(repository.agents, agent)
+	return agent, nil
+}
+
+func (repository *FakeAgentRepository) UpdateAgent(ctx context.Context, id int64, agent domain.Agent) (domain.Agent, error) {
+	for i, a := range repository.agents {
+		if a.ID == id {
+			agent.ID = id
+			repository.agents[i] = agent
+			return agent, nil
+		}
+	}
+	return domain.Agent{}, fmt.Errorf("agent %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakeAgentRepository) DeleteById(ctx context.Context, id int64) (bool, error) {
+	for i, agent := range repository.agents {
+		if agent.ID == id {
+			repository.agents = append(repository.agents[:i], repository.agents[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/domain"
+	"time"
+)
+
+type FakeAPIKeyRepository struct {
+	keys []domain.APIKey
+}
+
+func NewFakeAPIKeyRepository() *FakeAPIKeyRepository {
+	return &FakeAPIKeyRepository{}
+}
+
+func (repository *FakeAPIKeyRepository) AddAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	key.ID = int64(len(repository.keys) + 1)
+	key.CreatedAt = time.Now()
+	repository.keys = append(repository.keys, key)
+	return key, nil
+}
+
+func (repository *FakeAPIKeyRepository) GetAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	keys := []domain.APIKey{}
+	for _, key := range repository.keys {
+		if userID == 0 || key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (repository *FakeAPIKeyRepository) UseAPIKey(ctx context.Context, keyHash string) (domain.APIKey, error) {
+	for i, key := range repository.keys {
+		if key.KeyHash == keyHash && key.RevokedAt == nil {
+			now := time.Now()
+			repository.keys[i].LastUsedAt = &now
+			return repository.keys[i], nil
+		}
+	}
+	return domain.APIKey{}, fmt.Errorf("API key: %w", domain.ErrNotFound)
+}
+
+func (repository *FakeAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int64, userID int64) (domain.APIKey, error) {
+	for i, key := range repository.keys {
+		if key.ID == id && (userID == 0 || key.UserID == userID) && key.RevokedAt == nil {
+			now := time.Now()
+			repository.keys[i].RevokedAt = &now
+			return repository.keys[i], nil
+		}
+	}
+	return domain.APIKey{}, fmt.Errorf("API key %d: %w", id, domain.ErrNotFound)
+}
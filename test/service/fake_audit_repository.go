@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"kirmac-site-backend/domain"
+	"time"
+)
+
+type FakeAuditRepository struct {
+	entries []domain.AuditEntry
+}
+
+func NewFakeAuditRepository() *FakeAuditRepository {
+	return &FakeAuditRepository{}
+}
+
+func (repository *FakeAuditRepository) AddEntry(ctx context.Context, entry domain.AuditEntry) error {
+	entry.ID = int64(len(repository.entries) + 1)
+	entry.CreatedAt = time.Now()
+	repository.entries = append(repository.entries, entry)
+	return nil
+}
+
+func (repository *FakeAuditRepository) GetEntries(ctx context.Context, entityType string, entityID int64, page int, pageSize int) (domain.AuditPage, error) {
+	matching := []domain.AuditEntry{}
+	for i := len(repository.entries) - 1; i >= 0; i-- {
+		entry := repository.entries[i]
+		if entry.EntityType == entityType && entry.EntityID == entityID {
+			matching = append(matching, entry)
+		}
+	}
+
+	entries := []domain.AuditEntry{}
+	start := (page - 1) * pageSize
+	if start < len(matching) {
+		end := start + pageSize
+		if end > len(matching) {
+			end = len(matching)
+		}
+		entries = matching[start:end]
+	}
+	return domain.AuditPage{
+		Entries:    entries,
+		TotalCount: int64(len(matching)),
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
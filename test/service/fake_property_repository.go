@@ -1,53 +1,598 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"kirmac-site-backend/domain"
+	"kirmac-site-backend/persistence"
+	"math"
+	"sort"
+	"strings"
+	"time"
 )
 
+// FakePropertyRepository keeps properties in memory, it is also the repository of their images
 type FakePropertyRepository struct {
-	properties []domain.Property
+	properties    []domain.Property
+	lastImageID   int64
+	statusChanges []domain.StatusChange
 }
 
+// NewFakePropertyRepository keeps the given properties, those without a status are active like the rows the status migration found
 func NewFakePropertyRepository(initialProperty []domain.Property) *FakePropertyRepository {
+	for i := range initialProperty {
+		if initialProperty[i].Status == "" {
+			initialProperty[i].Status = domain.StatusActive
+		}
+	}
 	return &FakePropertyRepository{
 		properties: initialProperty,
 	}
 }
 
-func (repository *FakePropertyRepository) GetAllProperties() ([]domain.Property, error) {
-	return repository.properties, nil
+// withImages returns a copy of property with an empty list when it has no images like the real repository does
+func (repository *FakePropertyRepository) withImages(property domain.Property) domain.Property {
+	property.Images = append([]domain.PropertyImage{}, property.Images...)
+	return property
+}
+
+func (repository *FakePropertyRepository) GetAllProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyPage, error) {
+	matches := repository.sortedMatches(criteria)
+	page := domain.PropertyPage{
+		TotalCount: int64(len(matches)),
+		Page:       criteria.Page,
+		PageSize:   criteria.PageSize,
+	}
+	start := int(criteria.Offset())
+	if start >= len(matches) {
+		return page, nil
+	}
+	end := start + criteria.PageSize
+	if end > len(matches) {
+		end = len(matches)
+	}
+	page.Properties = matches[start:end]
+	return page, nil
+}
+
+// QueryProperties returns rows over at most limit sorted matches
+func (repository *FakePropertyRepository) QueryProperties(ctx context.Context, criteria domain.PropertyCriteria, limit int) (persistence.PropertyRows, error) {
+	matches := repository.sortedMatches(criteria)
+	return &fakePropertyRows{properties: matches[:min(limit, len(matches))], index: -1}, nil
+}
+
+// GetPropertyClusters groups the matches by the grid cell of their coordinates, largest first
+func (repository *FakePropertyRepository) GetPropertyClusters(ctx context.Context, criteria domain.PropertyCriteria, cellDegrees float64) ([]domain.PropertyCluster, error) {
+	criteria.HasCoordinates = true
+	type cell struct{ row, column float64 }
+	cells := map[cell]*domain.PropertyCluster{}
+	var clusters []*domain.PropertyCluster
+	for _, property := range repository.properties {
+		if !matchesCriteria(property, criteria) {
+			continue
+		}
+		key := cell{math.Floor(*property.Latitude / cellDegrees), math.Floor(*property.Longitude / cellDegrees)}
+		cluster, ok := cells[key]
+		if !ok {
+			cluster = &domain.PropertyCluster{MinPrice: property.Price, MaxPrice: property.Price}
+			cells[key] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.Center.Latitude += *property.Latitude
+		cluster.Center.Longitude += *property.Longitude
+		cluster.Count++
+		cluster.MinPrice = min(cluster.MinPrice, property.Price)
+		cluster.MaxPrice = max(cluster.MaxPrice, property.Price)
+	}
+
+	result := make([]domain.PropertyCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		cluster.Center.Latitude /= float64(cluster.Count)
+		cluster.Center.Longitude /= float64(cluster.Count)
+		result = append(result, *cluster)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Count > result[j].Count
+	})
+	return result, nil
+}
+
+// sortedMatches returns copies of the properties matching the criteria in their sort order
+func (repository *FakePropertyRepository) sortedMatches(criteria domain.PropertyCriteria) []domain.Property {
+	var matches []domain.Property
+	for _, property := range repository.properties {
+		if matchesCriteria(property, criteria) {
+			matches = append(matches, repository.withImages(property))
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		less, equal := compareProperties(matches[i], matches[j], criteria.SortField)
+		if equal {
+			return matches[i].ID < matches[j].ID
+		}
+		if criteria.SortDirection == domain.SortDescending {
+			return !less
+		}
+		return less
+	})
+	return matches
+}
+
+// GetNearbyProperties orders the matches by their haversine distance from the center
+func (repository *FakePropertyRepository) GetNearbyProperties(ctx context.Context, criteria domain.PropertyCriteria) (domain.PropertyDistancePage, error) {
+	var results []domain.PropertyDistance
+	for _, property := range repository.properties {
+		if matchesCriteria(property, criteria) {
+			results = append(results, domain.PropertyDistance{
+				Property:   repository.withImages(property),
+				DistanceKm: domain.DistanceKm(criteria.Near.Center, domain.GeoPoint{Latitude: *property.Latitude, Longitude: *property.Longitude}),
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].DistanceKm < results[j].DistanceKm
+	})
+
+	page := domain.PropertyDistancePage{
+		TotalCount: int64(len(results)),
+		Page:       criteria.Page,
+		PageSize:   criteria.PageSize,
+	}
+	start := int(criteria.Offset())
+	if start >= len(results) {
+		return page, nil
+	}
+	page.Results = results[start:min(start+criteria.PageSize, len(results))]
+	return page, nil
 }
 
-func (repository *FakePropertyRepository) GetPropertyById(id int64) (domain.Property, error) {
+func (repository *FakePropertyRepository) GetPropertyById(ctx context.Context, id int64) (domain.Property, error) {
 	for _, property := range repository.properties {
-		if property.ID == id {
-			return property, nil
+		if property.ID == id && property.DeletedAt == nil {
+			return repository.withImages(property), nil
 		}
 	}
-	return domain.Property{}, nil
+	return domain.Property{}, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakePropertyRepository) GetPropertyForUpdate(ctx context.Context, id int64) (domain.Property, error) {
+	return repository.GetPropertyById(ctx, id)
 }
 
-func (repository *FakePropertyRepository) AddProperty(property domain.Property) domain.Property {
+func (repository *FakePropertyRepository) GetDeletedPropertyForUpdate(ctx context.Context, id int64) (domain.Property, error) {
+	for _, property := range repository.properties {
+		if property.ID == id && property.DeletedAt != nil {
+			return repository.withImages(property), nil
+		}
+	}
+	return domain.Property{}, fmt.Errorf("deleted property %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakePropertyRepository) AddProperty(ctx context.Context, property domain.Property) (domain.Property, error) {
+	for _, p := range repository.properties {
+		if p.ID > property.ID {
+			property.ID = p.ID
+		}
+	}
+	property.ID++
+	property.Version = 1
+	if property.Status == "" {
+		property.Status = domain.StatusActive
+	}
+	property.StatusChangedAt = time.Now()
 	repository.properties = append(repository.properties, property)
-	return property
+	return repository.withImages(property), nil
 }
 
-func (repository *FakePropertyRepository) DeleteById(id int64) (bool, error) {
+func (repository *FakePropertyRepository) DeleteById(ctx context.Context, id int64, version int64) (bool, error) {
 	for i, property := range repository.properties {
-		if property.ID == id {
-			repository.properties = append(repository.properties[:i], repository.properties[i+1:]...)
+		if property.ID == id && property.DeletedAt == nil {
+			if version != 0 && property.Version != version {
+				return false, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+			}
+			deletedAt := time.Now()
+			repository.properties[i].DeletedAt = &deletedAt
+			repository.properties[i].Version++
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func (repository *FakePropertyRepository) UpdateProperty(id int64, property domain.Property) error {
+func (repository *FakePropertyRepository) UpdateProperty(ctx context.Context, id int64, property domain.Property) (domain.Property, error) {
 	for i, p := range repository.properties {
-		if p.ID == id {
+		if p.ID == id && p.DeletedAt == nil {
+			if property.Version != 0 && p.Version != property.Version {
+				return domain.Property{}, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+			}
+			property.ID = id
+			property.Version = p.Version + 1
+			property.Images = p.Images
+			property.Status = p.Status
+			property.StatusChangedAt = p.StatusChangedAt
+			property.OwnerID = p.OwnerID
 			repository.properties[i] = property
+			return repository.withImages(property), nil
+		}
+	}
+	return domain.Property{}, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakePropertyRepository) PatchProperty(ctx context.Context, id int64, property domain.Property, fields []string) (domain.Property, error) {
+	for i, p := range repository.properties {
+		if p.ID != id || p.DeletedAt != nil {
+			continue
+		}
+		if property.Version != 0 && p.Version != property.Version {
+			return domain.Property{}, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+		}
+		for _, field := range fields {
+			switch field {
+			case "location":
+				p.Location = property.Location
+			case "city":
+				p.City = property.City
+			case "district":
+				p.District = property.District
+			case "neighborhood":
+				p.Neighborhood = property.Neighborhood
+			case "postal_code":
+				p.PostalCode = property.PostalCode
+			case "latitude":
+				p.Latitude = property.Latitude
+			case "longitude":
+				p.Longitude = property.Longitude
+			case "price":
+				p.Price = property.Price
+			case "title":
+				p.Title = property.Title
+			case "description":
+				p.Description = property.Description
+			case "bedrooms":
+				p.Bedrooms = property.Bedrooms
+			case "bathrooms":
+				p.Bathrooms = property.Bathrooms
+			case "square_feet":
+				p.SquareFeet = property.SquareFeet
+			case "agent_id":
+				p.AgentID = property.AgentID
+			default:
+				return domain.Property{}, fmt.Errorf("field %s cannot be patched", field)
+			}
+		}
+		p.Version++
+		repository.properties[i] = p
+		return repository.withImages(p), nil
+	}
+	return domain.Property{}, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+}
+
+// Search matches properties containing every query term in their title, location or description
+func (repository *FakePropertyRepository) Search(ctx context.Context, query string, page int, pageSize int) (domain.PropertySearchPage, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	var results []domain.PropertySearchResult
+	for _, property := range repository.properties {
+		if property.DeletedAt != nil || !hasStatus(property, domain.ListedStatuses) {
+			continue
+		}
+		rank := 0.0
+		matchedAll := true
+		for _, term := range terms {
+			weight := 0.0
+			if containsFold(property.Title, term) {
+				weight += 1.0
+			}
+			if containsFold(property.Location, term) {
+				weight += 0.4
+			}
+			if containsFold(property.Description, term) {
+				weight += 0.2
+			}
+			if weight == 0 {
+				matchedAll = false
+				break
+			}
+			rank += weight
+		}
+		if !matchedAll || len(terms) == 0 {
+			continue
+		}
+		results = append(results, domain.PropertySearchResult{
+			Property: property,
+			Rank:     rank,
+			Highlights: map[string]string{
+				"title":       highlight(property.Title, terms),
+				"description": highlight(property.Description, terms),
+				"location":    highlight(property.Location, terms),
+			},
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+
+	searchPage := domain.PropertySearchPage{Query: query, TotalCount: int64(len(results)), Page: page, PageSize: pageSize}
+	start := (page - 1) * pageSize
+	if start >= len(results) {
+		return searchPage, nil
+	}
+	end := start + pageSize
+	if end > len(results) {
+		end = len(results)
+	}
+	searchPage.Results = results[start:end]
+	return searchPage, nil
+}
+
+// TransitionProperty changes the status of a property still in change.FromStatus and records the change
+func (repository *FakePropertyRepository) TransitionProperty(ctx context.Context, id int64, change domain.StatusChange, version int64) (domain.Property, error) {
+	property, err := repository.property(id)
+	if err != nil {
+		return domain.Property{}, err
+	}
+	if version != 0 && property.Version != version {
+		return domain.Property{}, fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+	}
+	if property.Status != change.FromStatus {
+		return domain.Property{}, fmt.Errorf("property %d is no longer %s: %w", id, change.FromStatus, domain.ErrConflict)
+	}
+	change.ID = int64(len(repository.statusChanges) + 1)
+	change.PropertyID = id
+	change.ChangedAt = time.Now()
+	repository.statusChanges = append(repository.statusChanges, change)
+	property.Status = change.ToStatus
+	property.StatusChangedAt = change.ChangedAt
+	property.Version++
+	return repository.withImages(*property), nil
+}
+
+func (repository *FakePropertyRepository) GetStatusChanges(ctx context.Context, id int64) ([]domain.StatusChange, error) {
+	changes := []domain.StatusChange{}
+	for _, change := range repository.statusChanges {
+		if change.PropertyID == id {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// GetDeletedProperties pages through the deleted properties, most recently deleted first
+func (repository *FakePropertyRepository) GetDeletedProperties(ctx context.Context, page int, pageSize int) (domain.PropertyPage, error) {
+	var deleted []domain.Property
+	for _, property := range repository.properties {
+		if property.DeletedAt != nil {
+			deleted = append(deleted, repository.withImages(property))
+		}
+	}
+	sort.SliceStable(deleted, func(i, j int) bool {
+		return deleted[i].DeletedAt.After(*deleted[j].DeletedAt)
+	})
+	start := min((page-1)*pageSize, len(deleted))
+	return domain.PropertyPage{
+		Properties: deleted[start:min(start+pageSize, len(deleted))],
+		TotalCount: int64(len(deleted)),
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+func (repository *FakePropertyRepository) RestoreProperty(ctx context.Context, id int64) (domain.Property, error) {
+	for i, property := range repository.properties {
+		if property.ID == id && property.DeletedAt != nil {
+			repository.properties[i].DeletedAt = nil
+			repository.properties[i].Version++
+			return repository.withImages(repository.properties[i]), nil
+		}
+	}
+	return domain.Property{}, fmt.Errorf("deleted property %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakePropertyRepository) PurgeDeletedProperties(ctx context.Context, deletedBefore time.Time) (int64, []domain.PropertyImage, error) {
+	var kept []domain.Property
+	images := []domain.PropertyImage{}
+	for _, property := range repository.properties {
+		if property.DeletedAt == nil || !property.DeletedAt.Before(deletedBefore) {
+			kept = append(kept, property)
+		} else {
+			images = append(images, property.Images...)
+		}
+	}
+	purged := int64(len(repository.properties) - len(kept))
+	repository.properties = kept
+	return purged, images, nil
+}
+
+func (repository *FakePropertyRepository) TouchProperty(ctx context.Context, id int64, version int64) error {
+	property, err := repository.property(id)
+	if err != nil {
+		return err
+	}
+	if version != 0 && property.Version != version {
+		return fmt.Errorf("property %d has been modified: %w", id, domain.ErrPreconditionFailed)
+	}
+	property.Version++
+	return nil
+}
+
+func (repository *FakePropertyRepository) GetImages(ctx context.Context, propertyID int64) ([]domain.PropertyImage, error) {
+	property, err := repository.property(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return repository.withImages(*property).Images, nil
+}
+
+func (repository *FakePropertyRepository) AddImage(ctx context.Context, propertyID int64, image domain.PropertyImage) (domain.PropertyImage, error) {
+	property, err := repository.property(propertyID)
+	if err != nil {
+		return domain.PropertyImage{}, err
+	}
+	repository.lastImageID++
+	image.ID = repository.lastImageID
+	property.Images = append(property.Images, image)
+	repository.sortImages(property)
+	return image, nil
+}
+
+func (repository *FakePropertyRepository) UpdateImage(ctx context.Context, propertyID int64, image domain.PropertyImage) error {
+	property, err := repository.property(propertyID)
+	if err != nil {
+		return err
+	}
+	for i, stored := range property.Images {
+		if stored.ID == image.ID {
+			stored.Alt, stored.Caption, stored.Position, stored.IsCover = image.Alt, image.Caption, image.Position, image.IsCover
+			property.Images[i] = stored
+			repository.sortImages(property)
 			return nil
 		}
 	}
+	return fmt.Errorf("image %d of property %d: %w", image.ID, propertyID, domain.ErrNotFound)
+}
+
+func (repository *FakePropertyRepository) DeleteImage(ctx context.Context, propertyID int64, imageID int64) (bool, error) {
+	property, err := repository.property(propertyID)
+	if err != nil {
+		return false, err
+	}
+	for i, stored := range property.Images {
+		if stored.ID == imageID {
+			property.Images = append(property.Images[:i:i], property.Images[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (repository *FakePropertyRepository) property(id int64) (*domain.Property, error) {
+	for i := range repository.properties {
+		if repository.properties[i].ID == id && repository.properties[i].DeletedAt == nil {
+			return &repository.properties[i], nil
+		}
+	}
+	return nil, fmt.Errorf("property %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakePropertyRepository) sortImages(property *domain.Property) {
+	sort.SliceStable(property.Images, func(i, j int) bool {
+		if property.Images[i].Position == property.Images[j].Position {
+			return property.Images[i].ID < property.Images[j].ID
+		}
+		return property.Images[i].Position < property.Images[j].Position
+	})
+}
+
+func highlight(value string, terms []string) string {
+	for _, term := range terms {
+		index := strings.Index(strings.ToLower(value), term)
+		if index >= 0 {
+			value = value[:index] + "<mark>" + value[index:index+len(term)] + "</mark>" + value[index+len(term):]
+		}
+	}
+	return value
+}
+
+// fakePropertyRows iterates over properties already in memory
+type fakePropertyRows struct {
+	properties []domain.Property
+	index      int
+}
+
+func (rows *fakePropertyRows) Next() bool {
+	rows.index++
+	return rows.index < len(rows.properties)
+}
+
+func (rows *fakePropertyRows) Property() (domain.Property, error) {
+	return rows.properties[rows.index], nil
+}
+
+func (rows *fakePropertyRows) Err() error {
 	return nil
 }
+
+func (rows *fakePropertyRows) Close() {}
+
+func matchesCriteria(property domain.Property, criteria domain.PropertyCriteria) bool {
+	if property.DeletedAt != nil {
+		return false
+	}
+	if criteria.MinPrice != nil && property.Price < *criteria.MinPrice {
+		return false
+	}
+	if criteria.MaxPrice != nil && property.Price > *criteria.MaxPrice {
+		return false
+	}
+	if criteria.MinBedrooms != nil && property.Bedrooms < *criteria.MinBedrooms {
+		return false
+	}
+	if criteria.MinBathrooms != nil && property.Bathrooms < *criteria.MinBathrooms {
+		return false
+	}
+	if criteria.MinSquareFeet != nil && property.SquareFeet < *criteria.MinSquareFeet {
+		return false
+	}
+	if criteria.MaxSquareFeet != nil && property.SquareFeet > *criteria.MaxSquareFeet {
+		return false
+	}
+	if criteria.Location != "" && !containsFold(property.Location, criteria.Location) {
+		return false
+	}
+	if criteria.AgentID != nil && (property.AgentID == nil || *property.AgentID != *criteria.AgentID) {
+		return false
+	}
+	if criteria.AgentName != "" && !containsFold(property.AgentName, criteria.AgentName) {
+		return false
+	}
+	if criteria.City != "" && !strings.EqualFold(property.City, criteria.City) {
+		return false
+	}
+	if len(criteria.Statuses) > 0 && !hasStatus(property, criteria.Statuses) {
+		return false
+	}
+	if criteria.HasCoordinates || criteria.Within != nil || criteria.Near != nil {
+		if property.Latitude == nil || property.Longitude == nil {
+			return false
+		}
+		point := domain.GeoPoint{Latitude: *property.Latitude, Longitude: *property.Longitude}
+		if criteria.Within != nil && !criteria.Within.Contains(point) {
+			return false
+		}
+		if criteria.Near != nil && domain.DistanceKm(criteria.Near.Center, point) > criteria.Near.RadiusKm {
+			return false
+		}
+	}
+	return true
+}
+
+func hasStatus(property domain.Property, statuses []domain.PropertyStatus) bool {
+	for _, status := range statuses {
+		if property.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(value string, term string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(term))
+}
+
+// compareProperties reports whether a sorts before b on field and whether they are equal on it
+func compareProperties(a domain.Property, b domain.Property, field string) (bool, bool) {
+	switch field {
+	case domain.SortByPrice:
+		return a.Price < b.Price, a.Price == b.Price
+	case domain.SortByBedrooms:
+		return a.Bedrooms < b.Bedrooms, a.Bedrooms == b.Bedrooms
+	case domain.SortByBathrooms:
+		return a.Bathrooms < b.Bathrooms, a.Bathrooms == b.Bathrooms
+	case domain.SortBySquareFeet:
+		return a.SquareFeet < b.SquareFeet, a.SquareFeet == b.SquareFeet
+	case domain.SortByTitle:
+		return a.Title < b.Title, a.Title == b.Title
+	case domain.SortByLocation:
+		return a.Location < b.Location, a.Location == b.Location
+	}
+	return a.ID < b.ID, a.ID == b.ID
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/domain"
+)
+
+type FakeTenantRepository struct {
+	tenants []domain.Tenant
+}
+
+func NewFakeTenantRepository(tenants []domain.Tenant) *FakeTenantRepository {
+	return &FakeTenantRepository{tenants: tenants}
+}
+
+func (repository *FakeTenantRepository) AddTenant(ctx context.Context, tenant domain.Tenant) (domain.Tenant, error) {
+	for _, t := range repository.tenants {
+		if t.Slug == tenant.Slug {
+			return domain.Tenant{}, fmt.Errorf("tenant %q: %w", tenant.Slug, domain.ErrConflict)
+		}
+		for _, host := range t.Hosts {
+			for _, newHost := range tenant.Hosts {
+				if host == newHost {
+					return domain.Tenant{}, fmt.Errorf("host %q: %w", host, domain.ErrConflict)
+				}
+			}
+		}
+	}
+	tenant.ID = int64(len(repository.tenants) + 1)
+	repository.tenants = append(repository.tenants, tenant)
+	return tenant, nil
+}
+
+func (repository *FakeTenantRepository) GetTenants(ctx context.Context) ([]domain.Tenant, error) {
+	return append([]domain.Tenant{}, repository.tenants...), nil
+}
+
+func (repository *FakeTenantRepository) GetTenantById(ctx context.Context, id int64) (domain.Tenant, error) {
+	for _, tenant := range repository.tenants {
+		if tenant.ID == id {
+			return tenant, nil
+		}
+	}
+	return domain.Tenant{}, fmt.Errorf("tenant %d: %w", id, domain.ErrNotFound)
+}
+
+func (repository *FakeTenantRepository) GetTenantBySlug(ctx context.Context, slug string) (domain.Tenant, error) {
+	for _, tenant := range repository.tenants {
+		if tenant.Slug == slug {
+			return tenant, nil
+		}
+	}
+	return domain.Tenant{}, fmt.Errorf("tenant %q: %w", slug, domain.ErrNotFound)
+}
+
+func (repository *FakeTenantRepository) GetTenantByHost(ctx context.Context, host string) (domain.Tenant, error) {
+	for _, tenant := range repository.tenants {
+		for _, h := range tenant.Hosts {
+			if h == host {
+				return tenant, nil
+			}
+		}
+	}
+	return domain.Tenant{}, fmt.Errorf("tenant of host %q: %w", host, domain.ErrNotFound)
+}
@@ -0,0 +1,10 @@
+package service
+
+import "context"
+
+// FakeTransactionManager runs the unit of work without a transaction, the fake repositories apply changes immediately
+type FakeTransactionManager struct{}
+
+func (FakeTransactionManager) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
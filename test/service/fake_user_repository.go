@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"kirmac-site-backend/domain"
+	"time"
+)
+
+type FakeUserRepository struct {
+	users []domain.User
+}
+
+func NewFakeUserRepository() *FakeUserRepository {
+	return &FakeUserRepository{}
+}
+
+func (repository *FakeUserRepository) AddUser(ctx context.Context, user domain.User) (domain.User, error) {
+	for _, u := range repository.users {
+		if u.Username == user.Username {
+			return domain.User{}, fmt.Errorf("user %q: %w", user.Username, domain.ErrConflict)
+		}
+	}
+	user.ID = int64(len(repository.users) + 1)
+	user.CreatedAt = time.Now()
+	repository.users = append(repository.users, user)
+	return user, nil
+}
+
+func (repository *FakeUserRepository) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
+	for _, user := range repository.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return domain.User{}, fmt.Errorf("user %q: %w", username, domain.ErrNotFound)
+}
+
+func (repository *FakeUserRepository) GetUserById(ctx context.Context, id int64) (domain.User, error) {
+	for _, user := range repository.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return domain.User{}, fmt.Errorf("user %d: %w", id, domain.ErrNotFound)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"testing"
+)
+
+func coordinates(latitude float64, longitude float64) (*float64, *float64) {
+	return &latitude, &longitude
+}
+
+func geoProperty(id int64, city string, latitude float64, longitude float64) domain.Property {
+	property := domain.Property{ID: id, Location: city + ", Turkey", City: city, Price: 1000000, Title: "Property in " + city}
+	property.Latitude, property.Longitude = coordinates(latitude, longitude)
+	return property
+}
+
+// TestGeoSearch tests the radius and bounding box searches of the PropertyService
+func TestGeoSearch(t *testing.T) {
+	geoService := services.NewPropertyService(NewFakePropertyRepository([]domain.Property{
+		geoProperty(1, "Antalya", 36.8841, 30.7056),
+		geoProperty(2, "Antalya", 36.8569, 30.7925),
+		geoProperty(3, "Kemer", 36.5978, 30.5606),
+		geoProperty(4, "Istanbul", 41.0082, 28.9784),
+		{ID: 5, Location: "Antalya, Turkey", City: "Antalya", Price: 900000, Title: "Unmapped Flat"},
+		geoProperty(6, "Taveuni", -16.8, 179.9),
+	}), NewFakeAuditRepository(), FakeTransactionManager{})
+	antalya := domain.GeoPoint{Latitude: 36.8841, Longitude: 30.7056}
+
+	t.Run("NearestFirst", func(t *testing.T) {
+		page, err := geoService.GetNearbyProperties(ctx, domain.PropertyCriteria{Near: &domain.GeoRadius{Center: antalya, RadiusKm: 50}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), page.TotalCount)
+		assert.Equal(t, []int64{1, 2, 3}, []int64{page.Results[0].Property.ID, page.Results[1].Property.ID, page.Results[2].Property.ID})
+		assert.InDelta(t, 0, page.Results[0].DistanceKm, 0.001)
+		assert.InDelta(t, 8.2, page.Results[1].DistanceKm, 0.2)
+	})
+	t.Run("RadiusExcludesFartherProperties", func(t *testing.T) {
+		page, err := geoService.GetNearbyProperties(ctx, domain.PropertyCriteria{Near: &domain.GeoRadius{Center: antalya, RadiusKm: 10}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), page.TotalCount)
+	})
+	t.Run("RejectInvalidRadius", func(t *testing.T) {
+		_, err := geoService.GetNearbyProperties(ctx, domain.PropertyCriteria{Near: &domain.GeoRadius{Center: domain.GeoPoint{Latitude: 95}, RadiusKm: 0}})
+		var validationError *domain.ValidationError
+		assert.ErrorAs(t, err, &validationError)
+		assert.Equal(t, 2, len(validationError.Fields))
+	})
+	t.Run("WithinBoundingBox", func(t *testing.T) {
+		page, err := geoService.GetAllProperties(ctx, domain.PropertyCriteria{Within: &domain.BoundingBox{MinLatitude: 36.5, MinLongitude: 30.6, MaxLatitude: 37, MaxLongitude: 31}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), page.TotalCount)
+	})
+	t.Run("BoundingBoxAcrossAntimeridian", func(t *testing.T) {
+		page, err := geoService.GetAllProperties(ctx, domain.PropertyCriteria{Within: &domain.BoundingBox{MinLatitude: -20, MinLongitude: 179, MaxLatitude: -10, MaxLongitude: -179}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+		assert.Equal(t, int64(6), page.Properties[0].ID)
+	})
+	t.Run("RadiusAcrossAntimeridian", func(t *testing.T) {
+		page, err := geoService.GetNearbyProperties(ctx, domain.PropertyCriteria{Near: &domain.GeoRadius{Center: domain.GeoPoint{Latitude: -16.8, Longitude: -179.9}, RadiusKm: 30}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+	})
+	t.Run("FilterByCity", func(t *testing.T) {
+		page, err := geoService.GetAllProperties(ctx, domain.PropertyCriteria{City: "antalya"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), page.TotalCount)
+	})
+}
+
+func exportGeoJSON(t *testing.T, export services.GeoJSONExport, err error) map[string]interface{} {
+	assert.NoError(t, err)
+	var buffer bytes.Buffer
+	assert.NoError(t, export(&buffer))
+	var collection map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &collection))
+	return collection
+}
+
+// TestGeoJSONExport tests the point and clustered GeoJSON exports of the PropertyService
+func TestGeoJSONExport(t *testing.T) {
+	covered := geoProperty(2, "Antalya", 36.8569, 30.7925)
+	covered.Price = 2500000
+	covered.Images = []domain.PropertyImage{
+		{ID: 1, URL: "https://cdn.example.com/2/side.jpg"},
+		{ID: 2, URL: "https://cdn.example.com/2/front.jpg", IsCover: true, Variants: map[string]domain.ImageVariant{
+			"thumbnail": {URL: "https://cdn.example.com/2/front-thumbnail.jpg", Width: 320, Height: 240},
+		}},
+	}
+	exportService := services.NewPropertyService(NewFakePropertyRepository([]domain.Property{
+		geoProperty(1, "Antalya", 36.8841, 30.7056),
+		covered,
+		geoProperty(3, "Istanbul", 41.0082, 28.9784),
+		{ID: 4, Location: "Antalya, Turkey", City: "Antalya", Price: 900000, Title: "Unmapped Flat"},
+	}), NewFakeAuditRepository(), FakeTransactionManager{})
+
+	t.Run("PointsHonorFilters", func(t *testing.T) {
+		export, err := exportService.ExportGeoJSON(ctx, domain.PropertyCriteria{City: "Antalya", SortField: domain.SortByPrice, SortDirection: domain.SortDescending})
+		collection := exportGeoJSON(t, export, err)
+		assert.Equal(t, "FeatureCollection", collection["type"])
+		features := collection["features"].([]interface{})
+		assert.Equal(t, 2, len(features))
+		first := features[0].(map[string]interface{})
+		assert.Equal(t, float64(2), first["id"])
+		assert.Equal(t, map[string]interface{}{"type": "Point", "coordinates": []interface{}{30.7925, 36.8569}}, first["geometry"])
+		properties := first["properties"].(map[string]interface{})
+		assert.Equal(t, "https://cdn.example.com/2/front-thumbnail.jpg", properties["cover_image"])
+		assert.Equal(t, float64(2500000), properties["price"])
+		assert.NotContains(t, features[1].(map[string]interface{})["properties"], "cover_image")
+	})
+	t.Run("Clusters", func(t *testing.T) {
+		export, err := exportService.ExportGeoJSONClusters(ctx, domain.PropertyCriteria{}, 5)
+		collection := exportGeoJSON(t, export, err)
+		assert.Equal(t, float64(5), collection["zoom"])
+		features := collection["features"].([]interface{})
+		assert.Equal(t, 2, len(features))
+		antalya := features[0].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"cluster": true, "point_count": float64(2), "min_price": float64(1000000), "max_price": float64(2500000)}, antalya["properties"])
+		center := antalya["geometry"].(map[string]interface{})["coordinates"].([]interface{})
+		assert.InDelta(t, 30.74905, center[0], 0.0001)
+		assert.InDelta(t, 36.8705, center[1], 0.0001)
+	})
+	t.Run("ClustersSplitWhenZoomedIn", func(t *testing.T) {
+		export, err := exportService.ExportGeoJSONClusters(ctx, domain.PropertyCriteria{City: "Antalya"}, 12)
+		collection := exportGeoJSON(t, export, err)
+		assert.Equal(t, 2, len(collection["features"].([]interface{})))
+	})
+	t.Run("RejectInvalidZoom", func(t *testing.T) {
+		_, err := exportService.ExportGeoJSONClusters(ctx, domain.PropertyCriteria{}, 21)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+// TestGeoRadiusBounds tests that the bounding box of a radius holds the whole circle
+func TestGeoRadiusBounds(t *testing.T) {
+	bounds := domain.GeoRadius{Center: domain.GeoPoint{Latitude: 36.8841, Longitude: 30.7056}, RadiusKm: 100}.Bounds()
+	assert.InDelta(t, 0.9, bounds.MaxLatitude-36.8841, 0.01)
+	assert.InDelta(t, 1.12, bounds.MaxLongitude-30.7056, 0.01)
+
+	wrapped := domain.GeoRadius{Center: domain.GeoPoint{Latitude: 0, Longitude: 179.9}, RadiusKm: 100}.Bounds()
+	assert.True(t, wrapped.CrossesAntimeridian())
+
+	polar := domain.GeoRadius{Center: domain.GeoPoint{Latitude: 89.9, Longitude: 0}, RadiusKm: 100}.Bounds()
+	assert.Equal(t, domain.BoundingBox{MinLatitude: polar.MinLatitude, MinLongitude: -180, MaxLatitude: 90, MaxLongitude: 180}, polar)
+}
+
+// TestAddressValidation tests the validation of the address and coordinates of a property
+func TestAddressValidation(t *testing.T) {
+	latitude, _ := coordinates(91, 0)
+	_, err := propertyService.AddProperty(ctx, model.PropertyCreate{
+		Location:   "Izmir, Turkey",
+		City:       "Izmir",
+		PostalCode: "35<script>",
+		Latitude:   latitude,
+		Price:      750000,
+		Title:      "Alsancak Flat",
+	})
+	var validationError *domain.ValidationError
+	assert.ErrorAs(t, err, &validationError)
+	var fields []string
+	for _, fieldError := range validationError.Fields {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"postal_code", "latitude"}, fields)
+}
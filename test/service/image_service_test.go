@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"io"
+	commonimaging "kirmac-site-backend/common/imaging"
+	"kirmac-site-backend/common/storage"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"kirmac-site-backend/test/imaging"
+	"strings"
+	"testing"
+)
+
+func pngImage(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	assert.NoError(t, png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, 4, 3))))
+	return buffer.Bytes()
+}
+
+// TestImageService tests uploading, adding, editing, ordering and deleting the images of a property
+func TestImageService(t *testing.T) {
+	localStorage, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/images")
+	assert.NoError(t, err)
+	repository := NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Ankara, Turkey", Price: 800000, Title: "Modern City Apartment", Version: 1,
+			Images: []domain.PropertyImage{{ID: 100, URL: "https://example.com/ankara_apt1.jpg", IsCover: true}}},
+	})
+	imageService := services.NewImageService(repository, repository, NewFakeAuditRepository(), FakeTransactionManager{}, localStorage, 1024)
+
+	uploaded, err := imageService.UploadImage(ctx, 1, bytes.NewReader(pngImage(t)), model.ImageCreate{Alt: "Living room with city views", IsCover: true}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), uploaded.Version)
+	assert.Equal(t, 2, len(uploaded.Images))
+	cover := uploaded.Images[0]
+	coverURL := cover.URL
+	assert.True(t, strings.HasPrefix(coverURL, "http://localhost:8080/images/properties/1/"))
+	assert.True(t, strings.HasSuffix(coverURL, ".png"))
+	assert.Equal(t, domain.PropertyImage{
+		ID:       cover.ID,
+		URL:      coverURL,
+		Alt:      "Living room with city views",
+		Position: 0,
+		Width:    4,
+		Height:   3,
+		IsCover:  true,
+		Variants: map[string]domain.ImageVariant{
+			"thumbnail": {URL: strings.TrimSuffix(coverURL, ".png") + "_thumbnail.png", Width: 4, Height: 3},
+			"card":      {URL: strings.TrimSuffix(coverURL, ".png") + "_card.png", Width: 4, Height: 3},
+			"full":      {URL: strings.TrimSuffix(coverURL, ".png") + "_full.png", Width: 4, Height: 3},
+		},
+	}, cover)
+	assert.Equal(t, domain.PropertyImage{ID: 100, URL: "https://example.com/ankara_apt1.jpg", Position: 1}, uploaded.Images[1])
+
+	t.Run("ServeStoredImage", func(t *testing.T) {
+		object, err := imageService.OpenImage(ctx, strings.TrimPrefix(coverURL, "http://localhost:8080/images/"))
+		assert.NoError(t, err)
+		defer object.Body.Close()
+		content, _ := io.ReadAll(object.Body)
+		decoded, err := png.Decode(bytes.NewReader(content))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 4, 3), decoded.Bounds())
+		assert.Equal(t, "image/png", object.ContentType)
+	})
+	t.Run("RejectNonImages", func(t *testing.T) {
+		_, err := imageService.UploadImage(ctx, 1, strings.NewReader("<html><script>alert(1)</script></html>"), model.ImageCreate{}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+	t.Run("RejectLargeImages", func(t *testing.T) {
+		large := append(pngImage(t), make([]byte, 1024)...)
+		_, err := imageService.UploadImage(ctx, 1, bytes.NewReader(large), model.ImageCreate{}, 0)
+		assert.ErrorIs(t, err, domain.ErrTooLarge)
+	})
+	t.Run("StaleVersion", func(t *testing.T) {
+		_, err := imageService.UploadImage(ctx, 1, bytes.NewReader(pngImage(t)), model.ImageCreate{}, 1)
+		assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+	})
+	var linked domain.PropertyImage
+	t.Run("AddByURL", func(t *testing.T) {
+		_, err := imageService.AddImage(ctx, 1, model.ImageCreate{URL: "ftp://example.com/ankara_apt2.jpg"}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+
+		added, err := imageService.AddImage(ctx, 1, model.ImageCreate{URL: "https://example.com/ankara_apt2.jpg", Caption: "Kitchen"}, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), added.Version)
+		linked = added.Images[2]
+		assert.Equal(t, "https://example.com/ankara_apt2.jpg", linked.URL)
+		assert.Equal(t, "Kitchen", linked.Caption)
+		assert.Equal(t, 2, linked.Position)
+		assert.False(t, linked.IsCover)
+	})
+	t.Run("Update", func(t *testing.T) {
+		alt, position := "Balcony", 0
+		updated, err := imageService.UpdateImage(ctx, 1, 100, model.ImageUpdate{Alt: &alt, Position: &position}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{100, cover.ID, linked.ID}, imageIDs(updated.Images))
+		assert.Equal(t, "Balcony", updated.Images[0].Alt)
+		assert.True(t, updated.Images[1].IsCover, "moving an image keeps the cover")
+
+		isCover := true
+		updated, err = imageService.UpdateImage(ctx, 1, linked.ID, model.ImageUpdate{IsCover: &isCover}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []bool{false, false, true}, []bool{updated.Images[0].IsCover, updated.Images[1].IsCover, updated.Images[2].IsCover})
+
+		isCover = false
+		_, err = imageService.UpdateImage(ctx, 1, linked.ID, model.ImageUpdate{IsCover: &isCover}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		position = 3
+		_, err = imageService.UpdateImage(ctx, 1, linked.ID, model.ImageUpdate{Position: &position}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		_, err = imageService.UpdateImage(ctx, 1, 999, model.ImageUpdate{Alt: &alt}, 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+	t.Run("Reorder", func(t *testing.T) {
+		_, err := imageService.ReorderImages(ctx, 1, []int64{100, cover.ID}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		_, err = imageService.ReorderImages(ctx, 1, []int64{100, 100, cover.ID}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+
+		reordered, err := imageService.ReorderImages(ctx, 1, []int64{cover.ID, linked.ID, 100}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{cover.ID, linked.ID, 100}, imageIDs(reordered.Images))
+		assert.Equal(t, []int{0, 1, 2}, []int{reordered.Images[0].Position, reordered.Images[1].Position, reordered.Images[2].Position})
+		assert.True(t, reordered.Images[1].IsCover, "reordering keeps the cover")
+	})
+	t.Run("Delete", func(t *testing.T) {
+		thumbnailKey := strings.TrimPrefix(strings.TrimSuffix(coverURL, ".png")+"_thumbnail.png", "http://localhost:8080/images/")
+		deleted, err := imageService.DeleteImage(ctx, 1, cover.ID, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{linked.ID, 100}, imageIDs(deleted.Images))
+		_, err = imageService.OpenImage(ctx, strings.TrimPrefix(coverURL, "http://localhost:8080/images/"))
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = imageService.OpenImage(ctx, thumbnailKey)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		deleted, err = imageService.DeleteImage(ctx, 1, linked.ID, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.PropertyImage{{ID: 100, URL: "https://example.com/ankara_apt1.jpg", Alt: "Balcony", Position: 0, IsCover: true}}, deleted.Images)
+
+		_, err = imageService.DeleteImage(ctx, 1, linked.ID, 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func imageIDs(images []domain.PropertyImage) []int64 {
+	var ids []int64
+	for _, image := range images {
+		ids = append(ids, image.ID)
+	}
+	return ids
+}
+
+// TestImageVariants tests that uploads are turned upright, stripped of metadata and resized
+func TestImageVariants(t *testing.T) {
+	localStorage, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/images")
+	assert.NoError(t, err)
+	repository := NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Fethiye, Turkey", Price: 1300000, Title: "Lagoon View Villa", Version: 1},
+	})
+	imageService := services.NewImageService(repository, repository, NewFakeAuditRepository(), FakeTransactionManager{}, localStorage, 1<<20)
+
+	// a 1000x500 photo taken sideways, shown as 500x1000 once turned upright
+	property, err := imageService.UploadImage(ctx, 1, bytes.NewReader(imaging.JPEGWithOrientation(t, 1000, 500, 6)), model.ImageCreate{}, 0)
+	assert.NoError(t, err)
+	uploaded := property.Images[0]
+	assert.Equal(t, 500, uploaded.Width)
+	assert.Equal(t, 1000, uploaded.Height)
+	sizes := map[string][2]int{}
+	for name, variant := range uploaded.Variants {
+		sizes[name] = [2]int{variant.Width, variant.Height}
+	}
+	assert.Equal(t, map[string][2]int{"thumbnail": {160, 320}, "card": {300, 600}, "full": {500, 1000}}, sizes)
+
+	object, err := imageService.OpenImage(ctx, strings.TrimPrefix(uploaded.URL, "http://localhost:8080/images/"))
+	assert.NoError(t, err)
+	defer object.Body.Close()
+	content, _ := io.ReadAll(object.Body)
+	assert.False(t, bytes.Contains(content, []byte("Exif")), "metadata is stripped")
+	assert.Equal(t, 1, commonimaging.Orientation(content))
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"testing"
+)
+
+// TestAuthorizationPolicy tests what each role may change through the PropertyService
+func TestAuthorizationPolicy(t *testing.T) {
+	ownerID, otherID := int64(7), int64(8)
+	policyService := services.NewPropertyService(NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse", Version: 1, OwnerID: &ownerID},
+		{ID: 2, Location: "Bodrum, Turkey", Price: 3500000, Title: "Beach Villa", Version: 1, OwnerID: &otherID},
+	}), NewFakeAuditRepository(), FakeTransactionManager{})
+	agentCtx := requestctx.WithPrincipal(context.Background(), domain.Principal{Name: "ayse.kaya", UserID: ownerID, Role: domain.RoleAgent})
+	viewerCtx := requestctx.WithPrincipal(context.Background(), domain.Principal{Name: "mehmet.demir", UserID: 9, Role: domain.RoleViewer})
+	price := model.PropertyPatch{Type: model.MergePatch, Document: []byte(`{"price": 1750000}`)}
+
+	t.Run("AgentChangesOwnListing", func(t *testing.T) {
+		property, err := policyService.PatchProperty(agentCtx, 1, price, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1750000, property.Price)
+		assert.Equal(t, &ownerID, property.OwnerID)
+	})
+	t.Run("AgentCannotChangeOtherListings", func(t *testing.T) {
+		_, err := policyService.PatchProperty(agentCtx, 2, price, 0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.EqualError(t, err, "forbidden: agent users can only change listings they own")
+		_, err = policyService.DeleteById(agentCtx, 2, 0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = policyService.TransitionProperty(agentCtx, 2, model.StatusTransition{Status: domain.StatusSold}, 0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = policyService.GetPropertyHistory(agentCtx, 1, 1, 10)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = policyService.GetDeletedProperties(agentCtx, 1, 10)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+	t.Run("AgentOwnsCreatedListings", func(t *testing.T) {
+		property, err := policyService.AddProperty(agentCtx, model.PropertyCreate{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio"})
+		assert.NoError(t, err)
+		assert.Equal(t, &ownerID, property.OwnerID)
+	})
+	t.Run("ViewerCannotChangeAnything", func(t *testing.T) {
+		_, err := policyService.AddProperty(viewerCtx, model.PropertyCreate{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio"})
+		assert.EqualError(t, err, "forbidden: viewer users cannot create listings")
+		_, err = policyService.PatchProperty(viewerCtx, 1, price, 0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+	t.Run("UnlistedOnlyForStaff", func(t *testing.T) {
+		draft, err := policyService.AddProperty(agentCtx, model.PropertyCreate{Location: "Datca, Turkey", Price: 1100000, Title: "Olive Grove House", Status: domain.StatusDraft})
+		assert.NoError(t, err)
+		drafts := domain.PropertyCriteria{Statuses: []domain.PropertyStatus{domain.StatusDraft}}
+
+		_, err = policyService.GetPropertyById(agentCtx, draft.ID)
+		assert.NoError(t, err)
+		page, err := policyService.GetAllProperties(agentCtx, drafts)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+
+		_, err = policyService.GetPropertyById(viewerCtx, draft.ID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = policyService.GetStatusHistory(context.Background(), draft.ID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = policyService.GetAllProperties(viewerCtx, drafts)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = policyService.GetAllProperties(context.Background(), drafts)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+	t.Run("AnonymousIsUnauthorized", func(t *testing.T) {
+		_, err := policyService.PatchProperty(context.Background(), 1, price, 0)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+	t.Run("AdminChangesAnyListing", func(t *testing.T) {
+		property, err := policyService.PatchProperty(ctx, 2, price, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &otherID, property.OwnerID)
+	})
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"testing"
+)
+
+// TestAuditLog tests that the changes made through the PropertyService are recorded with their actor, request and field diff
+func TestAuditLog(t *testing.T) {
+	auditService := services.NewPropertyService(NewFakePropertyRepository(nil), NewFakeAuditRepository(), FakeTransactionManager{})
+	auditCtx := requestctx.WithRequestID(requestctx.WithPrincipal(ctx, domain.Principal{Name: "ayse.kaya", UserID: 3, Role: domain.RoleAdmin}), "req-1")
+
+	added, err := auditService.AddProperty(auditCtx, model.PropertyCreate{Location: "Izmir, Turkey", Price: 1200000, Title: "Seaside Condo"})
+	assert.NoError(t, err)
+	updated, err := auditService.UpdateProperty(auditCtx, added.ID, model.PropertyCreate{Location: "Izmir, Turkey", Price: 1150000, Title: "Seaside Condo"}, added.Version)
+	assert.NoError(t, err)
+	_, err = auditService.PatchProperty(auditCtx, added.ID, model.PropertyPatch{Type: model.MergePatch, Document: []byte(`{"price": 1150000}`)}, 0)
+	assert.NoError(t, err)
+	_, err = auditService.DeleteById(auditCtx, added.ID, updated.Version)
+	assert.NoError(t, err)
+
+	history, err := auditService.GetPropertyHistory(ctx, added.ID, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), history.TotalCount)
+
+	t.Run("NewestFirst", func(t *testing.T) {
+		var actions []domain.AuditAction
+		for _, entry := range history.Entries {
+			actions = append(actions, entry.Action)
+			assert.Equal(t, added.ID, entry.EntityID)
+			assert.Equal(t, "ayse.kaya", entry.Actor)
+			assert.Equal(t, "req-1", entry.RequestID)
+		}
+		assert.Equal(t, []domain.AuditAction{domain.AuditDelete, domain.AuditUpdate, domain.AuditCreate}, actions)
+	})
+	t.Run("FieldDiff", func(t *testing.T) {
+		assert.Equal(t, []domain.FieldChange{{Field: "price", Before: float64(1200000), After: float64(1150000)}}, history.Entries[1].Changes)
+		assert.Contains(t, history.Entries[2].Changes, domain.FieldChange{Field: "title", Before: nil, After: "Seaside Condo"})
+		assert.Contains(t, history.Entries[0].Changes, domain.FieldChange{Field: "title", Before: "Seaside Condo", After: nil})
+	})
+	t.Run("FailedChangeNotRecorded", func(t *testing.T) {
+		_, err := auditService.RestoreProperty(auditCtx, added.ID+1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = auditService.GetPropertyHistory(ctx, added.ID+1, 1, 10)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+	t.Run("Restore", func(t *testing.T) {
+		_, err := auditService.RestoreProperty(auditCtx, added.ID)
+		assert.NoError(t, err)
+		history, err := auditService.GetPropertyHistory(ctx, added.ID, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), history.TotalCount)
+		assert.Equal(t, domain.AuditRestore, history.Entries[0].Action)
+		changes := history.Entries[0].Changes
+		assert.Len(t, changes, 1)
+		assert.Equal(t, "deleted_at", changes[0].Field)
+		assert.NotNil(t, changes[0].Before)
+		assert.Nil(t, changes[0].After)
+	})
+}
+
+// TestImageAuditLog tests that adding, changing and removing images is recorded like every other change of a property
+func TestImageAuditLog(t *testing.T) {
+	repository := NewFakePropertyRepository([]domain.Property{{ID: 1, Location: "Izmir, Turkey", Price: 1200000, Title: "Seaside Condo", Version: 1}})
+	audit := NewFakeAuditRepository()
+	imageService := services.NewImageService(repository, repository, audit, FakeTransactionManager{}, nil, 1024)
+	auditService := services.NewPropertyService(repository, audit, FakeTransactionManager{})
+	auditCtx := requestctx.WithRequestID(requestctx.WithPrincipal(ctx, domain.Principal{Name: "ayse.kaya", UserID: 3, Role: domain.RoleAdmin}), "req-2")
+
+	added, err := imageService.AddImage(auditCtx, 1, model.ImageCreate{URL: "https://example.com/izmir_condo1.jpg"}, 0)
+	assert.NoError(t, err)
+	alt := "Terrace"
+	_, err = imageService.UpdateImage(auditCtx, 1, added.Images[0].ID, model.ImageUpdate{Alt: &alt}, 0)
+	assert.NoError(t, err)
+	_, err = imageService.DeleteImage(auditCtx, 1, added.Images[0].ID, 0)
+	assert.NoError(t, err)
+
+	history, err := auditService.GetPropertyHistory(ctx, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), history.TotalCount)
+	for _, entry := range history.Entries {
+		assert.Equal(t, domain.AuditUpdate, entry.Action)
+		assert.Equal(t, "req-2", entry.RequestID)
+		assert.Len(t, entry.Changes, 1)
+		assert.Equal(t, "images", entry.Changes[0].Field)
+	}
+	assert.Nil(t, history.Entries[2].Changes[0].Before, "the first image is added to a property without images")
+	assert.Nil(t, history.Entries[0].Changes[0].After, "the last image is deleted")
+}
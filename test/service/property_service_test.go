@@ -1,15 +1,24 @@
 package service
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
 	"kirmac-site-backend/domain"
 	"kirmac-site-backend/services"
 	"kirmac-site-backend/services/model"
+	"os"
 	"testing"
 )
 
 var propertyService services.IPropertyService
 
+// ctx acts as an admin of the default tenant so the tests of the PropertyService are not about permissions
+var ctx = requestctx.WithTenant(
+	requestctx.WithPrincipal(context.Background(), domain.Principal{Name: "admin", UserID: 1, Role: domain.RoleAdmin, TenantID: 1}),
+	domain.Tenant{ID: 1, Slug: "default", Currency: domain.DefaultCurrency},
+)
+
 func TestMain(m *testing.M) {
 	initialProperties := []domain.Property{
 		{
@@ -23,7 +32,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  2200,
 			AgentName:   "Ayse Kaya",
 			AgentTitle:  "Luxury Property Specialist",
-			ImageURLs:   []string{"https://example.com/antalya_penthouse1.jpg", "https://example.com/antalya_penthouse2.jpg", "https://example.com/antalya_penthouse3.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/antalya_penthouse1.jpg"}, {URL: "https://example.com/antalya_penthouse2.jpg"}, {URL: "https://example.com/antalya_penthouse3.jpg"}},
 		},
 		{
 			ID:          4,
@@ -36,7 +45,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  5000,
 			AgentName:   "Mehmet Yilmaz",
 			AgentTitle:  "Luxury Property Consultant",
-			ImageURLs:   []string{"https://example.com/bodrum_villa1.jpg", "https://example.com/bodrum_villa2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/bodrum_villa1.jpg"}, {URL: "https://example.com/bodrum_villa2.jpg"}},
 		},
 		{
 			ID:          5,
@@ -49,7 +58,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  1500,
 			AgentName:   "Zeynep Kaya",
 			AgentTitle:  "City Center Specialist",
-			ImageURLs:   []string{"https://example.com/ankara_apt1.jpg", "https://example.com/ankara_apt2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/ankara_apt1.jpg"}, {URL: "https://example.com/ankara_apt2.jpg"}},
 		},
 		{
 			ID:          6,
@@ -62,7 +71,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  2000,
 			AgentName:   "Can Demir",
 			AgentTitle:  "Izmir Coast Expert",
-			ImageURLs:   []string{"https://example.com/izmir_condo1.jpg", "https://example.com/izmir_condo2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/izmir_condo1.jpg"}, {URL: "https://example.com/izmir_condo2.jpg"}},
 		},
 		{
 			ID:          7,
@@ -75,7 +84,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  1200,
 			AgentName:   "Ayse Yildiz",
 			AgentTitle:  "Cappadocia Property Specialist",
-			ImageURLs:   []string{"https://example.com/cappadocia_cave1.jpg", "https://example.com/cappadocia_cave2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/cappadocia_cave1.jpg"}, {URL: "https://example.com/cappadocia_cave2.jpg"}},
 		},
 		{
 			ID:          10,
@@ -88,7 +97,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  1600,
 			AgentName:   "Emre Sahin",
 			AgentTitle:  "Black Sea Region Specialist",
-			ImageURLs:   []string{"https://example.com/trabzon_apt1.jpg", "https://example.com/trabzon_apt2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/trabzon_apt1.jpg"}, {URL: "https://example.com/trabzon_apt2.jpg"}},
 		},
 		{
 			ID:          11,
@@ -101,7 +110,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  600,
 			AgentName:   "Selin Aydin",
 			AgentTitle:  "Alanya Beach Property Expert",
-			ImageURLs:   []string{"https://example.com/alanya_studio1.jpg", "https://example.com/alanya_studio2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/alanya_studio1.jpg"}, {URL: "https://example.com/alanya_studio2.jpg"}},
 		},
 		{
 			ID:          12,
@@ -114,7 +123,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  900,
 			AgentName:   "Ahmet Celik",
 			AgentTitle:  "Student Housing Specialist",
-			ImageURLs:   []string{"https://example.com/eskisehir_apt1.jpg", "https://example.com/eskisehir_apt2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/eskisehir_apt1.jpg"}, {URL: "https://example.com/eskisehir_apt2.jpg"}},
 		},
 		{
 			ID:          13,
@@ -127,7 +136,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  2800,
 			AgentName:   "Deniz Korkmaz",
 			AgentTitle:  "Cesme Luxury Property Advisor",
-			ImageURLs:   []string{"https://example.com/cesme_house1.jpg", "https://example.com/cesme_house2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/cesme_house1.jpg"}, {URL: "https://example.com/cesme_house2.jpg"}},
 		},
 		{
 			ID:          14,
@@ -140,7 +149,7 @@ func TestMain(m *testing.M) {
 			SquareFeet:  2800,
 			AgentName:   "Deniz Korkmaz",
 			AgentTitle:  "Cesme Luxury Property Advisor",
-			ImageURLs:   []string{"https://example.com/cesme_house1.jpg", "https://example.com/cesme_house2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/cesme_house1.jpg"}, {URL: "https://example.com/cesme_house2.jpg"}},
 		},
 		{
 			ID:          8,
@@ -153,27 +162,28 @@ func TestMain(m *testing.M) {
 			SquareFeet:  1800,
 			AgentName:   "Leyla Ozturk",
 			AgentTitle:  "Historical Property Consultant",
-			ImageURLs:   []string{"https://example.com/bursa_ottoman1.jpg", "https://example.com/bursa_ottoman2.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/bursa_ottoman1.jpg"}, {URL: "https://example.com/bursa_ottoman2.jpg"}},
 		},
 	}
 	fakePropertyRepository := NewFakePropertyRepository(initialProperties)
-	propertyService = services.NewPropertyService(fakePropertyRepository)
+	propertyService = services.NewPropertyService(fakePropertyRepository, NewFakeAuditRepository(), FakeTransactionManager{})
+	os.Exit(m.Run())
 }
 
 // TestGetAllProperties tests the GetAllProperties method of the PropertyService
 func TestGetAllProperties(t *testing.T) {
-	actualProperties, err := propertyService.GetAllProperties()
+	actualProperties, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{})
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
 	t.Run("TestGetAllProperties", func(t *testing.T) {
-		assert.Equal(t, 11, len(actualProperties))
+		assert.Equal(t, 11, len(actualProperties.Properties))
 	})
 }
 
 // TestGetPropertyById tests the GetPropertyById method of the PropertyService
 func TestGetPropertyById(t *testing.T) {
-	actualProperty, err := propertyService.GetPropertyById(3)
+	actualProperty, err := propertyService.GetPropertyById(ctx, 3)
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
@@ -189,7 +199,8 @@ func TestGetPropertyById(t *testing.T) {
 			SquareFeet:  2200,
 			AgentName:   "Ayse Kaya",
 			AgentTitle:  "Luxury Property Specialist",
-			ImageURLs:   []string{"https://example.com/antalya_penthouse1.jpg", "https://example.com/antalya_penthouse2.jpg", "https://example.com/antalya_penthouse3.jpg"},
+			Images:      []domain.PropertyImage{{URL: "https://example.com/antalya_penthouse1.jpg"}, {URL: "https://example.com/antalya_penthouse2.jpg"}, {URL: "https://example.com/antalya_penthouse3.jpg"}},
+			Status:      domain.StatusActive,
 		}
 		assert.Equal(t, expectedProperty, actualProperty)
 	})
@@ -205,19 +216,228 @@ func TestAddProperty(t *testing.T) {
 		Bedrooms:    8,
 		Bathrooms:   6,
 		SquareFeet:  8000,
-		AgentName:   "Ali Yilmaz",
-		AgentTitle:  "Bosphorus Property Specialist",
-		ImageURLs:   []string{"https://example.com/istanbul_mansion1.jpg", "https://example.com/istanbul_mansion2.jpg"},
 	}
-	err := propertyService.AddProperty(property)
+	addedProperty, err := propertyService.AddProperty(ctx, property)
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
+	t.Run("TestAddPropertyReturnsId", func(t *testing.T) {
+		assert.Equal(t, int64(15), addedProperty.ID)
+		assert.Equal(t, property.Title, addedProperty.Title)
+	})
 	t.Run("TestAddProperty", func(t *testing.T) {
-		actualProperties, err := propertyService.GetAllProperties()
+		actualProperties, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{})
 		if err != nil {
 			t.Errorf("Error: %v", err)
 		}
-		assert.Equal(t, 12, len(actualProperties))
+		assert.Equal(t, 12, len(actualProperties.Properties))
+	})
+}
+
+// TestGetAllPropertiesWithCriteria tests filtering, sorting and paging in the GetAllProperties method of the PropertyService
+func TestGetAllPropertiesWithCriteria(t *testing.T) {
+	t.Run("FilterByLocation", func(t *testing.T) {
+		page, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{Location: "cesme"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), page.TotalCount)
+		assert.Equal(t, int64(13), page.Properties[0].ID)
+		assert.Equal(t, int64(14), page.Properties[1].ID)
+	})
+	t.Run("SortByPriceDescending", func(t *testing.T) {
+		page, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{SortField: "price", SortDirection: "DESC", PageSize: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Properties))
+		assert.Equal(t, "Bodrum, Turkey", page.Properties[0].Location)
+	})
+	t.Run("Paginate", func(t *testing.T) {
+		maxPrice := 500000
+		page, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{MaxPrice: &maxPrice, SortField: "price", Page: 2, PageSize: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), page.TotalCount)
+		assert.Equal(t, 2, page.Page)
+		assert.Equal(t, "Alanya, Turkey", page.Properties[0].Location)
+	})
+	t.Run("LastPage", func(t *testing.T) {
+		page, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{Page: domain.MaxPage, PageSize: domain.MaxPageSize})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.MaxPage, page.Page)
+		assert.Empty(t, page.Properties)
+		assert.Equal(t, int64(domain.MaxPage-1)*domain.MaxPageSize, domain.PropertyCriteria{Page: domain.MaxPage, PageSize: domain.MaxPageSize}.Offset())
+	})
+	t.Run("RejectUnknownSortField", func(t *testing.T) {
+		_, err := propertyService.GetAllProperties(ctx, domain.PropertyCriteria{SortField: "agent_phone"})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+// TestSearchProperties tests the SearchProperties method of the PropertyService
+func TestSearchProperties(t *testing.T) {
+	t.Run("RankTitleMatchesFirst", func(t *testing.T) {
+		page, err := propertyService.SearchProperties(ctx, "penthouse", 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Results))
+		assert.Equal(t, int64(3), page.Results[0].Property.ID)
+		assert.Equal(t, "Seaside <mark>Penthouse</mark> in Antalya", page.Results[0].Highlights["title"])
+	})
+	t.Run("MatchEveryTerm", func(t *testing.T) {
+		page, err := propertyService.SearchProperties(ctx, "seaside izmir", 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Results))
+		assert.Equal(t, int64(6), page.Results[0].Property.ID)
+	})
+	t.Run("Paginate", func(t *testing.T) {
+		page, err := propertyService.SearchProperties(ctx, "turkey", 2, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Results))
+		assert.Greater(t, page.TotalCount, int64(2))
+		assert.Equal(t, 2, page.Page)
+		assert.Equal(t, 1, page.PageSize)
+	})
+	t.Run("RejectEmptyQuery", func(t *testing.T) {
+		_, err := propertyService.SearchProperties(ctx, "  ", 1, 10)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+// TestAddPropertyValidation tests that AddProperty reports every invalid field at once
+func TestAddPropertyValidation(t *testing.T) {
+	property := model.PropertyCreate{
+		Location:   "Bodrum, Turkey",
+		Price:      -1,
+		Bedrooms:   -2,
+		SquareFeet: 1200,
+	}
+	_, err := propertyService.AddProperty(ctx, property)
+
+	var validationError *domain.ValidationError
+	assert.ErrorAs(t, err, &validationError)
+	var fields []string
+	for _, fieldError := range validationError.Fields {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"title", "price", "bedrooms"}, fields)
+}
+
+// TestPatchProperty tests the PatchProperty method of the PropertyService
+func TestPatchProperty(t *testing.T) {
+	newService := func() services.IPropertyService {
+		return services.NewPropertyService(NewFakePropertyRepository([]domain.Property{
+			{
+				ID:          1,
+				Location:    "Ankara, Turkey",
+				Price:       800000,
+				Title:       "Modern City Apartment",
+				Description: "Centrally located modern apartment with panoramic city views in Ankara.",
+				Bedrooms:    3,
+				Bathrooms:   2,
+				SquareFeet:  1500,
+				Images:      []domain.PropertyImage{{URL: "https://example.com/ankara_apt1.jpg"}},
+			},
+		}), NewFakeAuditRepository(), FakeTransactionManager{})
+	}
+
+	t.Run("MergePatch", func(t *testing.T) {
+		patchService := newService()
+		patched, err := patchService.PatchProperty(ctx, 1, model.PropertyPatch{
+			Type:     model.MergePatch,
+			Document: []byte(`{"price": 850000, "description": null}`),
+		}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 850000, patched.Price)
+		assert.Equal(t, "", patched.Description)
+		assert.Equal(t, "Modern City Apartment", patched.Title)
+
+		stored, _ := patchService.GetPropertyById(ctx, 1)
+		assert.Equal(t, patched, stored)
+	})
+	t.Run("JSONPatch", func(t *testing.T) {
+		patchService := newService()
+		patched, err := patchService.PatchProperty(ctx, 1, model.PropertyPatch{
+			Type: model.JSONPatch,
+			Document: []byte(`[
+				{"op": "test", "path": "/price", "value": 800000},
+				{"op": "replace", "path": "/bedrooms", "value": 4},
+				{"op": "replace", "path": "/description", "value": "Bright apartment in central Ankara."}
+			]`),
+		}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, patched.Bedrooms)
+		assert.Equal(t, "Bright apartment in central Ankara.", patched.Description)
+		assert.Equal(t, []domain.PropertyImage{{URL: "https://example.com/ankara_apt1.jpg"}}, patched.Images)
+	})
+	t.Run("JSONPatchNull", func(t *testing.T) {
+		patchService := newService()
+		patched, err := patchService.PatchProperty(ctx, 1, model.PropertyPatch{
+			Type:     model.JSONPatch,
+			Document: []byte(`[{"op": "replace", "path": "/latitude", "value": 39.92}, {"op": "replace", "path": "/longitude", "value": 32.85}]`),
+		}, 0)
+		assert.NoError(t, err)
+		assert.NotNil(t, patched.Latitude)
+
+		patched, err = patchService.PatchProperty(ctx, 1, model.PropertyPatch{
+			Type: model.JSONPatch,
+			Document: []byte(`[
+				{"op": "test", "path": "/agent_id", "value": null},
+				{"op": "replace", "path": "/latitude", "value": null},
+				{"op": "replace", "path": "/longitude", "value": null}
+			]`),
+		}, 0)
+		assert.NoError(t, err)
+		assert.Nil(t, patched.Latitude)
+		assert.Nil(t, patched.Longitude)
+
+		_, err = patchService.PatchProperty(ctx, 1, model.PropertyPatch{
+			Type:     model.JSONPatch,
+			Document: []byte(`[{"op": "replace", "path": "/price"}]`),
+		}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation, "a missing value is still rejected")
+	})
+	t.Run("FailedTestIsConflict", func(t *testing.T) {
+		_, err := newService().PatchProperty(ctx, 1, model.PropertyPatch{
+			Type:     model.JSONPatch,
+			Document: []byte(`[{"op": "test", "path": "/price", "value": 1}, {"op": "replace", "path": "/price", "value": 2}]`),
+		}, 0)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+	})
+	t.Run("RevalidatePatchedProperty", func(t *testing.T) {
+		_, err := newService().PatchProperty(ctx, 1, model.PropertyPatch{
+			Type:     model.MergePatch,
+			Document: []byte(`{"title": "", "price": 0}`),
+		}, 0)
+		var validationError *domain.ValidationError
+		assert.ErrorAs(t, err, &validationError)
+		assert.Equal(t, 2, len(validationError.Fields))
+	})
+	t.Run("RejectUnknownField", func(t *testing.T) {
+		_, err := newService().PatchProperty(ctx, 1, model.PropertyPatch{
+			Type:     model.MergePatch,
+			Document: []byte(`{"id": 7}`),
+		}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
 	})
 }
+
+// TestOptimisticConcurrency tests that stale versions are rejected by UpdateProperty, PatchProperty and DeleteById
+func TestOptimisticConcurrency(t *testing.T) {
+	concurrencyService := services.NewPropertyService(NewFakePropertyRepository(nil), NewFakeAuditRepository(), FakeTransactionManager{})
+	property := model.PropertyCreate{Location: "Izmir, Turkey", Price: 1200000, Title: "Seaside Condo in Izmir"}
+	added, err := concurrencyService.AddProperty(ctx, property)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), added.Version)
+
+	property.Price = 1250000
+	updated, err := concurrencyService.UpdateProperty(ctx, added.ID, property, added.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	_, err = concurrencyService.UpdateProperty(ctx, added.ID, property, added.Version)
+	assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+	_, err = concurrencyService.PatchProperty(ctx, added.ID, model.PropertyPatch{Type: model.MergePatch, Document: []byte(`{"price": 1}`)}, added.Version)
+	assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+	_, err = concurrencyService.DeleteById(ctx, added.ID, added.Version)
+	assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+
+	deleted, err := concurrencyService.DeleteById(ctx, added.ID, updated.Version)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"testing"
+)
+
+// TestStatusLifecycle tests the status transitions of the PropertyService and the status filtering of lists
+func TestStatusLifecycle(t *testing.T) {
+	agentID := int64(7)
+	statusService := services.NewPropertyService(NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse", Version: 1, OwnerID: &agentID},
+		{ID: 2, Location: "Bodrum, Turkey", Price: 3500000, Title: "Beach Villa", Version: 1},
+		{ID: 3, Location: "Izmir, Turkey", Price: 1200000, Title: "Alsancak Condo", Status: domain.StatusDraft, Version: 1},
+	}), NewFakeAuditRepository(), FakeTransactionManager{})
+	agentCtx := requestctx.WithPrincipal(ctx, domain.Principal{Name: "ayse.kaya", UserID: agentID, Role: domain.RoleAgent})
+
+	t.Run("SellThroughAnOffer", func(t *testing.T) {
+		property, err := statusService.TransitionProperty(agentCtx, 1, model.StatusTransition{Status: domain.StatusUnderOffer}, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusUnderOffer, property.Status)
+		assert.Equal(t, int64(2), property.Version)
+		property, err = statusService.TransitionProperty(agentCtx, 1, model.StatusTransition{Status: domain.StatusSold, Note: " Deed signed "}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusSold, property.Status)
+
+		history, err := statusService.GetStatusHistory(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusSold, history.Status)
+		assert.Equal(t, []domain.PropertyStatus{domain.StatusArchived}, history.AllowedTransitions)
+		assert.Equal(t, 2, len(history.Changes))
+		assert.Equal(t, domain.StatusActive, history.Changes[0].FromStatus)
+		assert.Equal(t, "ayse.kaya", history.Changes[1].Actor)
+		assert.Equal(t, "Deed signed", history.Changes[1].Note)
+		assert.Equal(t, history.Changes[1].ChangedAt, history.StatusChangedAt)
+	})
+	t.Run("ListsHideUnlistedStatuses", func(t *testing.T) {
+		page, err := statusService.GetAllProperties(ctx, domain.PropertyCriteria{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+		assert.Equal(t, int64(2), page.Properties[0].ID)
+
+		page, err = statusService.GetAllProperties(ctx, domain.PropertyCriteria{Statuses: []domain.PropertyStatus{domain.StatusSold, domain.StatusDraft}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), page.TotalCount)
+
+		_, err = statusService.GetAllProperties(ctx, domain.PropertyCriteria{Statuses: []domain.PropertyStatus{"rented"}})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+	t.Run("RejectTransitionOutsideLifecycle", func(t *testing.T) {
+		_, err := statusService.TransitionProperty(ctx, 1, model.StatusTransition{Status: domain.StatusActive}, 0)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+		_, err = statusService.TransitionProperty(ctx, 3, model.StatusTransition{Status: domain.StatusSold}, 0)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+		_, err = statusService.TransitionProperty(ctx, 3, model.StatusTransition{Status: "rented"}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		_, err = statusService.TransitionProperty(ctx, 2, model.StatusTransition{Status: domain.StatusArchived}, 5)
+		assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+	})
+	t.Run("StatusIsNotEditable", func(t *testing.T) {
+		_, err := statusService.UpdateProperty(ctx, 2, model.PropertyCreate{Location: "Bodrum, Turkey", Price: 3500000, Title: "Beach Villa", Status: domain.StatusSold}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		_, err = statusService.PatchProperty(ctx, 2, model.PropertyPatch{Type: model.MergePatch, Document: []byte(`{"status": "sold"}`)}, 0)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		property, err := statusService.PatchProperty(ctx, 2, model.PropertyPatch{Type: model.MergePatch, Document: []byte(`{"price": 3400000}`)}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusActive, property.Status)
+	})
+	t.Run("CreateAsDraftOrActive", func(t *testing.T) {
+		property, err := statusService.AddProperty(ctx, model.PropertyCreate{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio"})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusActive, property.Status)
+		property, err = statusService.AddProperty(ctx, model.PropertyCreate{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio", Status: domain.StatusDraft})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusDraft, property.Status)
+		_, err = statusService.AddProperty(ctx, model.PropertyCreate{Location: "Kas, Turkey", Price: 990000, Title: "Kas Studio", Status: domain.StatusSold})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
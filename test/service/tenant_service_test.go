@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/requestctx"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"testing"
+	"time"
+)
+
+// TestTenantService tests that requests are resolved to the tenant of their credentials or of their host
+func TestTenantService(t *testing.T) {
+	tenantService := services.NewTenantService(NewFakeTenantRepository([]domain.Tenant{
+		{ID: 1, Slug: "default", Name: "Default", Currency: "TRY"},
+		{ID: 2, Slug: "ege-emlak", Name: "Ege Emlak", Currency: "EUR", Hosts: []string{"ege-emlak.com"}},
+	}), "default", time.Minute)
+	background := context.Background()
+
+	t.Run("Host", func(t *testing.T) {
+		tenant, err := tenantService.ResolveTenant(background, "Ege-Emlak.com:443")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), tenant.ID)
+		tenant, err = tenantService.ResolveTenant(background, "unknown.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "default", tenant.Slug)
+	})
+	t.Run("Credentials", func(t *testing.T) {
+		agentCtx := requestctx.WithPrincipal(background, domain.Principal{Name: "ayse.kaya", UserID: 1, Role: domain.RoleAgent, TenantID: 2})
+		tenant, err := tenantService.ResolveTenant(agentCtx, "api.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "ege-emlak", tenant.Slug)
+
+		otherCtx := requestctx.WithPrincipal(background, domain.Principal{Name: "mehmet.demir", UserID: 2, Role: domain.RoleAgent, TenantID: 1})
+		_, err = tenantService.ResolveTenant(otherCtx, "ege-emlak.com")
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		legacyCtx := requestctx.WithPrincipal(background, domain.Principal{Name: "mehmet.demir", UserID: 2, Role: domain.RoleAgent})
+		_, err = tenantService.ResolveTenant(legacyCtx, "ege-emlak.com")
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+	t.Run("Cache", func(t *testing.T) {
+		repository := &countingTenantRepository{FakeTenantRepository: NewFakeTenantRepository([]domain.Tenant{{ID: 1, Slug: "default"}})}
+		cached := services.NewTenantService(repository, "default", time.Minute)
+		for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+			_, err := cached.ResolveTenant(background, host)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, repository.reads, "hosts are looked up in the cached tenants")
+
+		_, err := cached.CreateTenant(background, model.TenantCreate{Slug: "karadeniz", Name: "Karadeniz Emlak", Hosts: []string{"karadeniz.example.com"}})
+		assert.NoError(t, err)
+		tenant, err := cached.ResolveTenant(background, "karadeniz.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "karadeniz", tenant.Slug, "a new tenant is served at once")
+		assert.Equal(t, 2, repository.reads)
+	})
+	t.Run("NoDefault", func(t *testing.T) {
+		strict := services.NewTenantService(NewFakeTenantRepository(nil), "", time.Minute)
+		_, err := strict.ResolveTenant(background, "unknown.example.com")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+	t.Run("CreateTenant", func(t *testing.T) {
+		tenant, err := tenantService.CreateTenant(background, model.TenantCreate{Slug: "marmara", Name: "Marmara Gayrimenkul", Currency: "usd", Hosts: []string{"Marmara.example.com"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "USD", tenant.Currency)
+		assert.Equal(t, []string{"marmara.example.com"}, tenant.Hosts)
+
+		_, err = tenantService.CreateTenant(background, model.TenantCreate{Slug: "Bad Slug", Currency: "lira", Hosts: []string{"not a host"}, CorsOrigins: []string{"example.com"}})
+		var validationError *domain.ValidationError
+		assert.ErrorAs(t, err, &validationError)
+		assert.Len(t, validationError.Fields, 5)
+	})
+}
+
+// countingTenantRepository counts how often every tenant is read
+type countingTenantRepository struct {
+	*FakeTenantRepository
+	reads int
+}
+
+func (repository *countingTenantRepository) GetTenants(ctx context.Context) ([]domain.Tenant, error) {
+	repository.reads++
+	return repository.FakeTenantRepository.GetTenants(ctx)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"kirmac-site-backend/common/storage"
+	"kirmac-site-backend/domain"
+	"kirmac-site-backend/services"
+	"kirmac-site-backend/services/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTrash tests that deleted properties can be listed, restored and purged
+func TestTrash(t *testing.T) {
+	repository := NewFakePropertyRepository([]domain.Property{
+		{ID: 1, Location: "Antalya, Turkey", Price: 1800000, Title: "Seaside Penthouse", Version: 1},
+		{ID: 2, Location: "Bodrum, Turkey", Price: 3500000, Title: "Beach Villa", Version: 1},
+	})
+	trashService := services.NewPropertyService(repository, NewFakeAuditRepository(), FakeTransactionManager{})
+
+	deleted, err := trashService.DeleteById(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	t.Run("HiddenFromReads", func(t *testing.T) {
+		_, err := trashService.GetPropertyById(ctx, 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		page, err := trashService.GetAllProperties(ctx, domain.PropertyCriteria{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+		_, err = trashService.DeleteById(ctx, 1, 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+	t.Run("ListedInTrash", func(t *testing.T) {
+		page, err := trashService.GetDeletedProperties(ctx, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), page.TotalCount)
+		assert.Equal(t, int64(1), page.Properties[0].ID)
+		assert.NotNil(t, page.Properties[0].DeletedAt)
+	})
+	t.Run("Restore", func(t *testing.T) {
+		property, err := trashService.RestoreProperty(ctx, 1)
+		assert.NoError(t, err)
+		assert.Nil(t, property.DeletedAt)
+		assert.Equal(t, int64(3), property.Version)
+		_, err = trashService.RestoreProperty(ctx, 2)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+	t.Run("PurgeAfterRetention", func(t *testing.T) {
+		localStorage, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/images")
+		assert.NoError(t, err)
+		imageService := services.NewImageService(repository, repository, NewFakeAuditRepository(), FakeTransactionManager{}, localStorage, 1024)
+		uploaded, err := imageService.UploadImage(ctx, 2, bytes.NewReader(pngImage(t)), model.ImageCreate{}, 0)
+		assert.NoError(t, err)
+		var keys []string
+		for _, url := range []string{uploaded.Images[0].URL, uploaded.Images[0].Variants["thumbnail"].URL} {
+			keys = append(keys, strings.TrimPrefix(url, "http://localhost:8080/images/"))
+		}
+
+		_, err = trashService.DeleteById(ctx, 2, 0)
+		assert.NoError(t, err)
+		purger := services.NewTrashPurger(repository, NewFakeTenantRepository([]domain.Tenant{{ID: 1, Slug: "default"}}), localStorage, 24*time.Hour, time.Hour)
+
+		purged, err := purger.Purge(ctx, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), purged)
+
+		purged, err = purger.Purge(ctx, time.Now().Add(25*time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		page, err := trashService.GetDeletedProperties(ctx, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), page.TotalCount)
+		for _, key := range keys {
+			_, err := localStorage.Get(ctx, key)
+			assert.ErrorIs(t, err, storage.ErrNotFound, "the files of uploaded images are deleted with the property")
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"kirmac-site-backend/common/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var ctx = context.Background()
+
+// testStorage runs the behaviour every backend must share
+func testStorage(t *testing.T, objectStorage storage.Storage) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		assert.NoError(t, objectStorage.Put(ctx, "properties/1/cover.png", strings.NewReader("png data"), "image/png"))
+		object, err := objectStorage.Get(ctx, "properties/1/cover.png")
+		assert.NoError(t, err)
+		defer object.Body.Close()
+		content, _ := io.ReadAll(object.Body)
+		assert.Equal(t, "png data", string(content))
+		assert.Equal(t, "image/png", object.ContentType)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, objectStorage.Put(ctx, "properties/1/old.png", strings.NewReader("old"), "image/png"))
+		assert.NoError(t, objectStorage.Delete(ctx, "properties/1/old.png"))
+		_, err := objectStorage.Get(ctx, "properties/1/old.png")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		assert.NoError(t, objectStorage.Delete(ctx, "properties/1/old.png"))
+	})
+	t.Run("RejectInvalidKeys", func(t *testing.T) {
+		for _, key := range []string{"../secret", "/etc/passwd", "properties/../../secret", "properties//1", "a b"} {
+			assert.Error(t, objectStorage.Put(ctx, key, strings.NewReader("x"), "text/plain"), key)
+			_, err := objectStorage.Get(ctx, key)
+			assert.ErrorIs(t, err, storage.ErrNotFound, key)
+		}
+	})
+}
+
+func TestLocalStorage(t *testing.T) {
+	localStorage, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/images/")
+	assert.NoError(t, err)
+	testStorage(t, localStorage)
+	assert.Equal(t, "http://localhost:8080/images/properties/1/cover.png", localStorage.URL("properties/1/cover.png"))
+}
+
+// fakeS3 is an in-memory stand-in for an S3 bucket that checks the request signature headers
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (s3 *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=test-key/") || !strings.Contains(authorization, "/eu-central-1/s3/aws4_request") ||
+		!strings.Contains(authorization, "host;x-amz-content-sha256;x-amz-date, Signature=") || r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/listings/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s3.objects[key] = body
+		s3.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		object, ok := s3.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", s3.types[key])
+		w.Write(object)
+	case http.MethodDelete:
+		delete(s3.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}, types: map[string]string{}})
+	defer server.Close()
+
+	s3Storage, err := storage.NewS3Storage(storage.S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-central-1",
+		Bucket:          "listings",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	}, server.Client())
+	assert.NoError(t, err)
+	testStorage(t, s3Storage)
+	assert.Equal(t, server.URL+"/listings/properties/1/cover.png", s3Storage.URL("properties/1/cover.png"))
+
+	t.Run("WrongCredentials", func(t *testing.T) {
+		wrongStorage, _ := storage.NewS3Storage(storage.S3Config{Endpoint: server.URL, Region: "us-east-1", Bucket: "listings", AccessKeyID: "other"}, server.Client())
+		assert.Error(t, wrongStorage.Put(ctx, "properties/1/cover.png", strings.NewReader("png data"), "image/png"))
+	})
+}